@@ -73,13 +73,14 @@ _tf_manage2_complete() {
                 fi
                 ;;
             3)
-                # Complete config init formats
-                if [[ "${COMP_WORDS[2]}" == "init" ]]; then
-                    local suggestions
-                    suggestions=$(_call_tf_completion "config_init")
-                    if [[ $? -eq 0 && -n "$suggestions" ]]; then
-                        COMPREPLY=($(compgen -W "$suggestions" -- "$cur_word"))
-                    fi
+                # Complete config init formats and config set keys
+                local suggestions
+                case "${COMP_WORDS[2]}" in
+                    init) suggestions=$(_call_tf_completion "config_init") ;;
+                    set) suggestions=$(_call_tf_completion "config_keys") ;;
+                esac
+                if [[ -n "$suggestions" ]]; then
+                    COMPREPLY=($(compgen -W "$suggestions" -- "$cur_word"))
                 fi
                 ;;
             *)
@@ -95,11 +96,11 @@ _tf_manage2_complete() {
             # Complete products or config command
             local suggestions
             suggestions=$(_call_tf_completion "products")
-            # Add config as a special command
+            # Add config, validate-all, drift, workspaces, inspect, clean, login, logout and self-update as special commands
             if [[ $? -eq 0 && -n "$suggestions" ]]; then
-                suggestions="$suggestions config"
+                suggestions="$suggestions config validate-all drift workspaces inspect clean login logout self-update"
             else
-                suggestions="config"
+                suggestions="config validate-all drift workspaces inspect clean login logout self-update"
             fi
             COMPREPLY=($(compgen -W "$suggestions" -- "$cur_word"))
             ;;
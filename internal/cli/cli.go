@@ -1,12 +1,18 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/format"
+	"github.com/sorinlg/tf-manage2/internal/framework"
 	"github.com/sorinlg/tf-manage2/internal/terraform"
 )
 
@@ -26,27 +32,180 @@ func SetVersionInfo(v, c, d, b string) {
 	builtBy = b
 }
 
-// Execute is the main CLI entry point
+// globalOptions holds tf-manage flags that may appear anywhere on the command line
+type globalOptions struct {
+	Vars        []string      // Variable overrides from --set, in command line order
+	ProjectDir  string        // Explicit project root from --project-dir
+	ConfigFile  string        // Alternate config file from --config
+	Format      string        // Output format for summaries from --format
+	Quiet       bool          // Suppress informational banners (--quiet)
+	ASCII       bool          // Use [OK] and [FAIL] status indicators (--ascii)
+	Color       string        // When to color tf-manage output: auto, always or never (--color)
+	Verbose     bool          // Echo every terraform command (--verbose or TFM_VERBOSE)
+	Trace       bool          // Log every executed command as parsed, with its directory and env (--trace)
+	RedactVars  bool          // Hide -var values in echoed commands (--redact-vars)
+	Timeout     time.Duration // Limit for each terraform command (--timeout)
+	ErrorOnWarn bool          // Make config lint and TF_VAR_tfm_* conflicts fail instead of warning (--error-on-warn)
+
+	TerraformColor bool // Keep terraform's colored output in unattended mode (--terraform-color)
+	JSON           bool // Print a single JSON result on stdout when the run finishes (--json)
+
+	RespectEnvWorkspace bool // Use an exported TF_WORKSPACE instead of the computed workspace (--respect-env-workspace)
+
+	NoDeprecationWarning bool   // Hide the legacy .tfm.conf deprecation notice (--no-deprecation-warning)
+	WorkspacePrefix      string // Replace the configured workspace_prefix (--workspace-prefix)
+	EnvFile              string // Dotenv file passed to terraform commands (--env-file)
+	LogFile              string // Transcript of the run's output without colors (--log-file)
+	Timings              bool   // Print how long each phase of the run took (--timings)
+	OutDir               string // Absolute base directory for generated artifacts (--out-dir)
+	SkipValidation       bool   // Skip the product, repo, module, env and config checks (--skip-validation or TFM_SKIP_VALIDATION)
+	SkipIfMissing        bool   // Exit 0 without running terraform when the instance tfvars is missing (--skip-if-missing)
+}
+
+// managerOptions converts CLI options into terraform manager options
+func (o *globalOptions) managerOptions() terraform.Options {
+	return terraform.Options{
+		Verbose:    o.Verbose,
+		RedactVars: o.RedactVars,
+		Timeout:    o.Timeout,
+
+		TerraformColor:      o.TerraformColor,
+		RespectEnvWorkspace: o.RespectEnvWorkspace,
+		EnvFile:             o.EnvFile,
+		Timings:             o.Timings,
+		OutDir:              o.OutDir,
+		SkipValidation:      o.SkipValidation,
+		SkipIfMissing:       o.SkipIfMissing,
+		ErrorOnWarn:         o.ErrorOnWarn,
+	}
+}
+
+// Execute is the main CLI entry point. Failures that carry their own exit code terminate
+// the process; use Run to keep control of the process instead.
 func Execute() error {
-	args := os.Args[1:]
+	code, err := Run(os.Args[1:])
+	if err == nil && code != 0 {
+		os.Exit(code)
+	}
+	return err
+}
+
+// Run executes tf-manage with args (without the program name) and returns the exit code
+// instead of exiting. err is only set for failures that have not already been reported
+// to the user; strict mode command failures are returned rather than exiting.
+func Run(args []string) (int, error) {
+	previous := framework.SetStrictExit(false)
+	defer framework.SetStrictExit(previous)
+
+	args, opts, err := parseGlobalFlags(args)
+	if err != nil {
+		return ExitCode(err), err
+	}
+	if opts.NoDeprecationWarning {
+		config.SuppressDeprecationNotice()
+	}
+	if opts.LogFile != "" {
+		// A relative log file is an artifact of the run when --out-dir is set. artifact_dir
+		// cannot apply here since the log is opened before the config is loaded.
+		if opts.OutDir != "" && !filepath.IsAbs(opts.LogFile) {
+			opts.LogFile = filepath.Join(opts.OutDir, opts.LogFile)
+			os.MkdirAll(filepath.Dir(opts.LogFile), 0755)
+		}
+		if err := framework.OpenLogFile(opts.LogFile); err != nil {
+			err = terraform.NewExitCodeError(fmt.Sprintf("cannot open log file: %v", err), terraform.ExitValidationFailed)
+			return ExitCode(err), err
+		}
+		defer framework.CloseLogFile()
+	}
+
+	// With --json, stdout carries only the result; everything else is sent to stderr
+	stdout := os.Stdout
+	if opts.JSON {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = stdout }()
+	}
+
+	start := time.Now()
+	summary := &runSummary{}
+	code, err := runExitCode(run(args, opts, summary))
+
+	if opts.JSON {
+		summary.ExitCode = code
+		summary.Duration = time.Since(start).Round(time.Millisecond).String()
+		if err != nil {
+			summary.Error = framework.MaskText(err.Error())
+		}
+		// The result is printed like any other output, so mask_identifiers applies to it
+		summary.Workspace = framework.MaskText(summary.Workspace)
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(summary); encodeErr != nil && err == nil {
+			return 1, encodeErr
+		}
+	}
+
+	return code, err
+}
+
+// runExitCode converts the error returned by run into Run's exit code and error
+func runExitCode(err error) (int, error) {
+	var status *exitStatus
+	switch {
+	case errors.As(err, &status):
+		return status.code, nil
+	case err != nil:
+		return ExitCode(err), err
+	}
+	return 0, nil
+}
+
+// runSummary is the result printed by --json once a run has finished
+type runSummary struct {
+	terraform.RunInfo
+	ExitCode int    `json:"exit_code"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// exitStatus ends a run with a specific exit code after the outcome was already reported
+type exitStatus struct {
+	code int
+}
+
+func (e *exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// run dispatches a command line to the matching tf-manage command, recording what was run in summary
+func run(args []string, opts *globalOptions, summary *runSummary) error {
+	if opts.Quiet {
+		framework.SetQuiet(true)
+	}
+	if opts.ASCII {
+		framework.SetASCII(true)
+	}
+	if opts.Trace {
+		framework.SetTrace(true)
+	}
+	if opts.Color != "" {
+		framework.SetColorMode(opts.Color)
+	}
+
+	// Let running terraform commands shut down cleanly on Ctrl-C or SIGTERM
+	defer framework.ForwardSignals(framework.DefaultGracePeriod)()
 
 	if len(args) == 0 {
 		return showUsage()
 	}
 
 	// Handle version flag
-	if len(args) == 1 && (args[0] == "--version" || args[0] == "-v") {
-		fmt.Printf("tf-manage2 version %s\n", version)
-		if commit != "none" {
-			fmt.Printf("  commit: %s\n", commit)
-		}
-		if date != "unknown" {
-			fmt.Printf("  built: %s\n", date)
-		}
-		if builtBy != "unknown" {
-			fmt.Printf("  built by: %s\n", builtBy)
-		}
-		return nil
+	if args[0] == "--version" || args[0] == "-v" || args[0] == "version" {
+		return handleVersion(args[1:])
+	}
+
+	// Handle replacing this binary with the latest release
+	if args[0] == "self-update" {
+		return handleSelfUpdate(args[1:])
 	}
 
 	// Handle help flag
@@ -56,45 +215,370 @@ func Execute() error {
 
 	// Handle completion commands
 	if len(args) >= 1 && args[0] == "__complete" {
-		return handleCompletion(args[1:])
+		return handleCompletion(args[1:], opts)
 	}
 
 	// Handle config commands
 	if len(args) >= 1 && args[0] == "config" {
-		return handleConfigCommand(args[1:])
+		return handleConfigCommand(args[1:], opts)
+	}
+
+	// Handle login/logout, which need no project or configuration
+	if terraform.IsContextFreeAction(args[0]) {
+		cmd, err := parseCommand(args)
+		if err != nil {
+			return err
+		}
+		summary.Action = cmd.Action
+		tfm := terraform.NewManager(config.DefaultConfig())
+		tfm.SetOptions(opts.managerOptions())
+		err = tfm.Authenticate(cmd)
+		summary.RunInfo = tfm.RunInfo()
+
+		var exitCodeErr *terraform.ExitCodeError
+		if errors.As(err, &exitCodeErr) {
+			return &exitStatus{code: exitCodeErr.ExitCode}
+		}
+		return err
 	}
 
 	// Load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := loadConfig(opts)
 	if err != nil {
 		return err
 	}
 
-	// Parse command arguments
-	cmd, err := parseCommand(args)
-	if err != nil {
+	// Handle repo-wide validation
+	if args[0] == "validate-all" {
+		summary.Action = args[0]
+		tfm := terraform.NewManager(cfg)
+		tfm.SetOptions(opts.managerOptions())
+		return tfm.ValidateAll(framework.Stdout(), opts.Format)
+	}
+
+	// Handle drift detection across every instance of a module
+	if args[0] == "drift" {
+		summary.Action = args[0]
+		return handleDrift(args[1:], cfg, opts)
+	}
+
+	// Handle listing a module's workspaces as tf-manage coordinates
+	if args[0] == "workspaces" {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: tf workspaces <module>")
+		}
+		summary.Action = args[0]
+		tfm := terraform.NewManager(cfg)
+		tfm.SetOptions(opts.managerOptions())
+		err := tfm.ListWorkspaces(framework.Stdout(), opts.Format, args[1])
+		if exitCodeErr, ok := err.(*terraform.ExitCodeError); ok {
+			return &exitStatus{code: exitCodeErr.ExitCode}
+		}
 		return err
 	}
 
+	// Handle inspection of the resolved paths and workspace
+	if args[0] == "inspect" {
+		summary.Action = args[0]
+		return handleInspect(args[1:], cfg, opts)
+	}
+
+	// Handle removal of cached .terraform directories
+	if args[0] == "clean" {
+		summary.Action = args[0]
+		return handleClean(args[1:], cfg, opts)
+	}
+
 	// Create terraform manager
 	tfm := terraform.NewManager(cfg)
+	tfm.SetOptions(opts.managerOptions())
 
-	// Execute the command
-	err = tfm.Execute(cmd)
+	// Let the operator pick the instance when it was omitted
+	if omitsInstance(args) {
+		instance, err := pickInstance(cfg, tfm.IsUnattended(), args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+		args = slices.Insert(args, 3, instance)
+	}
+
+	// Parse command arguments
+	cmd, err := parseCommand(args)
+	if err != nil {
+		return err
+	}
+	cmd.Vars = opts.Vars
+
+	// An env glob such as prod/* runs the action in every matching env, one after another
+	if terraform.IsEnvPattern(cmd.Env) {
+		err = executeEnvPattern(tfm, cmd, summary)
+	} else {
+		err = tfm.Execute(cmd)
+		summary.RunInfo = tfm.RunInfo()
+	}
 
 	// Check if this is an exit code error and exit with the specific code
-	if exitCodeErr, ok := err.(*terraform.ExitCodeError); ok {
+	var exitCodeErr *terraform.ExitCodeError
+	if errors.As(err, &exitCodeErr) {
 		// For exit code errors, we want to preserve the specific exit code
-		os.Exit(exitCodeErr.ExitCode)
+		return &exitStatus{code: exitCodeErr.ExitCode}
 	}
 
 	return err
 }
 
+// executeEnvPattern runs cmd in each env matching its env pattern and stops at the first failure
+func executeEnvPattern(tfm *terraform.Manager, cmd *terraform.Command, summary *runSummary) error {
+	envs, err := tfm.ExpandEnvPattern(cmd.Product, cmd.Module, cmd.Env)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	framework.Info(fmt.Sprintf("Env %s matches %s", framework.AddEmphasisBlue(cmd.Env), strings.Join(envs, ", ")))
+
+	for i, env := range envs {
+		envCmd := *cmd
+		envCmd.Env = env
+		framework.Info(fmt.Sprintf("Running %s in env %s (%d of %d)", cmd.Action, framework.AddEmphasisBlue(env), i+1, len(envs)))
+
+		err := tfm.Execute(&envCmd)
+		summary.RunInfo = tfm.RunInfo()
+
+		// Actions report success as an exit code error with code 0
+		var exitCodeErr *terraform.ExitCodeError
+		if err != nil && !(errors.As(err, &exitCodeErr) && exitCodeErr.ExitCode == 0) {
+			if i+1 < len(envs) {
+				framework.Error(fmt.Sprintf("Stopping after env %s failed; skipped %s", env, strings.Join(envs[i+1:], ", ")))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // Command represents a tf-manage command
 type Command = terraform.Command
 
+// parseGlobalFlags extracts tf-manage flags from args and returns the remaining positional arguments
+func parseGlobalFlags(args []string) ([]string, *globalOptions, error) {
+	opts := &globalOptions{
+		Format:  "text",
+		Verbose: os.Getenv("TFM_VERBOSE") != "",
+
+		SkipValidation: os.Getenv("TFM_SKIP_VALIDATION") != "",
+	}
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || arg == "--help" || arg == "--version" {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+
+		// Flags owned by a repository command are left for its handler, even when a
+		// global flag has the same name (e.g. inspect --json)
+		if len(positional) > 0 && commandFlags[positional[0]][name] && !hasValue {
+			positional = append(positional, arg)
+			continue
+		}
+		if len(positional) > 0 && commandValueFlags[positional[0]][name] {
+			positional = append(positional, arg)
+			if !hasValue && i+1 < len(args) {
+				i++
+				positional = append(positional, args[i])
+			}
+			continue
+		}
+
+		// takeValue returns the inline value or consumes the next argument
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch name {
+		case "set":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			if !strings.Contains(v, "=") || strings.HasPrefix(v, "=") {
+				return nil, nil, fmt.Errorf("invalid --set value %q (expected key=value)", v)
+			}
+			opts.Vars = append(opts.Vars, v)
+		case "project-dir":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.ProjectDir = v
+		case "config":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.ConfigFile = v
+		case "quiet":
+			opts.Quiet = true
+		case "ascii":
+			opts.ASCII = true
+		case "timings":
+			opts.Timings = true
+		case "skip-validation":
+			opts.SkipValidation = true
+		case "skip-if-missing":
+			opts.SkipIfMissing = true
+		case "verbose":
+			opts.Verbose = true
+		case "trace":
+			opts.Trace = true
+		case "redact-vars":
+			opts.RedactVars = true
+		case "error-on-warn":
+			opts.ErrorOnWarn = true
+		case "terraform-color":
+			opts.TerraformColor = true
+		case "json":
+			opts.JSON = true
+		case "respect-env-workspace":
+			opts.RespectEnvWorkspace = true
+		case "no-deprecation-warning":
+			opts.NoDeprecationWarning = true
+		case "env-file":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.EnvFile = v
+		case "log-file":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			opts.LogFile = v
+		case "out-dir":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			if v == "" {
+				return nil, nil, fmt.Errorf("flag --out-dir requires a value")
+			}
+			if opts.OutDir, err = filepath.Abs(v); err != nil {
+				return nil, nil, err
+			}
+		case "workspace-prefix":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := config.ValidateWorkspacePrefix(v); err != nil {
+				return nil, nil, terraform.NewExitCodeError(err.Error(), terraform.ExitValidationFailed)
+			}
+			opts.WorkspacePrefix = v
+		case "format":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			if _, err := format.Get(v); err != nil {
+				return nil, nil, err
+			}
+			opts.Format = v
+		case "color":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			if v != framework.ColorAuto && v != framework.ColorAlways && v != framework.ColorNever {
+				return nil, nil, fmt.Errorf("invalid --color %q (expected auto, always or never)", v)
+			}
+			opts.Color = v
+		case "timeout":
+			v, err := takeValue()
+			if err != nil {
+				return nil, nil, err
+			}
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, nil, fmt.Errorf("invalid --timeout value %q (expected a positive duration like 30m)", v)
+			}
+			opts.Timeout = d
+		default:
+			return nil, nil, fmt.Errorf("unknown flag: --%s", name)
+		}
+	}
+
+	return positional, opts, nil
+}
+
+// commandFlags are the boolean flags each repository command parses itself
+var commandFlags = map[string]map[string]bool{
+	"clean":     {"all": true, "lock-file": true, "dry-run": true, "force": true},
+	"version":   {"check": true},
+	"--version": {"check": true},
+	"-v":        {"check": true},
+
+	"self-update": {"check-only": true, "yes": true},
+	"inspect":     {"json": true},
+	"drift":       {"changed-only": true},
+	"__complete":  {"describe": true},
+}
+
+// commandValueFlags are the flags taking a value that each repository command parses itself
+var commandValueFlags = map[string]map[string]bool{
+	"drift": {"base": true},
+}
+
+// handleVersion prints the build information and, with --check, whether a newer release exists
+func handleVersion(args []string) error {
+	check := false
+	for _, arg := range args {
+		if arg != "--check" {
+			return fmt.Errorf("usage: tf version [--check]")
+		}
+		check = true
+	}
+
+	fmt.Printf("tf-manage2 version %s\n", version)
+	if commit != "none" {
+		fmt.Printf("  commit: %s\n", commit)
+	}
+	if date != "unknown" {
+		fmt.Printf("  built: %s\n", date)
+	}
+	if builtBy != "unknown" {
+		fmt.Printf("  built by: %s\n", builtBy)
+	}
+
+	if check {
+		checkForUpdate(os.Stdout, version)
+	}
+	return nil
+}
+
 func parseCommand(args []string) (*terraform.Command, error) {
+	// tf login [hostname] / tf logout [hostname]
+	if len(args) > 0 && terraform.IsContextFreeAction(args[0]) {
+		if len(args) > 2 {
+			return nil, fmt.Errorf("usage: tf %s [hostname]", args[0])
+		}
+		cmd := &terraform.Command{Action: args[0]}
+		if len(args) == 2 {
+			cmd.ActionFlags = args[1]
+		}
+		return cmd, nil
+	}
+
 	if len(args) < 5 {
 		return nil, fmt.Errorf("insufficient arguments")
 	}
@@ -119,6 +603,14 @@ func parseCommand(args []string) (*terraform.Command, error) {
 			cmd.ActionFlags = strings.Join(actionParts[1:], " ")
 		}
 	}
+	if err := terraform.ValidateAction(cmd.Action); err != nil {
+		return nil, err
+	}
+
+	// Flags from TFM_ACTION_FLAGS come after the command line's, so terraform sees them last
+	if envFlags := strings.Fields(os.Getenv("TFM_ACTION_FLAGS")); len(envFlags) > 0 {
+		cmd.ActionFlags = strings.Join(append(strings.Fields(cmd.ActionFlags), envFlags...), " ")
+	}
 
 	// Optional workspace override
 	if len(args) == 6 {
@@ -139,34 +631,140 @@ func showHelp() error {
 USAGE:
     tf <product> <module> <env> <module_instance> <action> [workspace]
     tf config <command>
+    tf validate-all
 
 ARGUMENTS:
     product           Product name
     module            Terraform module name
-    env               Environment (dev, staging, prod, etc.)
-    module_instance   Module instance identifier
-    action            Terraform action (init, plan, apply, destroy, etc.)
+    env               Environment (dev, staging, prod, etc.); a glob such as prod/* runs the action
+                      in every matching env that has the module, one after another
+    module_instance   Module instance identifier (omit it to choose from a list; operator mode only)
+    action            Terraform action (see ACTIONS)
     workspace         Optional workspace override (format: workspace=name)
 
+ACTIONS:
+%s
+REPOSITORY COMMANDS:
+    tf validate-all         Run terraform init -backend=false and validate in every module
+    tf drift <product> <module> <env>
+                            Report instances whose real infrastructure drifted (exit 2 on drift);
+                            --changed-only --base REF limits it to instances changed since REF
+    tf workspaces <module>  List the module's workspaces as product/module/env/instance,
+                            flagging names that do not follow the naming scheme
+    tf inspect <product> <module> <env> <module_instance> [--json]
+                            Show the resolved paths and workspace without running terraform
+    tf clean <product> <module>
+                            Remove the module's .terraform directory (--lock-file also removes
+                            .terraform.lock.hcl, --all cleans every module, --dry-run only lists,
+                            --force skips the confirmation)
+
+MAINTENANCE COMMANDS:
+    tf version [--check]    Show version information; --check looks for a newer release
+    tf self-update          Replace this binary with the latest release after verifying its
+                            checksum (--check-only only reports, --yes skips the confirmation)
+
+TERRAFORM CLOUD COMMANDS:
+    tf login [hostname]     Run terraform login (needs an interactive terminal)
+    tf logout [hostname]    Run terraform logout
+
 CONFIGURATION COMMANDS:
     tf config convert       Convert legacy .tfm.conf to .tfm.yaml
     tf config init yaml     Create new .tfm.yaml configuration
     tf config init legacy   Create new .tfm.conf configuration (deprecated)
     tf config validate      Validate current configuration
+    tf config lint          Warn about deprecated or questionable settings
+    tf config bump-version  Migrate configuration to a newer schema version
+    tf config set           Update a single setting in .tfm.yaml
 
 EXAMPLES:
     tf product1 sample_module dev instance_x init
     tf product1 sample_module dev instance_x plan
     tf product1 sample_module dev instance_x apply
     tf product1 sample_module dev instance_x destroy
+    tf product1 sample_module dev instance_x "plan --replace aws_instance.web"
+                            Plan/apply replacing a resource (--replace is repeatable and is
+                            preferred over the deprecated taint and untaint actions)
     tf product1 sample_module dev instance_x plan workspace=custom
+    tf product1 sample_module dev instance_x plan --set instance_count=3
+    tf product1 sample_module dev instance_x "init --migrate-state"
+    tf product1 sample_module dev instance_x "init --upgrade"
+    tf product1 sample_module dev instance_x "output --json-values"
+                            Print outputs as key=value lines; sensitive ones show as
+                            (sensitive) unless --show-sensitive is added
+    tf product1 sample_module dev instance_x "plan --plan-out-text plan.txt"
+    tf product1 sample_module dev instance_x "plan --no-refresh"
+                            Plan without refreshing state first (-refresh=false)
+    tf product1 sample_module dev instance_x "plan --approve-plan"
+                            Save the plan without confirmation when review_plans is enabled
+    tf product1 sample_module dev instance_x "show --json"
+    tf product1 sample_module dev instance_x "raw state pull"
+                            Run any terraform subcommand as typed (unvalidated)
+    tf product1 sample_module dev instance_x deploy
+                            Plan, confirm once, then apply exactly that plan
+    tf product1 sample_module dev instance_x "apply_plan --outputs-file outputs.json"
+    tf product1 sample_module dev instance_x "apply_plan --plan-file artifacts/x.tfplan"
 
 FLAGS:
     -h, --help        Show this help message
-    -v, --version     Show version information
+    -v, --version     Show version information (add --check to look for a newer release)
+    --set key=value   Override a terraform variable (repeatable, applied after the tfvars file)
+    --project-dir DIR Use DIR as the project root instead of the enclosing git repository
+    --config FILE     Read FILE instead of .tfm.yaml/.tfm.conf (relative to the project root)
+    --format NAME     Summary output format (text, json, csv, markdown)
+    --quiet           Suppress informational banners; errors and terraform output are kept
+    --ascii           Use [OK] and [FAIL] instead of ✓ and ✗ in status lines
+    --color WHEN      Color tf-manage output: auto (default; terminals without NO_COLOR), always or never
+    --verbose         Print every terraform command before running it
+    --redact-vars     With --verbose, hide -var values in printed commands
+    --trace           Log every executed command to stderr as JSON: program, argument list,
+                      working directory and environment overrides
+    --timeout DUR     Interrupt any terraform command running longer than DUR (e.g. 30m)
+    --terraform-color Keep terraform's colored output in unattended mode (-no-color is added by default)
+    --json            Print one JSON result (action, workspace, exit code, ...) on stdout; all other output goes to stderr
+    --respect-env-workspace
+                      Use a TF_WORKSPACE exported beforehand instead of the computed workspace
+    --no-deprecation-warning
+                      Hide the legacy .tfm.conf deprecation notice
+    --env-file PATH   Pass the KEY=VALUE lines of a dotenv file to terraform commands
+    --log-file PATH   Also write all tf-manage and terraform output, without colors, to PATH
+    --timings         Print how long validation, workspace selection and the terraform action took
+    --out-dir DIR     Write plans, --plan-out-text, --outputs-file and --log-file artifacts with
+                      relative paths under DIR/<product>/<env>/<module>/<instance> (overrides artifact_dir)
+    --skip-validation Skip the product, repo, module, env and config existence checks
+    --skip-if-missing Exit 0 without running terraform when the instance tfvars does not exist
+    --error-on-warn   Fail on config lint warnings and on exported TF_VAR_tfm_* values that
+                      differ from the injected -var tfm_* values
+    --workspace-prefix PREFIX
+                      Prepend PREFIX. to every workspace name (overrides workspace_prefix)
+
+Ctrl-C and SIGTERM are forwarded to the running terraform command, which gets
+10s to exit (and release its state lock) before it is killed. During interactive
+commands the first Ctrl-C lets terraform cancel on its own and a second one kills it.
 
 ENVIRONMENT VARIABLES:
-    TF_EXEC_MODE_OVERRIDE=1    Force unattended mode (auto-approve)
+    TF_EXEC_MODE_OVERRIDE=1    Force unattended mode (apply and destroy are auto-approved)
+    TFM_CACHE_DIR=path         Directory for cached data (default: user cache dir)
+    TFM_CONFIG_URL=https://... Load the YAML config from a URL instead of .tfm.yaml/.tfm.conf
+    TFM_CONFIG_TOKEN=token     Bearer token sent when fetching TFM_CONFIG_URL
+    TFM_ALLOW_PROTECTED=1      Allow unattended apply/destroy/import on protected_envs
+    TFM_SKIP_VERSION_CHECK=1   Skip terraform version detection
+    TFM_QUIET=1                Same as --quiet
+    TFM_ASCII=1                Same as --ascii
+    TFM_ACTION_FLAGS="..."     Action flags appended after those given on the command line
+    TFM_CHECK_MARK, TFM_CROSS_MARK
+                               Replace the success and failure status indicators
+    TFM_DONE_MESSAGE, TFM_CONTINUE_MESSAGE
+                               Replace the (done) and (continuing...) outcome text
+    TFM_VERBOSE=1              Same as --verbose
+    TFM_TRACE=1                Same as --trace
+    TFM_SKIP_VALIDATION=1      Same as --skip-validation
+    TFM_SUPPRESS_DEPRECATION=1 Same as --no-deprecation-warning
+
+EXIT CODES:
+    64    Validation failed (config, product, module, env or instance missing or invalid)
+    65    Terraform workspace could not be listed, created or selected
+    66    Change not approved (approval_command denied it, or a reviewed plan was not approved)
+    other Terraform's own exit code
 
 CONFIGURATION:
     tf-manage2 supports both legacy (.tfm.conf) and modern (.tfm.yaml) formats.
@@ -174,18 +772,27 @@ CONFIGURATION:
     Use 'tf config convert' to migrate to the new format.
 
 For more information, see: https://github.com/sorinlg/tf-manage2
-`)
+`, actionsHelp())
 	return nil
 }
 
+// actionsHelp lists the supported actions with their descriptions for showHelp
+func actionsHelp() string {
+	var help strings.Builder
+	for _, action := range terraform.SupportedActions() {
+		fmt.Fprintf(&help, "    %-18s%s\n", action.Name, action.Description)
+	}
+	return help.String()
+}
+
 // handleCompletion handles bash completion requests
-func handleCompletion(args []string) error {
+func handleCompletion(args []string, opts *globalOptions) error {
 	if len(args) == 0 {
 		return fmt.Errorf("completion command required")
 	}
 
 	// Try to load configuration
-	cfg, err := config.LoadConfig()
+	cfg, err := loadConfig(opts)
 	if err != nil {
 		// If config fails to load, we're likely not in a tf-manage workspace
 		// Don't output completion suggestions but also don't error
@@ -212,7 +819,7 @@ func handleCompletion(args []string) error {
 		}
 		return completion.SuggestConfigs(args[1], args[2], args[3])
 	case "actions":
-		return completion.SuggestActions()
+		return completion.SuggestActions(slices.Contains(args[1:], "--describe"))
 	case "workspace":
 		return completion.SuggestWorkspace()
 	case "repo":
@@ -221,13 +828,15 @@ func handleCompletion(args []string) error {
 		return completion.SuggestConfigCommands()
 	case "config_init":
 		return completion.SuggestConfigInitFormats()
+	case "config_keys":
+		return completion.SuggestConfigKeys()
 	default:
 		return nil // Silently fail for unknown commands
 	}
 }
 
 // handleConfigCommand handles config-related commands
-func handleConfigCommand(args []string) error {
+func handleConfigCommand(args []string, opts *globalOptions) error {
 	if len(args) == 0 {
 		return showConfigHelp()
 	}
@@ -239,22 +848,36 @@ func handleConfigCommand(args []string) error {
 
 	switch args[0] {
 	case "convert":
-		return handleConfigConvert()
+		return handleConfigConvert(opts)
+	case "migrate-check":
+		return handleConfigMigrateCheck(opts)
 	case "init":
 		if len(args) < 2 {
 			return fmt.Errorf("usage: tf config init <format>\nformats: yaml, legacy")
 		}
-		return handleConfigInit(args[1])
+		return handleConfigInit(args[1], opts)
 	case "validate":
-		return handleConfigValidate()
+		return handleConfigValidate(opts)
+	case "lint":
+		return handleConfigLint(opts)
+	case "bump-version":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: tf config bump-version <target>\nsupported versions: %s", strings.Join(config.SupportedVersions(), ", "))
+		}
+		return handleConfigBumpVersion(args[1], opts)
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: tf config set <key> <value>\nkeys: %s", strings.Join(config.SettableKeys(), ", "))
+		}
+		return handleConfigSet(args[1], args[2], opts)
 	default:
 		return fmt.Errorf("unknown config command: %s\nRun 'tf config --help' for usage", args[0])
 	}
 }
 
 // handleConfigConvert converts legacy .tfm.conf to .tfm.yaml
-func handleConfigConvert() error {
-	projectDir, err := findProjectDir()
+func handleConfigConvert(opts *globalOptions) error {
+	projectDir, err := resolveProjectDir(opts)
 	if err != nil {
 		return fmt.Errorf("failed to find project directory: %w", err)
 	}
@@ -262,9 +885,31 @@ func handleConfigConvert() error {
 	return config.ConvertLegacyToYAML(projectDir)
 }
 
+// handleConfigMigrateCheck previews what 'tf config convert' would write, without writing it
+func handleConfigMigrateCheck(opts *globalOptions) error {
+	projectDir, err := resolveProjectDir(opts)
+	if err != nil {
+		return fmt.Errorf("failed to find project directory: %w", err)
+	}
+
+	preview, err := config.MigrateCheck(projectDir)
+	if err != nil {
+		return err
+	}
+	if preview == "" {
+		fmt.Printf("No changes: .tfm.yaml already matches the converted .tfm.conf\n")
+		return nil
+	}
+	fmt.Print(preview)
+	if _, err := os.Stat(filepath.Join(projectDir, ".tfm.yaml")); err == nil {
+		framework.Info("Note: 'tf config convert' refuses to overwrite the existing .tfm.yaml")
+	}
+	return nil
+}
+
 // handleConfigInit creates a new configuration file
-func handleConfigInit(format string) error {
-	projectDir, err := findProjectDir()
+func handleConfigInit(format string, opts *globalOptions) error {
+	projectDir, err := resolveProjectDir(opts)
 	if err != nil {
 		return fmt.Errorf("failed to find project directory: %w", err)
 	}
@@ -317,18 +962,156 @@ export __tfm_module_rel_path='terraform/modules'
 	}
 }
 
+// handleConfigLint prints non-fatal configuration warnings with suggested fixes
+func handleConfigLint(opts *globalOptions) error {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := config.Lint(cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(warnings) == 0 {
+		fmt.Printf("✅ No lint warnings for %s\n", cfg.ConfigPath)
+		return nil
+	}
+
+	fmt.Printf("⚠️  %d lint warning(s) for %s\n", len(warnings), cfg.ConfigPath)
+	for _, warning := range warnings {
+		fmt.Printf("   %s: %s\n", warning.Field, warning.Message)
+		fmt.Printf("      fix: %s\n", warning.Suggestion)
+	}
+
+	if opts.ErrorOnWarn {
+		return fmt.Errorf("config lint reported %d warning(s)", len(warnings))
+	}
+	return nil
+}
+
+// handleDrift runs `tf drift [--changed-only --base REF] <product> <module> <env>`
+func handleDrift(args []string, cfg *config.Config, opts *globalOptions) error {
+	var driftOpts terraform.DriftOptions
+	changedOnly := false
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--changed-only":
+			changedOnly = true
+		case arg == "--base" && i+1 < len(args):
+			driftOpts.ChangedSince = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--base="):
+			driftOpts.ChangedSince = strings.TrimPrefix(arg, "--base=")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) != 3 {
+		return fmt.Errorf("usage: tf drift [--changed-only --base REF] <product> <module> <env>")
+	}
+	if changedOnly != (driftOpts.ChangedSince != "") {
+		return fmt.Errorf("--changed-only and --base REF must be used together")
+	}
+
+	tfm := terraform.NewManager(cfg)
+	tfm.SetOptions(opts.managerOptions())
+	err := tfm.DetectDrift(framework.Stdout(), opts.Format, positional[0], positional[1], positional[2], driftOpts)
+	if exitCodeErr, ok := err.(*terraform.ExitCodeError); ok {
+		return &exitStatus{code: exitCodeErr.ExitCode}
+	}
+	return err
+}
+
+// handleInspect runs `tf inspect [--json] <product> <module> <env> <instance>`
+func handleInspect(args []string, cfg *config.Config, opts *globalOptions) error {
+	asJSON := false
+	var positional []string
+	for _, arg := range args {
+		if arg == "--json" {
+			asJSON = true
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	if len(positional) != 4 {
+		return fmt.Errorf("usage: tf inspect [--json] <product> <module> <env> <module_instance>")
+	}
+
+	tfm := terraform.NewManager(cfg)
+	tfm.SetOptions(opts.managerOptions())
+	return tfm.Inspect(framework.Stdout(), &terraform.Command{
+		Product:        positional[0],
+		Module:         positional[1],
+		Env:            positional[2],
+		ModuleInstance: positional[3],
+	}, asJSON)
+}
+
+// handleClean runs `tf clean [--all] [--lock-file] [--dry-run] [--force] [<product> <module>]`
+func handleClean(args []string, cfg *config.Config, opts *globalOptions) error {
+	var cleanOpts terraform.CleanOptions
+	var positional []string
+	for _, arg := range args {
+		switch arg {
+		case "--all":
+			cleanOpts.All = true
+		case "--lock-file":
+			cleanOpts.LockFile = true
+		case "--dry-run":
+			cleanOpts.DryRun = true
+		case "--force":
+			cleanOpts.Force = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	usage := fmt.Errorf("usage: tf clean [--lock-file] [--dry-run] [--force] <product> <module> | tf clean --all [...]")
+	if cleanOpts.All && len(positional) != 0 || !cleanOpts.All && len(positional) != 2 {
+		return usage
+	}
+	product, module := "", ""
+	if !cleanOpts.All {
+		product, module = positional[0], positional[1]
+	}
+
+	tfm := terraform.NewManager(cfg)
+	tfm.SetOptions(opts.managerOptions())
+	return tfm.Clean(framework.Stdout(), product, module, cleanOpts)
+}
+
 // handleConfigValidate validates the current configuration
-func handleConfigValidate() error {
-	cfg, err := config.LoadConfig()
+func handleConfigValidate(opts *globalOptions) error {
+	cfg, err := loadConfig(opts)
 	if err != nil {
 		return err
 	}
 
 	fmt.Printf("✅ Configuration is valid\n")
-	fmt.Printf("   Config file: %s\n", cfg.ConfigPath)
-	fmt.Printf("   Repository:  %s\n", cfg.RepoName)
+	if cfg.ConfigURL != "" {
+		fmt.Printf("   Config URL:  %s\n", cfg.ConfigURL)
+	} else {
+		fmt.Printf("   Config file: %s\n", cfg.ConfigPath)
+	}
+	if cfg.ShadowedConfigPath != "" {
+		fmt.Printf("   Shadowed:    %s (ignored; remove it or merge its settings into %s)\n", cfg.ShadowedConfigPath, filepath.Base(cfg.ConfigPath))
+	}
+	if cfg.RepoNameIsAuto() {
+		fmt.Printf("   Repository:  %s (auto)\n", cfg.RepoName)
+	} else {
+		fmt.Printf("   Repository:  %s\n", cfg.RepoName)
+	}
 	fmt.Printf("   Environments: %s\n", cfg.EnvRelPath)
 	fmt.Printf("   Modules:     %s\n", cfg.ModuleRelPath)
+	if cfg.MinTerraformVersion != "" {
+		fmt.Printf("   Terraform:   >= %s\n", cfg.MinTerraformVersion)
+	}
+	if cfg.MaskIdentifiers {
+		fmt.Printf("   Masking:     identifiers hidden in logs\n")
+	}
 
 	if cfg.ConfigVersion != "" {
 		fmt.Printf("   Version:     %s\n", cfg.ConfigVersion)
@@ -339,6 +1122,68 @@ func handleConfigValidate() error {
 	return nil
 }
 
+// handleConfigBumpVersion migrates .tfm.yaml to the target config_version
+func handleConfigBumpVersion(target string, opts *globalOptions) error {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ConfigURL != "" {
+		return fmt.Errorf("bump-version cannot update a remote config (%s); unset %s to migrate a local file", cfg.ConfigURL, config.ConfigURLEnv)
+	}
+	if !config.IsYAMLConfigPath(cfg.ConfigPath) {
+		return fmt.Errorf("bump-version requires a YAML config; run 'tf config convert' first")
+	}
+
+	from := cfg.ConfigVersion
+	if from == target {
+		fmt.Printf("✅ Configuration is already at version %s\n", target)
+		return nil
+	}
+
+	applied, err := config.MigrateConfig(cfg, target)
+	if err != nil {
+		return err
+	}
+
+	if err := config.WriteYAMLConfig(cfg.ConfigPath, cfg); err != nil {
+		return fmt.Errorf("failed to write YAML config: %w", err)
+	}
+
+	fmt.Printf("✅ Bumped config_version from %s to %s\n", from, target)
+	for _, migration := range applied {
+		fmt.Printf("   %s -> %s: %s\n", migration.From, migration.To, migration.Description)
+	}
+	return nil
+}
+
+// handleConfigSet updates a single setting in the YAML config file
+func handleConfigSet(key, value string, opts *globalOptions) error {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ConfigURL != "" {
+		return fmt.Errorf("config set cannot update a remote config (%s); unset %s to edit a local file", cfg.ConfigURL, config.ConfigURLEnv)
+	}
+	if !config.IsYAMLConfigPath(cfg.ConfigPath) {
+		return fmt.Errorf("config set requires a YAML config; run 'tf config convert' first")
+	}
+
+	if err := cfg.Set(key, value); err != nil {
+		return err
+	}
+
+	if err := config.WriteYAMLConfig(cfg.ConfigPath, cfg); err != nil {
+		return fmt.Errorf("failed to write YAML config: %w", err)
+	}
+
+	fmt.Printf("✅ Set %s to %s in %s\n", key, value, cfg.ConfigPath)
+	return nil
+}
+
 // showConfigHelp shows help for config commands
 func showConfigHelp() error {
 	fmt.Printf(`tf-manage2 config commands
@@ -348,14 +1193,25 @@ USAGE:
 
 COMMANDS:
     convert     Convert legacy .tfm.conf to .tfm.yaml format
+    migrate-check
+                Preview the .tfm.yaml convert would write, as a diff (writes nothing)
     init        Create a new configuration file (yaml|legacy)
     validate    Validate the current configuration
+    lint        Warn about deprecated or questionable settings (--error-on-warn to fail)
+    bump-version <target>
+                Migrate .tfm.yaml to a newer config_version
+    set <key> <value>
+                Update a string or boolean setting in .tfm.yaml
 
 EXAMPLES:
     tf config convert              # Convert .tfm.conf to .tfm.yaml
+    tf config migrate-check        # Preview the conversion without writing
     tf config init yaml           # Create new .tfm.yaml file
     tf config init legacy         # Create new .tfm.conf file
     tf config validate            # Check current configuration
+    tf config lint                # Show config warnings and fixes
+    tf config bump-version 2.0    # Upgrade config schema version
+    tf config set lock_timeout 5m # Change a single setting
 
 MIGRATION:
     The legacy .tfm.conf format is deprecated and will be removed in v3.0.
@@ -366,6 +1222,81 @@ For more information, see: https://github.com/sorinlg/tf-manage2
 	return nil
 }
 
+// loadConfig loads the --config file if given, otherwise the config discovered in --project-dir
+// or the enclosing git repository, and installs its output redaction patterns
+func loadConfig(opts *globalOptions) (*config.Config, error) {
+	var cfg *config.Config
+	var err error
+	switch {
+	case opts.ConfigFile != "":
+		cfg, err = config.LoadConfigFile(opts.ProjectDir, opts.ConfigFile)
+	case opts.ProjectDir != "":
+		cfg, err = config.LoadConfigFrom(opts.ProjectDir)
+	default:
+		cfg, err = config.LoadConfig()
+	}
+	if err != nil {
+		message := err.Error()
+		if hint := configErrorHint(err); hint != "" {
+			message += "\nHint: " + hint
+		}
+		return nil, terraform.NewExitCodeError(message, terraform.ExitValidationFailed)
+	}
+	if opts.WorkspacePrefix != "" {
+		cfg.WorkspacePrefix = opts.WorkspacePrefix
+	}
+	if cfg.ShadowedConfigPath != "" {
+		framework.Error(fmt.Sprintf("Both %s and %s exist; %s is authoritative and %s is ignored",
+			filepath.Base(cfg.ConfigPath), filepath.Base(cfg.ShadowedConfigPath), cfg.ConfigPath, cfg.ShadowedConfigPath))
+	}
+
+	if err := framework.SetRedactPatterns(cfg.RedactPatterns); err != nil {
+		return nil, terraform.NewExitCodeError(err.Error(), terraform.ExitValidationFailed)
+	}
+	return cfg, nil
+}
+
+// configErrorHint suggests how to fix a config loading failure
+func configErrorHint(err error) string {
+	switch {
+	case errors.Is(err, config.ErrNoProjectDir):
+		return "run tf from inside the git repository, or pass --project-dir <repo root>"
+	case errors.Is(err, config.ErrConfigNotFound):
+		if os.Getenv(config.ConfigURLEnv) != "" {
+			return fmt.Sprintf("check %s and %s, or unset %s to use the local config", config.ConfigURLEnv, config.ConfigTokenEnv, config.ConfigURLEnv)
+		}
+		return "create one with 'tf config init yaml', or point --config at an existing file"
+	case errors.Is(err, config.ErrInvalidConfig):
+		return "fix the file and check it with 'tf config validate'"
+	}
+	return ""
+}
+
+// ExitCode returns the process exit code for an error returned by Execute
+func ExitCode(err error) int {
+	var exitCodeErr *terraform.ExitCodeError
+	if errors.As(err, &exitCodeErr) && exitCodeErr.ExitCode != 0 {
+		return exitCodeErr.ExitCode
+	}
+	return 1
+}
+
+// resolveProjectDir returns the absolute --project-dir if given, or the enclosing git repository root
+func resolveProjectDir(opts *globalOptions) (string, error) {
+	if opts.ProjectDir == "" {
+		return findProjectDir()
+	}
+
+	dir, err := filepath.Abs(opts.ProjectDir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("project directory does not exist: %s", dir)
+	}
+	return dir, nil
+}
+
 // findProjectDir finds the git repository root directory
 // This is a duplicate of the function in config package to avoid circular imports
 func findProjectDir() (string, error) {
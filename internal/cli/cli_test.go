@@ -0,0 +1,580 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+	"github.com/sorinlg/tf-manage2/internal/terraform"
+	"github.com/sorinlg/tf-manage2/internal/testutil"
+)
+
+func TestParseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		vars       []string
+		projectDir string
+		configFile string
+		timeout    time.Duration
+		color      string
+		wantErr    bool
+	}{
+		{
+			name:       "No flags",
+			args:       []string{"product1", "sample_module", "dev", "instance_x", "plan"},
+			positional: []string{"product1", "sample_module", "dev", "instance_x", "plan"},
+		},
+		{
+			name:       "Multiple --set in order",
+			args:       []string{"product1", "sample_module", "dev", "instance_x", "plan", "--set", "a=1", "--set=b=2", "--set", "a=3"},
+			positional: []string{"product1", "sample_module", "dev", "instance_x", "plan"},
+			vars:       []string{"a=1", "b=2", "a=3"},
+		},
+		{
+			name:       "Flags before positional arguments",
+			args:       []string{"--set", "a=1", "product1", "sample_module", "dev", "instance_x", "apply"},
+			positional: []string{"product1", "sample_module", "dev", "instance_x", "apply"},
+			vars:       []string{"a=1"},
+		},
+		{
+			name:       "Project dir",
+			args:       []string{"--project-dir", "/srv/infra", "config", "validate"},
+			positional: []string{"config", "validate"},
+			projectDir: "/srv/infra",
+		},
+		{
+			name:       "Config file with project dir",
+			args:       []string{"--config=.tfm.prod.yaml", "--project-dir", "/srv/infra", "config", "validate"},
+			positional: []string{"config", "validate"},
+			projectDir: "/srv/infra",
+			configFile: ".tfm.prod.yaml",
+		},
+		{
+			name:       "Timeout",
+			args:       []string{"--timeout=45m", "product1", "sample_module", "dev", "instance_x", "apply"},
+			positional: []string{"product1", "sample_module", "dev", "instance_x", "apply"},
+			timeout:    45 * time.Minute,
+		},
+		{
+			name:    "Invalid timeout",
+			args:    []string{"--timeout", "soon"},
+			wantErr: true,
+		},
+		{
+			name:       "Color",
+			args:       []string{"--color=always", "product1", "sample_module", "dev", "instance_x", "plan"},
+			positional: []string{"product1", "sample_module", "dev", "instance_x", "plan"},
+			color:      "always",
+		},
+		{
+			name:    "Invalid color",
+			args:    []string{"--color", "yes"},
+			wantErr: true,
+		},
+		{
+			name:    "Empty out dir",
+			args:    []string{"--out-dir="},
+			wantErr: true,
+		},
+		{
+			name:    "Missing value",
+			args:    []string{"product1", "--set"},
+			wantErr: true,
+		},
+		{
+			name:    "Value without key",
+			args:    []string{"--set", "=1"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown flag",
+			args:    []string{"--bogus"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positional, opts, err := parseGlobalFlags(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseGlobalFlags(%v) expected error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGlobalFlags(%v) unexpected error: %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(positional, tt.positional) {
+				t.Errorf("positional = %v, want %v", positional, tt.positional)
+			}
+			if !reflect.DeepEqual(opts.Vars, tt.vars) {
+				t.Errorf("vars = %v, want %v", opts.Vars, tt.vars)
+			}
+			if opts.ProjectDir != tt.projectDir {
+				t.Errorf("projectDir = %q, want %q", opts.ProjectDir, tt.projectDir)
+			}
+			if opts.ConfigFile != tt.configFile {
+				t.Errorf("configFile = %q, want %q", opts.ConfigFile, tt.configFile)
+			}
+			if opts.Timeout != tt.timeout {
+				t.Errorf("timeout = %s, want %s", opts.Timeout, tt.timeout)
+			}
+			if opts.Color != tt.color {
+				t.Errorf("color = %q, want %q", opts.Color, tt.color)
+			}
+		})
+	}
+}
+
+func TestSkipValidationFlag(t *testing.T) {
+	if _, opts, _ := parseGlobalFlags([]string{"product1"}); opts.SkipValidation {
+		t.Error("SkipValidation should be off by default")
+	}
+	if _, opts, _ := parseGlobalFlags([]string{"--skip-validation", "product1"}); !opts.managerOptions().SkipValidation {
+		t.Error("--skip-validation did not reach the manager options")
+	}
+	t.Setenv("TFM_SKIP_VALIDATION", "1")
+	if _, opts, _ := parseGlobalFlags([]string{"product1"}); !opts.SkipValidation {
+		t.Error("TFM_SKIP_VALIDATION did not enable SkipValidation")
+	}
+}
+
+func TestActionFlagsFromEnv(t *testing.T) {
+	instance := []string{"product1", "sample_module", "dev", "instance_x"}
+
+	tests := []struct {
+		name   string
+		action string
+		env    string
+		want   string
+	}{
+		{"Env only", "plan", "-refresh=false  -lock=false", "-refresh=false -lock=false"},
+		{"Appended after command line flags", "plan -target=aws_s3_bucket.b", "-refresh=false", "-target=aws_s3_bucket.b -refresh=false"},
+		{"tf-manage flags are accepted", "plan --parallelism 5", "--replace aws_instance.web", "--parallelism 5 --replace aws_instance.web"},
+		{"Quoted values are kept as written", "plan", `-var 'tags={"a"="b"}'`, `-var 'tags={"a"="b"}'`},
+		{"Blank env is ignored", "apply -auto-approve", "  ", "-auto-approve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TFM_ACTION_FLAGS", tt.env)
+			cmd, err := parseCommand(append(instance, tt.action))
+			if err != nil {
+				t.Fatalf("parseCommand() unexpected error: %v", err)
+			}
+			if cmd.ActionFlags != tt.want {
+				t.Errorf("ActionFlags = %q, want %q", cmd.ActionFlags, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCommandAction(t *testing.T) {
+	instance := []string{"product1", "sample_module", "dev", "instance_x"}
+
+	cmd, err := parseCommand(append(instance, "plan -refresh=false"))
+	if err != nil {
+		t.Fatalf("parseCommand() unexpected error: %v", err)
+	}
+	if cmd.Action != "plan" || cmd.ActionFlags != "-refresh=false" {
+		t.Errorf("parseCommand() = %q %q, want plan -refresh=false", cmd.Action, cmd.ActionFlags)
+	}
+
+	_, err = parseCommand(append(instance, "aplly"))
+	if err == nil || !strings.Contains(err.Error(), "did you mean 'apply'?") {
+		t.Errorf("Expected a suggestion for an unknown action, got %v", err)
+	}
+	if code := ExitCode(err); code != terraform.ExitValidationFailed {
+		t.Errorf("ExitCode(unknown action) = %d, want %d", code, terraform.ExitValidationFailed)
+	}
+}
+
+func TestParseCommandContextFree(t *testing.T) {
+	tests := []struct {
+		args     []string
+		action   string
+		hostname string
+		wantErr  bool
+	}{
+		{[]string{"login"}, "login", "", false},
+		{[]string{"login", "tfe.example.com"}, "login", "tfe.example.com", false},
+		{[]string{"logout", "app.terraform.io"}, "logout", "app.terraform.io", false},
+		{[]string{"login", "a", "b"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		cmd, err := parseCommand(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCommand(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if cmd.Action != tt.action || cmd.ActionFlags != tt.hostname || cmd.Product != "" {
+			t.Errorf("parseCommand(%v) = %+v, want action %q hostname %q", tt.args, cmd, tt.action, tt.hostname)
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	positional, opts, err := parseGlobalFlags([]string{"--quiet", "clean", "--dry-run", "product1", "sample_module", "--lock-file"})
+	if err != nil {
+		t.Fatalf("parseGlobalFlags() unexpected error: %v", err)
+	}
+	if want := []string{"clean", "--dry-run", "product1", "sample_module", "--lock-file"}; !reflect.DeepEqual(positional, want) || !opts.Quiet {
+		t.Errorf("positional = %v (quiet %v), want %v", positional, opts.Quiet, want)
+	}
+
+	// Command flags are only accepted after their command
+	if _, _, err := parseGlobalFlags([]string{"product1", "sample_module", "dev", "instance_x", "plan", "--dry-run"}); err == nil {
+		t.Error("Expected --dry-run to be rejected outside clean")
+	}
+
+	// A command's own flag wins over the global flag of the same name
+	positional, opts, err = parseGlobalFlags([]string{"inspect", "product1", "sample_module", "dev", "instance_x", "--json"})
+	if err != nil || opts.JSON || positional[len(positional)-1] != "--json" {
+		t.Errorf("inspect --json = %v (global json %v, err %v), want it passed to inspect", positional, opts.JSON, err)
+	}
+	if _, opts, err = parseGlobalFlags([]string{"--json", "inspect"}); err != nil || !opts.JSON {
+		t.Errorf("--json before the command should stay global (json %v, err %v)", opts.JSON, err)
+	}
+
+	// Value flags of a command keep their value
+	positional, _, err = parseGlobalFlags([]string{"drift", "--changed-only", "--base", "origin/main", "product1", "sample_module", "dev"})
+	want := []string{"drift", "--changed-only", "--base", "origin/main", "product1", "sample_module", "dev"}
+	if err != nil || !reflect.DeepEqual(positional, want) {
+		t.Errorf("drift flags = %v (err %v), want %v", positional, err, want)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if code := ExitCode(errors.New("boom")); code != 1 {
+		t.Errorf("ExitCode(plain error) = %d, want 1", code)
+	}
+	if code := ExitCode(fmt.Errorf("wrapped: %w", terraform.NewExitCodeError("failed", 3))); code != 3 {
+		t.Errorf("ExitCode(wrapped terraform error) = %d, want 3", code)
+	}
+
+	// A missing config is a validation failure
+	_, err := loadConfig(&globalOptions{ProjectDir: t.TempDir()})
+	if code := ExitCode(err); code != terraform.ExitValidationFailed {
+		t.Errorf("ExitCode(missing config) = %d, want %d", code, terraform.ExitValidationFailed)
+	}
+}
+
+// fakeProject creates the shared test project and runs from its root, as operators do
+func fakeProject(t *testing.T, exitCode int) string {
+	t.Helper()
+
+	projectDir := testutil.FakeProject(t, exitCode)
+	t.Chdir(projectDir)
+	return projectDir
+}
+
+func TestRun(t *testing.T) {
+	instance := []string{"product1", "sample_module", "dev", "instance_x"}
+
+	tests := []struct {
+		name     string
+		args     []string
+		tfExit   int
+		wantCode int
+		wantErr  bool
+	}{
+		{"Help", []string{"--help"}, 0, 0, false},
+		{"Version", []string{"--version"}, 0, 0, false},
+		{"Invalid global flag", []string{"--timeout", "soon"}, 0, 1, true},
+		{"Invalid color mode", []string{"--color", "sometimes"}, 0, 1, true},
+		{"Successful plan", append(instance, "plan"), 0, 0, false},
+		{"Terraform failure keeps its exit code", append(instance, "plan"), 3, 3, false},
+		{"Unknown product", []string{"no_such_product", "sample_module", "dev", "instance_x", "plan"}, 0, terraform.ExitValidationFailed, false},
+		{"Drift usage error", []string{"drift", "product1"}, 0, 1, true},
+		{"Omitted instance in unattended mode", []string{"product1", "sample_module", "dev", "plan"}, 0, 1, true},
+		{"Invalid workspace prefix", append([]string{"--workspace-prefix", "team.a"}, append(instance, "plan")...), 0, terraform.ExitValidationFailed, true},
+		{"Missing instance", []string{"product1", "sample_module", "dev", "instance_y", "destroy"}, 0, terraform.ExitValidationFailed, false},
+		{"Missing instance with --skip-if-missing", []string{"--skip-if-missing", "product1", "sample_module", "dev", "instance_y", "destroy"}, 3, 0, false},
+		{"Present instance with --skip-if-missing runs", append([]string{"--skip-if-missing"}, append(instance, "destroy")...), 3, 3, false},
+		{"Unwritable log file", append([]string{"--log-file", "/nonexistent/dir/run.log"}, append(instance, "plan")...), 0, terraform.ExitValidationFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectDir := fakeProject(t, tt.tfExit)
+			args := append([]string{"--quiet", "--project-dir", projectDir}, tt.args...)
+
+			code, err := Run(args)
+			if code != tt.wantCode {
+				t.Errorf("Run() code = %d, want %d", code, tt.wantCode)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Missing config", func(t *testing.T) {
+		code, err := Run([]string{"--quiet", "--project-dir", t.TempDir(), "product1", "sample_module", "dev", "instance_x", "plan"})
+		if code != terraform.ExitValidationFailed || err == nil {
+			t.Errorf("Run() = %d, %v; want %d with an error", code, err, terraform.ExitValidationFailed)
+		}
+	})
+}
+
+func TestWorkspacePrefixOverride(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	if err := os.WriteFile(filepath.Join(projectDir, ".tfm.yaml"), []byte("repo_name: test-repo\nworkspace_prefix: team-a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := loadConfig(&globalOptions{ProjectDir: projectDir})
+	if err != nil || cfg.WorkspacePrefix != "team-a" {
+		t.Fatalf("loadConfig() prefix = %q, %v; want team-a", cfg.WorkspacePrefix, err)
+	}
+
+	cfg, err = loadConfig(&globalOptions{ProjectDir: projectDir, WorkspacePrefix: "ci"})
+	if err != nil || cfg.WorkspacePrefix != "ci" {
+		t.Errorf("loadConfig() prefix = %q, %v; want the --workspace-prefix value ci", cfg.WorkspacePrefix, err)
+	}
+}
+
+func TestConfigErrorHint(t *testing.T) {
+	t.Setenv(config.ConfigURLEnv, "")
+	tests := []struct {
+		name string
+		opts *globalOptions
+		want string
+	}{
+		{"No project directory", &globalOptions{ProjectDir: filepath.Join(t.TempDir(), "missing")}, "--project-dir"},
+		{"No config file", &globalOptions{ProjectDir: t.TempDir()}, "tf config init yaml"},
+		{"Invalid config", &globalOptions{ProjectDir: t.TempDir(), ConfigFile: "bad.yaml"}, "tf config validate"},
+	}
+	if err := os.WriteFile(filepath.Join(tests[2].opts.ProjectDir, "bad.yaml"), []byte("repo_name: [\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(tt.opts)
+			if err == nil || !strings.Contains(err.Error(), "\nHint: ") || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadConfig() error = %v, want a hint mentioning %q", err, tt.want)
+			}
+			if code := ExitCode(err); code != terraform.ExitValidationFailed {
+				t.Errorf("ExitCode() = %d, want %d", code, terraform.ExitValidationFailed)
+			}
+		})
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+	projectDir := fakeProject(t, 2)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = w
+	code, runErr := Run([]string{"--json", "--project-dir", projectDir, "product1", "sample_module", "dev", "instance_x", "plan"})
+	os.Stdout = originalStdout
+	w.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read stdout: %v", err)
+	}
+	if code != 2 || runErr != nil {
+		t.Errorf("Run() = %d, %v; want 2, nil", code, runErr)
+	}
+
+	// stdout must hold exactly one JSON object and nothing else
+	var result map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&result); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, data)
+	}
+	if decoder.More() || strings.Contains(string(data), "fake terraform") || strings.Contains(string(data), "Detected exec mode") {
+		t.Errorf("Human output leaked into stdout:\n%s", data)
+	}
+
+	want := map[string]any{
+		"action":            "plan",
+		"workspace":         "product1.test-repo.sample_module.dev.instance_x",
+		"exec_mode":         "unattended",
+		"terraform_version": "unknown",
+		"exit_code":         float64(2),
+	}
+	for key, value := range want {
+		if result[key] != value {
+			t.Errorf("%s = %v, want %v", key, result[key], value)
+		}
+	}
+	if duration, ok := result["duration"].(string); !ok || duration == "" {
+		t.Errorf("Expected a duration, got %v", result["duration"])
+	}
+}
+
+func TestRunJSONMaskIdentifiers(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	configPath := filepath.Join(projectDir, ".tfm.yaml")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if err := os.WriteFile(configPath, append(data, "mask_identifiers: true\n"...), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Cleanup(framework.ClearMasks)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = w
+	Run([]string{"--json", "--project-dir", projectDir, "product1", "sample_module", "dev", "instance_x", "plan"})
+	os.Stdout = originalStdout
+	w.Close()
+
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read stdout: %v", err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("stdout is not JSON: %v\n%s", err, output)
+	}
+	workspace, _ := result["workspace"].(string)
+	if workspace == "" || strings.Contains(workspace, "product1") || strings.Contains(workspace, "instance_x") {
+		t.Errorf("Expected a masked workspace in the JSON result, got %q", workspace)
+	}
+}
+
+func TestEnvPattern(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	for _, env := range []string{"prod/us-east-1", "prod/eu-west-1"} {
+		path := filepath.Join(projectDir, "terraform/environments/product1", env, "sample_module/instance_x.tfvars")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte("instance_count = 1\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// Record the workspace every plan runs in
+	logPath := filepath.Join(t.TempDir(), "plans.log")
+	selectedPath := filepath.Join(t.TempDir(), "selected")
+	binDir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nif [ \"$1 $2\" = \"workspace select\" ]; then echo \"$3\" > %q; fi\nif [ \"$1\" = plan ]; then cat %q >> %q; fi\nexit 0\n", selectedPath, selectedPath, logPath)
+	if err := os.WriteFile(filepath.Join(binDir, "terraform"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake terraform: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	code, err := Run([]string{"--quiet", "--project-dir", projectDir, "product1", "sample_module", "prod/*", "instance_x", "plan"})
+	if code != 0 || err != nil {
+		t.Fatalf("Run() = %d, %v; want success", code, err)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Expected plans to run: %v", err)
+	}
+	want := "product1.test-repo.sample_module.prod__eu-west-1.instance_x\nproduct1.test-repo.sample_module.prod__us-east-1.instance_x\n"
+	if string(data) != want {
+		t.Errorf("plans ran in\n%s\nwant\n%s", data, want)
+	}
+
+	code, _ = Run([]string{"--quiet", "--project-dir", projectDir, "product1", "sample_module", "qa/*", "instance_x", "plan"})
+	if code != terraform.ExitValidationFailed {
+		t.Errorf("Run() with an unmatched env pattern = %d, want %d", code, terraform.ExitValidationFailed)
+	}
+}
+
+func TestCompleteActionsDescribe(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	output := captureOutput(t, func() {
+		if code, err := Run([]string{"--project-dir", projectDir, "__complete", "actions", "--describe"}); code != 0 || err != nil {
+			t.Errorf("Run() = %d, %v", code, err)
+		}
+	})
+	if !strings.HasPrefix(output, "init\t") {
+		t.Errorf("Expected described actions, got %q", output)
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	configPath := filepath.Join(projectDir, ".tfm.yaml")
+
+	captureOutput(t, func() {
+		if code, err := Run([]string{"--project-dir", projectDir, "config", "set", "lock_timeout", "5m"}); code != 0 || err != nil {
+			t.Errorf("Run() = %d, %v", code, err)
+		}
+	})
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "# tf-manage2 configuration file") {
+		t.Errorf("Expected the header to be kept, got:\n%s", data)
+	}
+	for _, want := range []string{"repo_name: test-repo", "lock_timeout: 5m"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in the config, got:\n%s", want, data)
+		}
+	}
+
+	code, err := Run([]string{"--project-dir", projectDir, "config", "set", "lock_timout", "5m"})
+	if code == 0 || err == nil || !strings.Contains(err.Error(), "unknown config key") {
+		t.Errorf("Run() with an unknown key = %d, %v; want an unknown key error", code, err)
+	}
+	if after, _ := os.ReadFile(configPath); string(after) != string(data) {
+		t.Errorf("Expected a refused key to leave the config unchanged, got:\n%s", after)
+	}
+}
+
+func TestShadowedLegacyConfig(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	legacy := "export __tfm_repo_name='legacy-repo'\nexport __tfm_env_rel_path='terraform/environments'\nexport __tfm_module_rel_path='terraform/modules'\n"
+	if err := os.WriteFile(filepath.Join(projectDir, ".tfm.conf"), []byte(legacy), 0644); err != nil {
+		t.Fatalf("Failed to write .tfm.conf: %v", err)
+	}
+
+	stderrFile, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create stderr file: %v", err)
+	}
+	originalStderr := os.Stderr
+	os.Stderr = stderrFile
+	output := captureOutput(t, func() {
+		if code, err := Run([]string{"--project-dir", projectDir, "config", "validate"}); code != 0 || err != nil {
+			t.Errorf("Run() = %d, %v", code, err)
+		}
+	})
+	os.Stderr = originalStderr
+	stderrFile.Close()
+	warning, _ := os.ReadFile(stderrFile.Name())
+
+	if want := filepath.Join(projectDir, ".tfm.yaml") + " is authoritative"; !strings.Contains(string(warning), want) {
+		t.Errorf("Expected a precedence warning, got %q", warning)
+	}
+	if !strings.Contains(output, "Repository:  test-repo") {
+		t.Errorf("Expected .tfm.yaml to win, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Shadowed:    "+filepath.Join(projectDir, ".tfm.conf")) {
+		t.Errorf("Expected validate to note the shadowed file, got:\n%s", output)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/terraform"
 )
 
 // Completion provides bash completion functionality
@@ -110,34 +112,47 @@ func (c *Completion) SuggestEnvironments(product, module string) error {
 
 // SuggestConfigs lists available configuration files for a given product, env, and module
 func (c *Completion) SuggestConfigs(product, env, module string) error {
+	configs, err := c.configs(product, env, module)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		fmt.Println(config)
+	}
+	return nil
+}
+
+// configs returns the instance names configured for a given product, env, and module
+func (c *Completion) configs(product, env, module string) ([]string, error) {
 	// First check if the product exists
 	productPath := filepath.Join(c.config.GetEnvPath(), product)
 	if _, err := os.Stat(productPath); os.IsNotExist(err) {
-		return fmt.Errorf("product path does not exist: %s", productPath)
+		return nil, fmt.Errorf("product path does not exist: %s", productPath)
 	}
 
 	// Then check if the environment exists
 	envPath := filepath.Join(productPath, env)
 	if _, err := os.Stat(envPath); os.IsNotExist(err) {
-		return fmt.Errorf("environment path does not exist: %s", envPath)
+		return nil, fmt.Errorf("environment path does not exist: %s", envPath)
 	}
 
 	// Then check if the module exists
 	modulePath := filepath.Join(c.config.GetModulePath(), module)
 	if _, err := os.Stat(modulePath); os.IsNotExist(err) {
-		return fmt.Errorf("module path does not exist: %s", modulePath)
+		return nil, fmt.Errorf("module path does not exist: %s", modulePath)
 	}
 
 	configPath := filepath.Join(c.config.GetEnvPath(), product, env, module)
 
 	entries, err := os.ReadDir(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config directory: %s", configPath)
+		return nil, fmt.Errorf("failed to read config directory: %s", configPath)
 	}
 
-	// Filter for .tfvars files and exclude .tfplan files
+	// Filter for .tfvars and .tfvars.json files and exclude .tfplan files
 	var configs []string
-	tfvarsRegex := regexp.MustCompile(`^(.+)\.tfvars$`)
+	tfvarsRegex := regexp.MustCompile(`^(.+)\.tfvars(\.json)?$`)
 
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -149,32 +164,27 @@ func (c *Completion) SuggestConfigs(product, env, module string) error {
 			continue
 		}
 
-		// Match .tfvars files
-		if matches := tfvarsRegex.FindStringSubmatch(entry.Name()); matches != nil {
-			configs = append(configs, matches[1]) // Return without .tfvars extension
+		// Match .tfvars files, listing an instance with both variants once
+		if matches := tfvarsRegex.FindStringSubmatch(entry.Name()); matches != nil && !slices.Contains(configs, matches[1]) {
+			configs = append(configs, matches[1]) // Return without the extension
 		}
 	}
 
 	if len(configs) == 0 {
-		return fmt.Errorf("no config files found in: %s", configPath)
+		return nil, fmt.Errorf("no config files found in: %s", configPath)
 	}
-
-	for _, config := range configs {
-		fmt.Println(config)
-	}
-	return nil
+	return configs, nil
 }
 
-// SuggestActions lists available terraform actions
-func (c *Completion) SuggestActions() error {
-	actions := []string{
-		"init", "plan", "apply", "apply_plan", "destroy", "output",
-		"get", "workspace", "providers", "import", "taint", "untaint",
-		"state", "refresh", "validate", "fmt", "format", "show",
-	}
-
-	for _, action := range actions {
-		fmt.Println(action)
+// SuggestActions lists available terraform actions. With describe, each line is the action
+// and its description separated by a tab, for shells that show descriptions.
+func (c *Completion) SuggestActions(describe bool) error {
+	for _, action := range terraform.SupportedActions() {
+		if describe {
+			fmt.Printf("%s\t%s\n", action.Name, action.Description)
+		} else {
+			fmt.Println(action.Name)
+		}
 	}
 	return nil
 }
@@ -196,7 +206,7 @@ func (c *Completion) SuggestRepo() error {
 // SuggestConfigCommands lists available config subcommands
 func (c *Completion) SuggestConfigCommands() error {
 	commands := []string{
-		"convert", "init", "validate",
+		"convert", "migrate-check", "init", "validate", "lint", "bump-version", "set",
 	}
 
 	for _, cmd := range commands {
@@ -205,6 +215,14 @@ func (c *Completion) SuggestConfigCommands() error {
 	return nil
 }
 
+// SuggestConfigKeys lists the keys accepted by config set
+func (c *Completion) SuggestConfigKeys() error {
+	for _, key := range config.SettableKeys() {
+		fmt.Println(key)
+	}
+	return nil
+}
+
 // SuggestConfigInitFormats lists available config init formats
 func (c *Completion) SuggestConfigInitFormats() error {
 	formats := []string{
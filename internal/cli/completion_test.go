@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/terraform"
 )
 
 // TestCompletion tests the completion functionality
@@ -36,6 +37,8 @@ func TestCompletion(t *testing.T) {
 	testFiles := []string{
 		"terraform/environments/product1/dev/sample_module/instance_x.tfvars",
 		"terraform/environments/product1/dev/sample_module/instance_y.tfvars",
+		"terraform/environments/product1/dev/sample_module/instance_y.tfvars.json",
+		"terraform/environments/product1/dev/sample_module/instance_z.tfvars.json",
 		"terraform/environments/product1/staging/sample_module/staging_instance.tfvars",
 		"terraform/environments/product2/prod/another_module/prod_instance.tfvars",
 	}
@@ -159,18 +162,17 @@ export __tfm_module_rel_path='terraform/modules'
 			}
 		})
 
-		expected := []string{"instance_x", "instance_y"}
-		for _, exp := range expected {
-			if !strings.Contains(output, exp) {
-				t.Errorf("Expected config %s not found in output: %s", exp, output)
-			}
+		// JSON configs are listed without their extension, and only once per instance
+		expected := "instance_x\ninstance_y\ninstance_z\n"
+		if output != expected {
+			t.Errorf("SuggestConfigs output = %q, want %q", output, expected)
 		}
 	})
 
 	// Test actions completion
 	t.Run("SuggestActions", func(t *testing.T) {
 		output := captureOutput(t, func() {
-			err := completion.SuggestActions()
+			err := completion.SuggestActions(false)
 			if err != nil {
 				t.Errorf("SuggestActions failed: %v", err)
 			}
@@ -183,6 +185,28 @@ export __tfm_module_rel_path='terraform/modules'
 			}
 		}
 	})
+
+	t.Run("SuggestActions with descriptions", func(t *testing.T) {
+		output := captureOutput(t, func() {
+			if err := completion.SuggestActions(true); err != nil {
+				t.Errorf("SuggestActions failed: %v", err)
+			}
+		})
+
+		lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+		if len(lines) != len(terraform.SupportedActions()) {
+			t.Fatalf("Expected one line per action, got %q", output)
+		}
+		for _, line := range lines {
+			name, description, ok := strings.Cut(line, "\t")
+			if !ok || !terraform.IsValidAction(name) || description == "" {
+				t.Errorf("Expected an action and description separated by a tab, got %q", line)
+			}
+		}
+		if !strings.Contains(output, "apply_plan\tApply the saved plan\n") {
+			t.Errorf("Missing apply_plan description in %q", output)
+		}
+	})
 }
 
 // captureOutput captures stdout during function execution
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+	"github.com/sorinlg/tf-manage2/internal/terraform"
+)
+
+// pickerStdin is where the instance picker reads the operator's choice (replaced in tests)
+var pickerStdin io.Reader = os.Stdin
+
+// omitsInstance reports whether args are `<product> <module> <env> <action>`, without the instance
+func omitsInstance(args []string) bool {
+	if len(args) != 4 {
+		return false
+	}
+	action := strings.Fields(args[3])
+	return len(action) > 0 && terraform.ValidateAction(action[0]) == nil
+}
+
+// pickInstance lists the instances of product/env/module and asks the operator to choose one
+// by number. Unattended runs cannot answer a prompt, so they must name the instance.
+func pickInstance(cfg *config.Config, unattended bool, product, module, env string) (string, error) {
+	if unattended {
+		return "", fmt.Errorf("the module instance is required in unattended mode: tf %s %s %s <module_instance> <action>", product, module, env)
+	}
+
+	instances, err := NewCompletion(cfg).configs(product, env, module)
+	if err != nil {
+		return "", err
+	}
+
+	framework.Info(fmt.Sprintf("Instances of %s in %s/%s:", framework.AddEmphasisBlue(module), product, env))
+	for i, instance := range instances {
+		fmt.Fprintf(framework.Stderr(), "  %d) %s\n", i+1, instance)
+	}
+	framework.Prompt(fmt.Sprintf("Choose an instance [1-%d]:", len(instances)))
+
+	answer, err := bufio.NewReader(pickerStdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(framework.Stderr())
+		return "", fmt.Errorf("no instance chosen")
+	}
+	return parseInstanceChoice(answer, instances)
+}
+
+// parseInstanceChoice resolves the operator's answer, a 1-based number or an instance name
+func parseInstanceChoice(answer string, instances []string) (string, error) {
+	answer = strings.TrimSpace(answer)
+	if slices.Contains(instances, answer) {
+		return answer, nil
+	}
+
+	choice, err := strconv.Atoi(answer)
+	if err != nil || choice < 1 || choice > len(instances) {
+		return "", fmt.Errorf("invalid choice %q: enter a number between 1 and %d", answer, len(instances))
+	}
+	return instances[choice-1], nil
+}
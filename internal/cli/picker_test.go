@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+)
+
+func TestOmitsInstance(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"product1", "sample_module", "dev", "plan"}, true},
+		{[]string{"product1", "sample_module", "dev", "plan -target=aws_s3_bucket.b"}, true},
+		{[]string{"product1", "sample_module", "dev", "instance_x"}, false},
+		{[]string{"product1", "sample_module", "dev", "instance_x", "plan"}, false},
+	}
+	for _, tt := range tests {
+		if got := omitsInstance(tt.args); got != tt.want {
+			t.Errorf("omitsInstance(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseInstanceChoice(t *testing.T) {
+	instances := []string{"instance_x", "instance_y"}
+	tests := []struct {
+		answer  string
+		want    string
+		wantErr bool
+	}{
+		{"1\n", "instance_x", false},
+		{" 2 \n", "instance_y", false},
+		{"instance_y\n", "instance_y", false},
+		{"0\n", "", true},
+		{"3\n", "", true},
+		{"\n", "", true},
+		{"instance_z\n", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseInstanceChoice(tt.answer, instances)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseInstanceChoice(%q) = %q, %v; want %q (error %v)", tt.answer, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPickInstance(t *testing.T) {
+	projectDir := fakeProject(t, 0)
+	cfg := &config.Config{
+		ProjectDir:    projectDir,
+		RepoName:      "test-repo",
+		EnvRelPath:    "terraform/environments",
+		ModuleRelPath: "terraform/modules",
+	}
+
+	pick := func(t *testing.T, input string, unattended bool) (string, error) {
+		t.Helper()
+		original := pickerStdin
+		pickerStdin = strings.NewReader(input)
+		t.Cleanup(func() { pickerStdin = original })
+		return pickInstance(cfg, unattended, "product1", "sample_module", "dev")
+	}
+
+	if instance, err := pick(t, "1\n", false); err != nil || instance != "instance_x" {
+		t.Errorf("pickInstance() = %q, %v; want instance_x", instance, err)
+	}
+	if _, err := pick(t, "", false); err == nil {
+		t.Error("Expected an error when stdin is closed")
+	}
+	if _, err := pick(t, "1\n", true); err == nil || !strings.Contains(err.Error(), "unattended") {
+		t.Errorf("Expected unattended mode to require the instance, got %v", err)
+	}
+}
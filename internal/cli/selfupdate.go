@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sorinlg/tf-manage2/internal/terraform"
+)
+
+// releaseDownloadTimeout bounds downloading a release archive
+const releaseDownloadTimeout = 2 * time.Minute
+
+// maxReleaseAssetSize caps how much of a release asset is read
+const maxReleaseAssetSize = 100 << 20
+
+// releaseBinaryName is the binary inside each release archive (see .goreleaser.yaml)
+const releaseBinaryName = "tf"
+
+// Seams replaced in tests
+var (
+	executablePath           = os.Executable
+	updateStdin    io.Reader = os.Stdin
+)
+
+// releaseAsset is a file attached to a GitHub release
+type releaseAsset struct {
+	Name        string `json:"name"`
+	DownloadURL string `json:"browser_download_url"`
+}
+
+// archiveName returns the release archive name for an OS and architecture,
+// following the archives name_template in .goreleaser.yaml
+func archiveName(goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	}
+	osName := goos
+	if osName != "" {
+		osName = strings.ToUpper(osName[:1]) + osName[1:]
+	}
+	return fmt.Sprintf("tf-manage2_%s_%s.tar.gz", osName, arch)
+}
+
+// selectAsset returns the release asset called name
+func selectAsset(latest *release, name string) (*releaseAsset, error) {
+	for i := range latest.Assets {
+		if latest.Assets[i].Name == name {
+			return &latest.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no %s asset", latest.TagName, name)
+}
+
+// updateAvailable reports whether latest is newer than the running version.
+// Development builds cannot be compared and are never replaced.
+func updateAvailable(current, latest string) (bool, error) {
+	cmp, err := terraform.CompareVersions(current, latest)
+	if err != nil {
+		return false, fmt.Errorf("cannot compare version %s with %s: self-update needs a release build", current, latest)
+	}
+	return cmp < 0, nil
+}
+
+// handleSelfUpdate replaces the running binary with the latest release for this platform
+func handleSelfUpdate(args []string) error {
+	checkOnly, yes := false, false
+	for _, arg := range args {
+		switch arg {
+		case "--check-only":
+			checkOnly = true
+		case "--yes":
+			yes = true
+		default:
+			return fmt.Errorf("usage: tf self-update [--check-only] [--yes]")
+		}
+	}
+
+	latest, err := latestRelease()
+	if err != nil {
+		return fmt.Errorf("could not look up the latest release: %w", err)
+	}
+	newer, err := updateAvailable(version, latest.TagName)
+	if err != nil {
+		return err
+	}
+	if !newer {
+		fmt.Printf("tf-manage2 %s is up to date (latest release %s)\n", version, latest.TagName)
+		return nil
+	}
+	fmt.Printf("Update available: %s (running %s)\n", latest.TagName, version)
+	if checkOnly {
+		return nil
+	}
+
+	target, err := updateTarget()
+	if err != nil {
+		return err
+	}
+
+	asset, err := selectAsset(latest, archiveName(runtime.GOOS, runtime.GOARCH))
+	if err != nil {
+		return err
+	}
+	checksums, err := selectAsset(latest, "checksums.txt")
+	if err != nil {
+		return fmt.Errorf("refusing to update without checksums: %w", err)
+	}
+
+	if !yes && !confirmUpdate(target, latest.TagName) {
+		return fmt.Errorf("self-update aborted")
+	}
+
+	binary, err := downloadVerifiedBinary(asset, checksums)
+	if err != nil {
+		return err
+	}
+	if err := replaceExecutable(target, binary); err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Updated %s to %s (checksum verified)\n", target, latest.TagName)
+	return nil
+}
+
+// updateTarget returns the path of the running binary, refusing installs a package manager owns
+// or the current user cannot write to
+func updateTarget() (string, error) {
+	path, err := executablePath()
+	if err != nil {
+		return "", fmt.Errorf("could not locate the running binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	if strings.Contains(path, "/Cellar/") || strings.Contains(path, "/homebrew/") {
+		return "", fmt.Errorf("%s is managed by Homebrew; run 'brew upgrade tf-manage2' instead", path)
+	}
+
+	probe, err := os.CreateTemp(filepath.Dir(path), ".tf-manage2-update-*")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable (installed by a package manager?); update it the way it was installed", filepath.Dir(path))
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	return path, nil
+}
+
+// confirmUpdate asks the operator before replacing the binary
+func confirmUpdate(target, tag string) bool {
+	fmt.Printf("Replace %s with %s? [y/N] ", target, tag)
+	answer, err := bufio.NewReader(updateStdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// downloadVerifiedBinary downloads a release archive, checks it against the release
+// checksums and returns the tf binary inside it
+func downloadVerifiedBinary(asset, checksums *releaseAsset) ([]byte, error) {
+	sums, err := download(checksums.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	want, err := checksumFor(sums, asset.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := download(asset.DownloadURL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(archive)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", asset.Name, got, want)
+	}
+
+	return extractBinary(archive)
+}
+
+// download fetches url into memory
+func download(url string) ([]byte, error) {
+	client := &http.Client{Timeout: releaseDownloadTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download of %s failed: %s", url, response.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxReleaseAssetSize))
+	if err != nil {
+		return nil, fmt.Errorf("download of %s failed: %w", url, err)
+	}
+	return data, nil
+}
+
+// checksumFor finds name in a checksums.txt listing ("<sha256>  <name>" per line)
+func checksumFor(sums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", name)
+}
+
+// extractBinary returns the tf binary from a release tar.gz archive
+func extractBinary(archive []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("invalid release archive: %w", err)
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("release archive has no %s binary", releaseBinaryName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid release archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == releaseBinaryName {
+			return io.ReadAll(io.LimitReader(reader, maxReleaseAssetSize))
+		}
+	}
+}
+
+// replaceExecutable swaps target for binary with a rename, so the old binary stays
+// intact if anything fails
+func replaceExecutable(target string, binary []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tf-manage2-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to stage the new binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to stage the new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to stage the new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return fmt.Errorf("failed to stage the new binary: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), target); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		goos, goarch, want string
+	}{
+		{"linux", "amd64", "tf-manage2_Linux_x86_64.tar.gz"},
+		{"linux", "arm64", "tf-manage2_Linux_arm64.tar.gz"},
+		{"darwin", "amd64", "tf-manage2_Darwin_x86_64.tar.gz"},
+		{"darwin", "arm64", "tf-manage2_Darwin_arm64.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := archiveName(tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("archiveName(%s, %s) = %s, want %s", tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+
+	latest := &release{TagName: "v1.4.0", Assets: []releaseAsset{
+		{Name: "checksums.txt"},
+		{Name: "tf-manage2_Darwin_arm64.tar.gz", DownloadURL: "https://example.com/darwin"},
+		{Name: "tf-manage2_Linux_x86_64.tar.gz", DownloadURL: "https://example.com/linux"},
+	}}
+	asset, err := selectAsset(latest, archiveName("linux", "amd64"))
+	if err != nil || asset.DownloadURL != "https://example.com/linux" {
+		t.Errorf("selectAsset(linux/amd64) = %+v, %v", asset, err)
+	}
+	if _, err := selectAsset(latest, archiveName("linux", "arm64")); err == nil {
+		t.Error("Expected an error for a platform without an asset")
+	}
+}
+
+func TestUpdateAvailable(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+		wantErr         bool
+	}{
+		{"1.2.3", "v1.4.0", true, false},
+		{"1.4.0", "v1.4.0", false, false},
+		{"1.4.0-rc1", "v1.4.0", true, false},
+		{"1.5.0", "v1.4.0", false, false},
+		{"dev", "v1.4.0", false, true},
+	}
+	for _, tt := range tests {
+		got, err := updateAvailable(tt.current, tt.latest)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("updateAvailable(%s, %s) = %v, %v; want %v (error %v)", tt.current, tt.latest, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSelfUpdate(t *testing.T) {
+	// Build a release archive holding the new binary
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	newBinary := []byte("#!/bin/sh\necho new\n")
+	tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: 2, Typeflag: tar.TypeReg})
+	tw.Write([]byte("hi"))
+	tw.WriteHeader(&tar.Header{Name: "tf", Mode: 0755, Size: int64(len(newBinary)), Typeflag: tar.TypeReg})
+	tw.Write(newBinary)
+	tw.Close()
+	gz.Close()
+
+	assetName := archiveName(runtime.GOOS, runtime.GOARCH)
+	sum := sha256.Sum256(archive.Bytes())
+	checksum := hex.EncodeToString(sum[:])
+
+	// fakeRelease serves the release, its archive and checksums.txt
+	fakeRelease := func(t *testing.T, checksums string) {
+		t.Helper()
+		mux := http.NewServeMux()
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(release{TagName: "v9.0.0", Assets: []releaseAsset{
+				{Name: assetName, DownloadURL: server.URL + "/archive"},
+				{Name: "checksums.txt", DownloadURL: server.URL + "/checksums"},
+			}})
+		})
+		mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) { w.Write(archive.Bytes()) })
+		mux.HandleFunc("/checksums", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(checksums)) })
+
+		originalURL, originalVersion := latestReleaseURL, version
+		latestReleaseURL, version = server.URL+"/latest", "1.0.0"
+		t.Cleanup(func() { latestReleaseURL, version = originalURL, originalVersion })
+	}
+
+	// fakeExecutable installs an old binary for self-update to replace
+	fakeExecutable := func(t *testing.T) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "tf")
+		if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+			t.Fatalf("Failed to write executable: %v", err)
+		}
+		original := executablePath
+		executablePath = func() (string, error) { return path, nil }
+		t.Cleanup(func() { executablePath = original })
+		return path
+	}
+
+	readFile := func(t *testing.T, path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		return string(data)
+	}
+
+	t.Run("Check only", func(t *testing.T) {
+		fakeRelease(t, checksum+"  "+assetName+"\n")
+		path := fakeExecutable(t)
+		if err := handleSelfUpdate([]string{"--check-only"}); err != nil {
+			t.Fatalf("handleSelfUpdate failed: %v", err)
+		}
+		if readFile(t, path) != "old" {
+			t.Error("--check-only must not replace the binary")
+		}
+	})
+
+	t.Run("Declined confirmation", func(t *testing.T) {
+		fakeRelease(t, checksum+"  "+assetName+"\n")
+		path := fakeExecutable(t)
+		original := updateStdin
+		updateStdin = strings.NewReader("n\n")
+		t.Cleanup(func() { updateStdin = original })
+
+		if err := handleSelfUpdate(nil); err == nil {
+			t.Error("Expected the update to abort")
+		}
+		if readFile(t, path) != "old" {
+			t.Error("A declined update must not replace the binary")
+		}
+	})
+
+	t.Run("Verified update", func(t *testing.T) {
+		fakeRelease(t, "deadbeef  other.tar.gz\n"+checksum+"  "+assetName+"\n")
+		path := fakeExecutable(t)
+		if err := handleSelfUpdate([]string{"--yes"}); err != nil {
+			t.Fatalf("handleSelfUpdate failed: %v", err)
+		}
+		if readFile(t, path) != string(newBinary) {
+			t.Error("Expected the binary to be replaced")
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0755 {
+			t.Errorf("Expected an executable binary, got %v", info.Mode())
+		}
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		fakeRelease(t, strings.Repeat("0", 64)+"  "+assetName+"\n")
+		path := fakeExecutable(t)
+		err := handleSelfUpdate([]string{"--yes"})
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("Expected a checksum mismatch, got %v", err)
+		}
+		if readFile(t, path) != "old" {
+			t.Error("A failed verification must not replace the binary")
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sorinlg/tf-manage2/internal/terraform"
+)
+
+// latestReleaseURL is the GitHub API endpoint for the newest tf-manage2 release; replaced in tests
+var latestReleaseURL = "https://api.github.com/repos/sorinlg/tf-manage2/releases/latest"
+
+// releaseCheckTimeout bounds the update check so it never holds up an offline operator
+const releaseCheckTimeout = 5 * time.Second
+
+// release is the part of a GitHub release the update check reads
+type release struct {
+	TagName string         `json:"tag_name"`
+	HTMLURL string         `json:"html_url"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+// latestRelease fetches the newest published release
+func latestRelease() (*release, error) {
+	request, err := http.NewRequest(http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+
+	client := &http.Client{Timeout: releaseCheckTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("releases API returned %s", response.Status)
+	}
+
+	var latest release
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&latest); err != nil {
+		return nil, fmt.Errorf("invalid releases API response: %w", err)
+	}
+	if latest.TagName == "" {
+		return nil, fmt.Errorf("invalid releases API response: no tag_name")
+	}
+	return &latest, nil
+}
+
+// checkForUpdate reports whether a newer release than current is available. Network and
+// parsing problems are reported as a note, never as a failure.
+func checkForUpdate(w io.Writer, current string) {
+	latest, err := latestRelease()
+	if err != nil {
+		fmt.Fprintf(w, "Could not check for updates: %v\n", err)
+		return
+	}
+
+	cmp, err := terraform.CompareVersions(current, latest.TagName)
+	switch {
+	case err != nil:
+		fmt.Fprintf(w, "Latest release is %s (cannot compare with version %s)\n", latest.TagName, current)
+	case cmp < 0:
+		fmt.Fprintf(w, "Update available: %s (running %s)\n", latest.TagName, current)
+		if latest.HTMLURL != "" {
+			fmt.Fprintf(w, "  %s\n", latest.HTMLURL)
+		}
+	default:
+		fmt.Fprintf(w, "tf-manage2 is up to date (latest release %s)\n", latest.TagName)
+	}
+}
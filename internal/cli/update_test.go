@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckForUpdate(t *testing.T) {
+	// fakeReleases serves a latest release with the given tag
+	fakeReleases := func(t *testing.T, status int, tag string) {
+		t.Helper()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"tag_name": %q, "html_url": "https://github.com/sorinlg/tf-manage2/releases/tag/%s"}`, tag, tag)
+		}))
+		t.Cleanup(server.Close)
+
+		original := latestReleaseURL
+		latestReleaseURL = server.URL
+		t.Cleanup(func() { latestReleaseURL = original })
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		tag     string
+		current string
+		want    string
+	}{
+		{"Up to date", http.StatusOK, "v1.4.0", "1.4.0", "tf-manage2 is up to date (latest release v1.4.0)\n"},
+		{"Ahead of the latest release", http.StatusOK, "v1.4.0", "1.5.0-rc1", "tf-manage2 is up to date (latest release v1.4.0)\n"},
+		{"Outdated", http.StatusOK, "v1.4.0", "1.2.3", "Update available: v1.4.0 (running 1.2.3)\n  https://github.com/sorinlg/tf-manage2/releases/tag/v1.4.0\n"},
+		{"Development build", http.StatusOK, "v1.4.0", "dev", "Latest release is v1.4.0 (cannot compare with version dev)\n"},
+		{"API error", http.StatusForbidden, "", "1.2.3", "Could not check for updates: releases API returned 403 Forbidden\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeReleases(t, tt.status, tt.tag)
+			var out strings.Builder
+			checkForUpdate(&out, tt.current)
+			if out.String() != tt.want {
+				t.Errorf("checkForUpdate(%s) = %q, want %q", tt.current, out.String(), tt.want)
+			}
+		})
+	}
+
+	t.Run("Offline", func(t *testing.T) {
+		original := latestReleaseURL
+		latestReleaseURL = "http://127.0.0.1:1/releases/latest"
+		t.Cleanup(func() { latestReleaseURL = original })
+
+		var out strings.Builder
+		checkForUpdate(&out, "1.2.3")
+		if !strings.HasPrefix(out.String(), "Could not check for updates: ") {
+			t.Errorf("Expected an offline note, got %q", out.String())
+		}
+	})
+}
+
+func TestVersionCheckFlags(t *testing.T) {
+	for _, args := range [][]string{{"--version", "--check"}, {"-v", "--check"}, {"version", "--check"}} {
+		positional, _, err := parseGlobalFlags(args)
+		if err != nil || len(positional) != 2 {
+			t.Errorf("parseGlobalFlags(%v) = %v, %v; want both arguments kept", args, positional, err)
+		}
+	}
+	if err := handleVersion([]string{"--bogus"}); err == nil {
+		t.Error("Expected a usage error for an unknown version flag")
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// BackendKeyVars are the tf-manage coordinates available to backend_key_template,
+// e.g. "{{.Product}}/{{.Env}}/{{.Module}}/{{.Instance}}.tfstate"
+type BackendKeyVars struct {
+	Product  string
+	Repo     string
+	Module   string
+	Env      string
+	Instance string
+}
+
+// WorkspacesEnabled reports whether instances are isolated with terraform workspaces (use_workspaces, default true)
+func (c *Config) WorkspacesEnabled() bool {
+	return c.UseWorkspaces == nil || *c.UseWorkspaces
+}
+
+// RenderBackendKey renders backend_key_template for one instance
+func (c *Config) RenderBackendKey(vars BackendKeyVars) (string, error) {
+	tmpl, err := parseBackendKeyTemplate(c.BackendKeyTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var key strings.Builder
+	if err := tmpl.Execute(&key, vars); err != nil {
+		return "", fmt.Errorf("invalid backend_key_template: %w", err)
+	}
+	if strings.TrimSpace(key.String()) == "" {
+		return "", fmt.Errorf("backend_key_template rendered an empty key")
+	}
+	return key.String(), nil
+}
+
+// validateBackendKey checks that backend_key_template and use_workspaces are set together
+// and that the template only uses the available coordinates
+func (c *Config) validateBackendKey() error {
+	switch {
+	case c.BackendKeyTemplate == "" && !c.WorkspacesEnabled():
+		return fmt.Errorf("use_workspaces: false requires backend_key_template, or every instance would share one state")
+	case c.BackendKeyTemplate == "":
+		return nil
+	case c.WorkspacesEnabled():
+		return fmt.Errorf("backend_key_template requires use_workspaces: false")
+	}
+
+	_, err := c.RenderBackendKey(BackendKeyVars{Product: "product", Repo: "repo", Module: "module", Env: "env", Instance: "instance"})
+	return err
+}
+
+// parseBackendKeyTemplate parses a backend key template, rejecting unknown fields when rendered
+func parseBackendKeyTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("backend_key_template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend_key_template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -2,10 +2,15 @@ package config
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/goccy/go-yaml"
 )
@@ -17,9 +22,87 @@ type Config struct {
 	ModuleRelPath string `json:"module_rel_path" yaml:"module_rel_path"`
 	ProjectDir    string `json:"project_dir"    yaml:"-"`
 	ConfigPath    string `json:"config_path"    yaml:"-"`
+	ConfigURL     string `json:"config_url"     yaml:"-"` // Set when loaded from TFM_CONFIG_URL; ConfigPath is then the cached copy
+
+	// ShadowedConfigPath is a .tfm.conf ignored because a .tfm.yaml next to it takes precedence
+	ShadowedConfigPath string `json:"shadowed_config_path" yaml:"-"`
+
+	// MinTerraformVersion blocks state-mutating actions on older terraform releases
+	MinTerraformVersion string `json:"min_terraform_version" yaml:"min_terraform_version,omitempty"`
+
+	// RedactPatterns are regular expressions whose matches are hidden in echoed commands and output
+	RedactPatterns []string `json:"redact_patterns" yaml:"redact_patterns,omitempty"`
+
+	// ProtectedEnvs are env names or glob patterns that need typed confirmation before apply/destroy/import
+	ProtectedEnvs []string `json:"protected_envs" yaml:"protected_envs,omitempty"`
+
+	// ApprovalCommand must exit 0 before an unattended apply, apply_plan or destroy runs
+	ApprovalCommand string `json:"approval_command" yaml:"approval_command,omitempty"`
+
+	// LockTimeout is passed to terraform as -lock-timeout for actions that lock state
+	LockTimeout string `json:"lock_timeout" yaml:"lock_timeout,omitempty"`
+
+	// RequireFreshPlan makes apply_plan refuse plans older than the module's .tf files or the tfvars file
+	RequireFreshPlan bool `json:"require_fresh_plan" yaml:"require_fresh_plan,omitempty"`
+
+	// ReviewPlans makes plan keep its output pending until it is approved, so apply_plan never
+	// picks up an unreviewed plan
+	ReviewPlans bool `json:"review_plans" yaml:"review_plans,omitempty"`
+
+	// WorkspacePrefix is prepended, with a "." separator, to every computed workspace name
+	WorkspacePrefix string `json:"workspace_prefix" yaml:"workspace_prefix,omitempty"`
+
+	// WorkspaceEnvSeparator replaces "/" in nested env names within workspace names (default "__")
+	WorkspaceEnvSeparator string `json:"workspace_env_separator" yaml:"workspace_env_separator,omitempty"`
+
+	// UseWorkspaces set to false stores each instance's state under its own backend key
+	// (see BackendKeyTemplate) instead of in a per-instance workspace
+	UseWorkspaces *bool `json:"use_workspaces" yaml:"use_workspaces,omitempty"`
+
+	// WorkspaceSelectEnv selects the instance workspace by passing TF_WORKSPACE to terraform
+	// instead of running terraform workspace select, for backends that behave better with it
+	WorkspaceSelectEnv bool `json:"workspace_select_env" yaml:"workspace_select_env,omitempty"`
+
+	// BackendKeyTemplate renders the backend key passed to init when use_workspaces is false
+	BackendKeyTemplate string `json:"backend_key_template" yaml:"backend_key_template,omitempty"`
+
+	// InjectTfmVars controls the -var tfm_* flags: always (default), auto (only the variables
+	// the module declares) or never. The booleans true and false mean always and never.
+	InjectTfmVars string `json:"inject_tfm_vars" yaml:"inject_tfm_vars,omitempty"`
+
+	// CheckTfvarsSyntax makes tf-manage check the instance tfvars file's structure before running terraform
+	CheckTfvarsSyntax bool `json:"check_tfvars_syntax" yaml:"check_tfvars_syntax,omitempty"`
+
+	// PlanDir relocates plan files to <plan_dir>/<product>/<env>/<module>/<instance>.tfplan
+	PlanDir string `json:"plan_dir" yaml:"plan_dir,omitempty"`
+
+	// ArtifactDir is the base for generated artifacts (plans, plan text, outputs), organized as
+	// <artifact_dir>/<product>/<env>/<module>/<instance>/. It takes precedence over PlanDir.
+	ArtifactDir string `json:"artifact_dir" yaml:"artifact_dir,omitempty"`
+
+	// PluginCacheDir is passed to terraform as TF_PLUGIN_CACHE_DIR for init so providers are downloaded once
+	PluginCacheDir string `json:"plugin_cache_dir" yaml:"plugin_cache_dir,omitempty"`
+
+	// ProviderLockPlatforms are passed as -platform to 'providers lock' when it names no platform
+	ProviderLockPlatforms []string `json:"provider_lock_platforms" yaml:"provider_lock_platforms,omitempty"`
+
+	// GlobalTerraformFlags are appended to every terraform action command (e.g. -no-color)
+	GlobalTerraformFlags []string `json:"global_terraform_flags" yaml:"global_terraform_flags,omitempty"`
+
+	// ActionTerraformFlags are appended to a single action's terraform command, keyed by action
+	ActionTerraformFlags map[string][]string `json:"action_terraform_flags" yaml:"action_terraform_flags,omitempty"`
+
+	// EnvOverrides replace selected settings for individual envs, keyed by env name
+	EnvOverrides map[string]EnvOverride `json:"env_overrides" yaml:"env_overrides,omitempty"`
+
+	// MaskIdentifiers hides product/repo/module/env/instance values in tf-manage logs
+	MaskIdentifiers bool `json:"mask_identifiers" yaml:"mask_identifiers,omitempty"`
 
 	// Version tracking for migration and compatibility
 	ConfigVersion string `json:"config_version" yaml:"config_version,omitempty"`
+
+	// repoNameAuto records that RepoName was derived from repo_name: "auto"
+	repoNameAuto bool
 }
 
 // DefaultConfig returns a config with default values
@@ -34,49 +117,144 @@ func DefaultConfig() *Config {
 func LoadConfig() (*Config, error) {
 	projectDir, err := findProjectDir()
 	if err != nil {
-		return nil, fmt.Errorf("failed to find project directory: %w", err)
+		return nil, newConfigError(ErrNoProjectDir, "", "failed to find project directory: %w", err)
 	}
 
-	config := DefaultConfig()
-	config.ProjectDir = projectDir
+	return loadConfigFromDir(projectDir)
+}
+
+// LoadConfigFrom loads the configuration from an explicit project root instead of
+// searching upwards from the current directory
+func LoadConfigFrom(projectDir string) (*Config, error) {
+	absDir, err := resolveProjectDir(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfigFromDir(absDir)
+}
+
+// LoadConfigFile loads an explicitly named config file instead of discovering .tfm.yaml or
+// .tfm.conf. A relative configFile is resolved against projectDir, which defaults to the
+// enclosing git repository when empty. Files ending in .yaml or .yml are parsed as YAML,
+// anything else as the legacy format.
+func LoadConfigFile(projectDir, configFile string) (*Config, error) {
+	var err error
+	if projectDir == "" {
+		projectDir, err = findProjectDir()
+		if err != nil {
+			return nil, newConfigError(ErrNoProjectDir, "", "failed to find project directory: %w", err)
+		}
+	} else if projectDir, err = resolveProjectDir(projectDir); err != nil {
+		return nil, err
+	}
+
+	configPath := configFile
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(projectDir, configFile)
+	}
+	if info, err := os.Stat(configPath); err != nil || info.IsDir() {
+		return nil, newConfigError(ErrConfigNotFound, configPath, "config file not found: %s", configPath)
+	}
+
+	return loadConfigAt(projectDir, configPath)
+}
+
+// resolveProjectDir returns projectDir as an absolute path, checking that it is a directory
+func resolveProjectDir(projectDir string) (string, error) {
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return "", newConfigError(ErrNoProjectDir, projectDir, "invalid project directory %s: %w", projectDir, err)
+	}
+
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return "", newConfigError(ErrNoProjectDir, absDir, "project directory does not exist: %s", absDir)
+	}
+
+	return absDir, nil
+}
+
+// loadConfigFromDir loads the configuration found in projectDir, or the remote config
+// named by TFM_CONFIG_URL when it is set
+func loadConfigFromDir(projectDir string) (*Config, error) {
+	if configURL := os.Getenv(ConfigURLEnv); configURL != "" {
+		return loadRemoteConfig(projectDir, configURL)
+	}
 
 	// Try YAML format first (new format)
 	yamlConfigPath := filepath.Join(projectDir, ".tfm.yaml")
+	legacyConfigPath := filepath.Join(projectDir, ".tfm.conf")
 	if _, err := os.Stat(yamlConfigPath); err == nil {
-		config.ConfigPath = yamlConfigPath
-		if err := parseYAMLConfigFile(yamlConfigPath, config); err != nil {
-			return nil, fmt.Errorf("failed to parse YAML config file %s: %w", yamlConfigPath, err)
+		config, err := loadConfigAt(projectDir, yamlConfigPath)
+		if err != nil {
+			return nil, err
 		}
-	} else {
-		// Fall back to legacy format
-		legacyConfigPath := filepath.Join(projectDir, ".tfm.conf")
-		config.ConfigPath = legacyConfigPath
-
-		// Check if legacy config file exists
-		if _, err := os.Stat(legacyConfigPath); os.IsNotExist(err) {
-			return nil, fmt.Errorf("config file not found. Create either:\n%s\n\nOR (recommended new format):\n%s",
-				generateLegacyConfigSnippet(projectDir), generateYAMLConfigSnippet(projectDir))
+		// Edits to a leftover legacy file would silently have no effect
+		if _, err := os.Stat(legacyConfigPath); err == nil {
+			config.ShadowedConfigPath = legacyConfigPath
 		}
+		return config, nil
+	}
+
+	// Fall back to legacy format
+	if _, err := os.Stat(legacyConfigPath); os.IsNotExist(err) {
+		return nil, newConfigError(ErrConfigNotFound, projectDir, "config file not found. Create either:\n%s\n\nOR (recommended new format):\n%s",
+			generateLegacyConfigSnippet(projectDir), generateYAMLConfigSnippet(projectDir))
+	}
+
+	return loadConfigAt(projectDir, legacyConfigPath)
+}
 
+// loadConfigAt parses and validates the config file at configPath for projectDir
+func loadConfigAt(projectDir, configPath string) (*Config, error) {
+	config := DefaultConfig()
+	config.ProjectDir = projectDir
+	config.ConfigPath = configPath
+
+	if IsYAMLConfigPath(configPath) {
+		if err := parseYAMLConfigFile(configPath, config); err != nil {
+			return nil, newConfigError(ErrInvalidConfig, configPath, "failed to parse YAML config file %s: %w", configPath, err)
+		}
+	} else {
 		// Parse the legacy config file and show deprecation notice
-		if err := parseLegacyConfigFile(legacyConfigPath, config); err != nil {
-			return nil, fmt.Errorf("failed to parse legacy config file %s: %w", legacyConfigPath, err)
+		if err := parseLegacyConfigFile(configPath, config); err != nil {
+			return nil, newConfigError(ErrInvalidConfig, configPath, "failed to parse legacy config file %s: %w", configPath, err)
 		}
 
 		// Show deprecation notice for legacy format
 		showDeprecationNotice()
 	}
 
+	config.resolveRepoName()
+
 	// Validate required fields
 	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+		return nil, newConfigError(ErrInvalidConfig, configPath, "invalid configuration: %w", err)
 	}
 
 	return config, nil
 }
 
-// Validate checks if the configuration is valid
+// IsYAMLConfigPath reports whether a config file uses the YAML format, judged by its extension
+func IsYAMLConfigPath(configPath string) bool {
+	ext := strings.ToLower(filepath.Ext(configPath))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// RepoNameIsAuto reports whether RepoName was derived automatically from repo_name: "auto"
+func (c *Config) RepoNameIsAuto() bool {
+	return c.repoNameAuto
+}
+
+// Validate checks if the configuration is valid. Failures match ErrInvalidConfig.
 func (c *Config) Validate() error {
+	if err := c.validate(); err != nil {
+		return &ConfigError{Kind: ErrInvalidConfig, Path: c.ConfigPath, Err: errors.Unwrap(err), message: err.Error()}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
 	if c.RepoName == "" {
 		return fmt.Errorf("repo_name is required")
 	}
@@ -86,9 +264,118 @@ func (c *Config) Validate() error {
 	if c.ModuleRelPath == "" {
 		return fmt.Errorf("module_rel_path is required")
 	}
+	for _, pattern := range c.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid redact_patterns entry %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.ProtectedEnvs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid protected_envs entry %q: %w", pattern, err)
+		}
+	}
+	if err := validateLockTimeout("lock_timeout", c.LockTimeout); err != nil {
+		return err
+	}
+	if c.MinTerraformVersion != "" && !terraformVersionPattern.MatchString(c.MinTerraformVersion) {
+		return fmt.Errorf("invalid min_terraform_version %q (expected a version like 1.5.0)", c.MinTerraformVersion)
+	}
+	if err := ValidateWorkspacePrefix(c.WorkspacePrefix); err != nil {
+		return err
+	}
+	if c.WorkspaceEnvSeparator != "" && !workspacePrefixPattern.MatchString(c.WorkspaceEnvSeparator) {
+		return fmt.Errorf("invalid workspace_env_separator %q (use letters, digits, '_' or '-')", c.WorkspaceEnvSeparator)
+	}
+	switch c.InjectTfmVars {
+	case "", "true", "false", InjectTfmVarsAlways, InjectTfmVarsAuto, InjectTfmVarsNever:
+	default:
+		return fmt.Errorf("invalid inject_tfm_vars %q (expected true, false, always, auto or never)", c.InjectTfmVars)
+	}
+	for _, platform := range c.ProviderLockPlatforms {
+		if !platformPattern.MatchString(platform) {
+			return fmt.Errorf("invalid provider_lock_platforms entry %q (expected os_arch like linux_amd64)", platform)
+		}
+	}
+	if err := c.validateBackendKey(); err != nil {
+		return err
+	}
+	if err := c.validateTerraformFlags(); err != nil {
+		return err
+	}
+	return c.validateEnvOverrides()
+}
+
+// DefaultWorkspaceEnvSeparator replaces "/" in nested env names when workspace_env_separator is not set
+const DefaultWorkspaceEnvSeparator = "__"
+
+// EnvSeparator returns the separator standing in for "/" in the env segment of workspace names
+func (c *Config) EnvSeparator() string {
+	if c.WorkspaceEnvSeparator == "" {
+		return DefaultWorkspaceEnvSeparator
+	}
+	return c.WorkspaceEnvSeparator
+}
+
+// inject_tfm_vars modes
+const (
+	InjectTfmVarsAlways = "always" // Pass every tfm_* variable, warning about undeclared ones
+	InjectTfmVarsAuto   = "auto"   // Pass only the tfm_* variables the module declares
+	InjectTfmVarsNever  = "never"  // Pass no tfm_* variables
+)
+
+// TfmVarsMode returns the inject_tfm_vars mode, defaulting to always
+func (c *Config) TfmVarsMode() string {
+	switch c.InjectTfmVars {
+	case "", "true":
+		return InjectTfmVarsAlways
+	case "false":
+		return InjectTfmVarsNever
+	}
+	return c.InjectTfmVars
+}
+
+// DefaultProviderLockPlatforms are locked when provider_lock_platforms is not set
+var DefaultProviderLockPlatforms = []string{"linux_amd64", "darwin_arm64", "windows_amd64"}
+
+// terraformVersionPattern matches a terraform version like 1.5, v1.5.7 or 1.6.0-rc1
+var terraformVersionPattern = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,2}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// platformPattern matches a terraform os_arch platform name
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+_[a-z0-9]+$`)
+
+// LockPlatforms returns the platforms 'providers lock' records checksums for by default
+func (c *Config) LockPlatforms() []string {
+	if len(c.ProviderLockPlatforms) == 0 {
+		return DefaultProviderLockPlatforms
+	}
+	return c.ProviderLockPlatforms
+}
+
+// workspacePrefixPattern limits prefixes to characters that are safe in workspace names
+var workspacePrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateWorkspacePrefix checks a workspace_prefix value can start a workspace name
+func ValidateWorkspacePrefix(prefix string) error {
+	if prefix != "" && !workspacePrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid workspace_prefix %q (use letters, digits, '-' and '_')", prefix)
+	}
 	return nil
 }
 
+// IsProtectedEnv reports whether env matches one of the protected_envs names or glob patterns.
+// An env override's protected flag takes precedence over protected_envs.
+func (c *Config) IsProtectedEnv(env string) bool {
+	if override, ok := c.EnvOverrides[env]; ok && override.Protected != nil {
+		return *override.Protected
+	}
+	for _, pattern := range c.ProtectedEnvs {
+		if matched, err := filepath.Match(pattern, env); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
 // GetModulePath returns the absolute path to the modules directory
 func (c *Config) GetModulePath() string {
 	return filepath.Join(c.ProjectDir, c.ModuleRelPath)
@@ -99,6 +386,30 @@ func (c *Config) GetEnvPath() string {
 	return filepath.Join(c.ProjectDir, c.EnvRelPath)
 }
 
+// GetPlanDir returns the absolute plan file directory, or "" when plans stay next to the tfvars
+func (c *Config) GetPlanDir() string {
+	if c.PlanDir == "" || filepath.IsAbs(c.PlanDir) {
+		return c.PlanDir
+	}
+	return filepath.Join(c.ProjectDir, c.PlanDir)
+}
+
+// GetArtifactDir returns the absolute artifact directory, or "" when artifacts stay in their default places
+func (c *Config) GetArtifactDir() string {
+	if c.ArtifactDir == "" || filepath.IsAbs(c.ArtifactDir) {
+		return c.ArtifactDir
+	}
+	return filepath.Join(c.ProjectDir, c.ArtifactDir)
+}
+
+// GetPluginCacheDir returns the absolute provider plugin cache directory, or "" when unset
+func (c *Config) GetPluginCacheDir() string {
+	if c.PluginCacheDir == "" || filepath.IsAbs(c.PluginCacheDir) {
+		return c.PluginCacheDir
+	}
+	return filepath.Join(c.ProjectDir, c.PluginCacheDir)
+}
+
 // findProjectDir finds the git repository root directory
 func findProjectDir() (string, error) {
 	cwd, err := os.Getwd()
@@ -209,12 +520,33 @@ func parseYAMLConfigFile(configPath string, config *Config) error {
 	return nil
 }
 
-// showDeprecationNotice displays a deprecation warning for legacy .tfm.conf format
+// SuppressDeprecationEnv silences the legacy format deprecation notice when set to 1
+const SuppressDeprecationEnv = "TFM_SUPPRESS_DEPRECATION"
+
+var (
+	deprecationOut        io.Writer = os.Stderr // replaced in tests
+	deprecationOnce       sync.Once
+	deprecationSuppressed atomic.Bool
+)
+
+// SuppressDeprecationNotice silences the legacy format deprecation notice for this process
+func SuppressDeprecationNotice() {
+	deprecationSuppressed.Store(true)
+}
+
+// showDeprecationNotice displays a deprecation warning for legacy .tfm.conf format,
+// at most once per process
 func showDeprecationNotice() {
-	fmt.Fprintf(os.Stderr, "\n⚠️  DEPRECATION NOTICE: Legacy .tfm.conf format detected\n")
-	fmt.Fprintf(os.Stderr, "   The bash export format (.tfm.conf) is deprecated and will be removed in v2.0\n")
-	fmt.Fprintf(os.Stderr, "   Please migrate to the new YAML format (.tfm.yaml)\n")
-	fmt.Fprintf(os.Stderr, "   Run 'tf config convert' to automatically migrate your configuration\n\n")
+	if deprecationSuppressed.Load() || os.Getenv(SuppressDeprecationEnv) == "1" {
+		return
+	}
+	deprecationOnce.Do(func() {
+		fmt.Fprintf(deprecationOut, "\n⚠️  DEPRECATION NOTICE: Legacy .tfm.conf format detected\n")
+		fmt.Fprintf(deprecationOut, "   The bash export format (.tfm.conf) is deprecated and will be removed in v2.0\n")
+		fmt.Fprintf(deprecationOut, "   Please migrate to the new YAML format (.tfm.yaml)\n")
+		fmt.Fprintf(deprecationOut, "   Run 'tf config convert' to automatically migrate your configuration\n")
+		fmt.Fprintf(deprecationOut, "   (set %s=1 or pass --no-deprecation-warning to hide this notice)\n\n", SuppressDeprecationEnv)
+	})
 }
 
 // generateLegacyConfigSnippet generates a sample .tfm.conf file content
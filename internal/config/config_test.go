@@ -0,0 +1,1035 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+// writeFile creates a file (and its parent directories) for a test
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// chdir changes into dir for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	originalDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change to %s: %v", dir, err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(originalDir); err != nil {
+			t.Errorf("Failed to restore original directory: %v", err)
+		}
+	})
+}
+
+func TestLoadConfigFrom(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), `config_version: "2.0"
+repo_name: "out-of-tree"
+env_rel_path: "infra/environments"
+module_rel_path: "infra/modules"
+`)
+
+	// Run from an unrelated directory that is not inside any git repository
+	chdir(t, t.TempDir())
+
+	t.Run("Absolute path", func(t *testing.T) {
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.RepoName != "out-of-tree" {
+			t.Errorf("RepoName = %s, want out-of-tree", cfg.RepoName)
+		}
+		if cfg.ProjectDir != projectDir {
+			t.Errorf("ProjectDir = %s, want %s", cfg.ProjectDir, projectDir)
+		}
+		if got, want := cfg.GetEnvPath(), filepath.Join(projectDir, "infra/environments"); got != want {
+			t.Errorf("GetEnvPath() = %s, want %s", got, want)
+		}
+		if got, want := cfg.GetModulePath(), filepath.Join(projectDir, "infra/modules"); got != want {
+			t.Errorf("GetModulePath() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Relative path", func(t *testing.T) {
+		chdir(t, filepath.Dir(projectDir))
+		cfg, err := LoadConfigFrom(filepath.Base(projectDir))
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.ProjectDir != projectDir {
+			t.Errorf("ProjectDir = %s, want %s", cfg.ProjectDir, projectDir)
+		}
+	})
+
+	t.Run("Directory without config", func(t *testing.T) {
+		if _, err := LoadConfigFrom(t.TempDir()); err == nil {
+			t.Error("Expected error for directory without config")
+		}
+	})
+
+	t.Run("Missing directory", func(t *testing.T) {
+		if _, err := LoadConfigFrom(filepath.Join(projectDir, "missing")); err == nil {
+			t.Error("Expected error for missing directory")
+		}
+	})
+
+	t.Run("YAML shadows a legacy config", func(t *testing.T) {
+		if cfg, err := LoadConfigFrom(projectDir); err != nil || cfg.ShadowedConfigPath != "" {
+			t.Fatalf("LoadConfigFrom() = %+v, %v; want no shadowed config", cfg, err)
+		}
+
+		legacyPath := filepath.Join(projectDir, ".tfm.conf")
+		writeFile(t, legacyPath, "export __tfm_repo_name='legacy'\nexport __tfm_env_rel_path='terraform/environments'\nexport __tfm_module_rel_path='terraform/modules'\n")
+		t.Cleanup(func() { os.Remove(legacyPath) })
+
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.RepoName != "out-of-tree" || cfg.ConfigPath != filepath.Join(projectDir, ".tfm.yaml") {
+			t.Errorf("Expected .tfm.yaml to win, got repo %s from %s", cfg.RepoName, cfg.ConfigPath)
+		}
+		if cfg.ShadowedConfigPath != legacyPath {
+			t.Errorf("ShadowedConfigPath = %q, want %q", cfg.ShadowedConfigPath, legacyPath)
+		}
+	})
+}
+
+// withMigrations replaces the migration chain for the duration of a test
+func withMigrations(t *testing.T, chain ...Migration) {
+	t.Helper()
+	original := migrations
+	migrations = chain
+	t.Cleanup(func() {
+		migrations = original
+	})
+}
+
+func TestMigrateConfig(t *testing.T) {
+	withMigrations(t, Migration{
+		From:        "2.0",
+		To:          "2.1",
+		Description: "rename environments directory",
+		Apply: func(cfg *Config) error {
+			cfg.EnvRelPath = "terraform/envs"
+			return nil
+		},
+	})
+
+	t.Run("Successful bump", func(t *testing.T) {
+		projectDir := t.TempDir()
+		configPath := filepath.Join(projectDir, ".tfm.yaml")
+		writeFile(t, configPath, `config_version: "2.0"
+repo_name: "bump-me"
+env_rel_path: "terraform/environments"
+module_rel_path: "terraform/modules"
+`)
+
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+
+		applied, err := MigrateConfig(cfg, "2.1")
+		if err != nil {
+			t.Fatalf("MigrateConfig failed: %v", err)
+		}
+		if len(applied) != 1 || applied[0].To != "2.1" {
+			t.Errorf("Unexpected applied migrations: %v", applied)
+		}
+		if err := WriteYAMLConfig(configPath, cfg); err != nil {
+			t.Fatalf("WriteYAMLConfig failed: %v", err)
+		}
+
+		reloaded, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("Reloading bumped config failed: %v", err)
+		}
+		if reloaded.ConfigVersion != "2.1" || reloaded.EnvRelPath != "terraform/envs" {
+			t.Errorf("Unexpected bumped config: version=%s env_rel_path=%s", reloaded.ConfigVersion, reloaded.EnvRelPath)
+		}
+	})
+
+	t.Run("Refused downgrade", func(t *testing.T) {
+		cfg := &Config{ConfigVersion: "2.1"}
+		if _, err := MigrateConfig(cfg, "2.0"); err == nil {
+			t.Fatal("Expected downgrade to be refused")
+		}
+		if cfg.ConfigVersion != "2.1" {
+			t.Errorf("ConfigVersion changed to %s after refused downgrade", cfg.ConfigVersion)
+		}
+	})
+
+	t.Run("Unsupported target", func(t *testing.T) {
+		if _, err := MigrateConfig(&Config{ConfigVersion: "2.0"}, "9.0"); err == nil {
+			t.Error("Expected unsupported target to be rejected")
+		}
+	})
+}
+
+func TestIsProtectedEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RepoName = "test-repo"
+	cfg.ProtectedEnvs = []string{"prod", "prod-*"}
+
+	for env, want := range map[string]bool{
+		"prod":       true,
+		"prod-eu":    true,
+		"production": false,
+		"dev":        false,
+	} {
+		if got := cfg.IsProtectedEnv(env); got != want {
+			t.Errorf("IsProtectedEnv(%q) = %v, want %v", env, got, want)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	cfg.ProtectedEnvs = []string{"prod-["}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() expected error for malformed protected_envs pattern")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), `config_version: "2.0"
+repo_name: "test-repo"
+env_rel_path: "terraform/environments"
+module_rel_path: "terraform/modules"
+lock_timeout: "30s"
+protected_envs: ["prod*"]
+env_overrides:
+  prod:
+    module_rel_path: "terraform/modules-pinned"
+    lock_timeout: "5m"
+  prod-sandbox:
+    protected: false
+`)
+
+	cfg, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+
+	prod := cfg.ForEnv("prod")
+	if got, want := prod.GetModulePath(), filepath.Join(projectDir, "terraform/modules-pinned"); got != want {
+		t.Errorf("prod GetModulePath() = %s, want %s", got, want)
+	}
+	if prod.LockTimeout != "5m" {
+		t.Errorf("prod LockTimeout = %s, want 5m", prod.LockTimeout)
+	}
+
+	dev := cfg.ForEnv("dev")
+	if got, want := dev.GetModulePath(), filepath.Join(projectDir, "terraform/modules"); got != want {
+		t.Errorf("dev GetModulePath() = %s, want %s", got, want)
+	}
+	if dev.LockTimeout != "30s" {
+		t.Errorf("dev LockTimeout = %s, want 30s", dev.LockTimeout)
+	}
+
+	// The base config is left untouched
+	if cfg.ModuleRelPath != "terraform/modules" {
+		t.Errorf("Base ModuleRelPath changed to %s", cfg.ModuleRelPath)
+	}
+
+	if !cfg.IsProtectedEnv("prod") || cfg.IsProtectedEnv("prod-sandbox") {
+		t.Error("Expected the protected override to take precedence over protected_envs")
+	}
+
+	t.Run("Unknown override key", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, ".tfm.yaml"), `repo_name: "test-repo"
+env_overrides:
+  prod:
+    module_path: "terraform/modules-pinned"
+`)
+		if _, err := LoadConfigFrom(dir); err == nil || !strings.Contains(err.Error(), "module_path") {
+			t.Errorf("Expected unknown key error naming module_path, got %v", err)
+		}
+	})
+
+	t.Run("Invalid lock timeout", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, ".tfm.yaml"), `repo_name: "test-repo"
+env_overrides:
+  prod:
+    lock_timeout: "forever"
+`)
+		if _, err := LoadConfigFrom(dir); err == nil {
+			t.Error("Expected error for invalid lock_timeout")
+		}
+	})
+}
+
+func TestMinTerraformVersionValidation(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{"", false},
+		{"1.5", false},
+		{"1.5.7", false},
+		{"v1.6.0-rc1", false},
+		{"1,5.0", true},
+		{">= 1.5", true},
+		{"1.5.0.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			cfg := &Config{RepoName: "test-repo", EnvRelPath: "envs", ModuleRelPath: "modules", MinTerraformVersion: tt.version}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTerraformFlags(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), `repo_name: "test-repo"
+global_terraform_flags: ["-no-color"]
+action_terraform_flags:
+  plan: ["-compact-warnings"]
+`)
+
+	cfg, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+	if got := cfg.TerraformFlagsFor("plan"); !reflect.DeepEqual(got, []string{"-no-color", "-compact-warnings"}) {
+		t.Errorf("TerraformFlagsFor(plan) = %v", got)
+	}
+	if got := cfg.TerraformFlagsFor("apply"); !reflect.DeepEqual(got, []string{"-no-color"}) {
+		t.Errorf("TerraformFlagsFor(apply) = %v", got)
+	}
+
+	for _, flags := range [][]string{
+		{"-var-file=extra.tfvars"},
+		{"-out", "other.tfplan"},
+		{"no-color"},
+		{"-no-color -compact-warnings"},
+	} {
+		cfg := DefaultConfig()
+		cfg.RepoName = "test-repo"
+		cfg.GlobalTerraformFlags = flags
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate() expected error for global_terraform_flags %q", flags)
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	projectDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(projectDir, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create .git: %v", err)
+	}
+	writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), `repo_name: "default-boundary"
+`)
+	writeFile(t, filepath.Join(projectDir, ".tfm.prod.yaml"), `repo_name: "prod-boundary"
+module_rel_path: "prod/modules"
+`)
+	writeFile(t, filepath.Join(projectDir, "legacy.conf"), `export __tfm_repo_name="legacy-boundary"
+export __tfm_env_rel_path="terraform/environments"
+export __tfm_module_rel_path="terraform/modules"
+`)
+	otherDir := t.TempDir()
+	writeFile(t, filepath.Join(otherDir, "shared.yml"), `repo_name: "shared-boundary"
+`)
+
+	t.Run("Relative to the detected project dir", func(t *testing.T) {
+		chdir(t, projectDir)
+		cfg, err := LoadConfigFile("", ".tfm.prod.yaml")
+		if err != nil {
+			t.Fatalf("LoadConfigFile failed: %v", err)
+		}
+		if cfg.RepoName != "prod-boundary" {
+			t.Errorf("RepoName = %s, want prod-boundary (alternate file must win over .tfm.yaml)", cfg.RepoName)
+		}
+		if got, want := cfg.GetModulePath(), filepath.Join(projectDir, "prod/modules"); got != want {
+			t.Errorf("GetModulePath() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Relative to an explicit project dir", func(t *testing.T) {
+		chdir(t, t.TempDir())
+		cfg, err := LoadConfigFile(projectDir, "legacy.conf")
+		if err != nil {
+			t.Fatalf("LoadConfigFile failed: %v", err)
+		}
+		if cfg.RepoName != "legacy-boundary" || cfg.ConfigPath != filepath.Join(projectDir, "legacy.conf") {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("Absolute path keeps the project dir", func(t *testing.T) {
+		cfg, err := LoadConfigFile(projectDir, filepath.Join(otherDir, "shared.yml"))
+		if err != nil {
+			t.Fatalf("LoadConfigFile failed: %v", err)
+		}
+		if cfg.RepoName != "shared-boundary" || cfg.ProjectDir != projectDir {
+			t.Errorf("Unexpected config: %+v", cfg)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		if _, err := LoadConfigFile(projectDir, ".tfm.staging.yaml"); err == nil || !strings.Contains(err.Error(), ".tfm.staging.yaml") {
+			t.Errorf("Expected not found error naming the file, got %v", err)
+		}
+	})
+}
+
+func TestLint(t *testing.T) {
+	// lintFields loads the config written to dir/name and returns the fields that were flagged
+	lintFields := func(t *testing.T, dir, name, content string) []string {
+		t.Helper()
+		writeFile(t, filepath.Join(dir, name), content)
+		cfg, err := LoadConfigFile(dir, name)
+		if err != nil {
+			t.Fatalf("LoadConfigFile failed: %v", err)
+		}
+		warnings, err := Lint(cfg)
+		if err != nil {
+			t.Fatalf("Lint failed: %v", err)
+		}
+		var fields []string
+		for _, warning := range warnings {
+			if warning.Suggestion == "" {
+				t.Errorf("Warning for %s has no suggestion", warning.Field)
+			}
+			fields = append(fields, warning.Field)
+		}
+		return fields
+	}
+
+	tests := []struct {
+		name    string
+		dirName string
+		file    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Clean config",
+			dirName: "infra",
+			file:    ".tfm.yaml",
+			content: "config_version: \"2.0\"\nrepo_name: \"infra\"\n",
+		},
+		{
+			name:    "Missing config_version",
+			dirName: "infra",
+			file:    ".tfm.yaml",
+			content: "repo_name: \"infra\"\n",
+			want:    []string{"config_version"},
+		},
+		{
+			name:    "Legacy format",
+			dirName: "infra",
+			file:    ".tfm.conf",
+			content: "export __tfm_repo_name='infra'\nexport __tfm_env_rel_path='terraform/environments'\nexport __tfm_module_rel_path='terraform/modules'\n",
+			want:    []string{"format"},
+		},
+		{
+			name:    "Trailing slashes",
+			dirName: "infra",
+			file:    ".tfm.yaml",
+			content: "config_version: \"2.0\"\nrepo_name: \"infra\"\nenv_rel_path: \"terraform/environments/\"\nmodule_rel_path: \"terraform/modules/\"\n",
+			want:    []string{"env_rel_path", "module_rel_path"},
+		},
+		{
+			name:    "Repo name differs from directory",
+			dirName: "infra",
+			file:    ".tfm.yaml",
+			content: "config_version: \"2.0\"\nrepo_name: \"platform\"\n",
+			want:    []string{"repo_name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), tt.dirName)
+			got := lintFields(t, dir, tt.file, tt.content)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Lint() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Outdated config_version", func(t *testing.T) {
+		withMigrations(t, Migration{From: "2.0", To: "2.1", Description: "test", Apply: func(*Config) error { return nil }})
+		dir := filepath.Join(t.TempDir(), "infra")
+		got := lintFields(t, dir, ".tfm.yaml", "config_version: \"2.0\"\nrepo_name: \"infra\"\n")
+		if strings.Join(got, ",") != "config_version" {
+			t.Errorf("Lint() fields = %v, want [config_version]", got)
+		}
+	})
+}
+
+func TestRepoNameFromURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:sorinlg/tf-manage2.git":              "tf-manage2",
+		"ssh://git@github.com/sorinlg/tf-manage2.git":        "tf-manage2",
+		"ssh://git@gitlab.example.com:2222/infra/live.git":   "live",
+		"https://github.com/sorinlg/tf-manage2.git":          "tf-manage2",
+		"https://github.com/sorinlg/tf-manage2":              "tf-manage2",
+		"https://gitlab.example.com/group/sub/platform.git/": "platform",
+		"/srv/git/mirror.git":                                "mirror",
+		"":                                                   "",
+	}
+
+	for url, want := range tests {
+		if got := repoNameFromURL(url); got != want {
+			t.Errorf("repoNameFromURL(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestAutoRepoName(t *testing.T) {
+	// withRemote fakes the origin remote URL returned by git
+	withRemote := func(t *testing.T, url string) {
+		t.Helper()
+		original := gitRemoteURL
+		gitRemoteURL = func(string) string { return url }
+		t.Cleanup(func() { gitRemoteURL = original })
+	}
+
+	projectDir := filepath.Join(t.TempDir(), "checkout")
+	writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), "config_version: \"2.0\"\nrepo_name: \"auto\"\n")
+
+	t.Run("From git remote", func(t *testing.T) {
+		withRemote(t, "git@github.com:sorinlg/infra-live.git")
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.RepoName != "infra-live" || !cfg.RepoNameIsAuto() {
+			t.Errorf("RepoName = %s (auto %v), want infra-live (auto)", cfg.RepoName, cfg.RepoNameIsAuto())
+		}
+
+		// Writing the config back keeps "auto" instead of freezing the derived name
+		out := filepath.Join(t.TempDir(), ".tfm.yaml")
+		if err := WriteYAMLConfig(out, cfg); err != nil {
+			t.Fatalf("WriteYAMLConfig failed: %v", err)
+		}
+		data, _ := os.ReadFile(out)
+		if !strings.Contains(string(data), "repo_name: auto") {
+			t.Errorf("Expected repo_name to stay auto:\n%s", data)
+		}
+	})
+
+	t.Run("Falls back to directory name", func(t *testing.T) {
+		withRemote(t, "")
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.RepoName != "checkout" {
+			t.Errorf("RepoName = %s, want checkout", cfg.RepoName)
+		}
+	})
+}
+
+func TestRemoteConfig(t *testing.T) {
+	var requests int
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		authorization = r.Header.Get("Authorization")
+		if r.URL.Path != "/tfm.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "repo_name: platform-base\nenv_rel_path: envs\nmodule_rel_path: modules\nlock_timeout: 1m\n")
+	}))
+	defer server.Close()
+
+	allowInsecureConfigURL = true
+	cacheDir := t.TempDir()
+	originalCacheDir := remoteConfigCacheDir
+	remoteConfigCacheDir = func() (string, error) { return cacheDir, nil }
+	t.Cleanup(func() {
+		allowInsecureConfigURL = false
+		remoteConfigCacheDir = originalCacheDir
+	})
+
+	projectDir := t.TempDir()
+	t.Setenv(ConfigURLEnv, server.URL+"/tfm.yaml")
+	t.Setenv(ConfigTokenEnv, "s3cret")
+
+	cfg, err := LoadConfigFrom(projectDir)
+	if err != nil {
+		t.Fatalf("LoadConfigFrom failed: %v", err)
+	}
+	if cfg.RepoName != "platform-base" || cfg.LockTimeout != "1m" {
+		t.Errorf("Unexpected remote config: %+v", cfg)
+	}
+	if cfg.ProjectDir != projectDir || cfg.ConfigURL != server.URL+"/tfm.yaml" {
+		t.Errorf("ProjectDir = %s, ConfigURL = %s", cfg.ProjectDir, cfg.ConfigURL)
+	}
+	if authorization != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want bearer token", authorization)
+	}
+
+	// A second load within the session is served from the cache
+	if _, err := LoadConfigFrom(projectDir); err != nil {
+		t.Fatalf("Second LoadConfigFrom failed: %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 request thanks to the cache, got %d", requests)
+	}
+
+	t.Run("Token is part of the cache key", func(t *testing.T) {
+		t.Setenv(ConfigTokenEnv, "other")
+		before := requests
+		if _, err := LoadConfigFrom(projectDir); err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if requests != before+1 || authorization != "Bearer other" {
+			t.Errorf("Expected a fresh request with the new token, got %d requests and %q", requests-before, authorization)
+		}
+	})
+
+	t.Run("Writable cache files are not trusted", func(t *testing.T) {
+		entries, err := filepath.Glob(filepath.Join(cacheDir, "tfm-config-*.yaml"))
+		if err != nil || len(entries) == 0 {
+			t.Fatalf("Expected cached configs, got %v (%v)", entries, err)
+		}
+		for _, entry := range entries {
+			writeFile(t, entry, "repo_name: planted\napproval_command: sh -c 'id'\n")
+			if err := os.Chmod(entry, 0666); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		cfg, err := LoadConfigFrom(projectDir)
+		if err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if cfg.RepoName != "platform-base" {
+			t.Errorf("Expected the planted config to be refetched, got repo_name %q", cfg.RepoName)
+		}
+	})
+
+	t.Run("Cache directory is made private", func(t *testing.T) {
+		if err := os.Chmod(cacheDir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfigFrom(projectDir); err != nil {
+			t.Fatalf("LoadConfigFrom failed: %v", err)
+		}
+		if info, err := os.Stat(cacheDir); err != nil || info.Mode().Perm() != 0700 {
+			t.Errorf("Expected cache directory mode 0700, got %v (%v)", info.Mode().Perm(), err)
+		}
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		t.Setenv(ConfigURLEnv, server.URL+"/missing.yaml")
+		if _, err := LoadConfigFrom(projectDir); err == nil || !strings.Contains(err.Error(), "404") {
+			t.Errorf("Expected a 404 error, got %v", err)
+		}
+	})
+
+	t.Run("Plain HTTP is refused", func(t *testing.T) {
+		allowInsecureConfigURL = false
+		defer func() { allowInsecureConfigURL = true }()
+		t.Setenv(ConfigURLEnv, "http://config.example.com/tfm.yaml")
+		if _, err := LoadConfigFrom(projectDir); err == nil || !strings.Contains(err.Error(), "https://") {
+			t.Errorf("Expected an https error, got %v", err)
+		}
+	})
+}
+
+func TestDeprecationNotice(t *testing.T) {
+	projectDir := t.TempDir()
+	writeFile(t, filepath.Join(projectDir, ".tfm.conf"), "export __tfm_repo_name='infra'\nexport __tfm_env_rel_path='terraform/environments'\nexport __tfm_module_rel_path='terraform/modules'\n")
+
+	// loadLegacy loads the legacy config twice with a fresh once-only state and returns the notice output
+	loadLegacy := func(t *testing.T) string {
+		t.Helper()
+		var out strings.Builder
+		previous := deprecationOut
+		deprecationOut = &out
+		deprecationOnce = sync.Once{}
+		deprecationSuppressed.Store(false)
+		t.Cleanup(func() {
+			deprecationOut = previous
+			deprecationSuppressed.Store(false)
+		})
+
+		for range 2 {
+			if _, err := LoadConfigFrom(projectDir); err != nil {
+				t.Fatalf("LoadConfigFrom() error: %v", err)
+			}
+		}
+		return out.String()
+	}
+
+	t.Run("Shown once by default", func(t *testing.T) {
+		t.Setenv(SuppressDeprecationEnv, "")
+		out := loadLegacy(t)
+		if count := strings.Count(out, "DEPRECATION NOTICE"); count != 1 {
+			t.Errorf("Notice shown %d times, want 1:\n%s", count, out)
+		}
+	})
+
+	t.Run("Suppressed by env", func(t *testing.T) {
+		t.Setenv(SuppressDeprecationEnv, "1")
+		if out := loadLegacy(t); out != "" {
+			t.Errorf("Expected no notice with %s=1, got:\n%s", SuppressDeprecationEnv, out)
+		}
+	})
+
+	t.Run("Suppressed by flag", func(t *testing.T) {
+		t.Setenv(SuppressDeprecationEnv, "")
+		var out strings.Builder
+		deprecationOut = &out
+		deprecationOnce = sync.Once{}
+		t.Cleanup(func() { deprecationOut = os.Stderr; deprecationSuppressed.Store(false) })
+
+		SuppressDeprecationNotice()
+		if _, err := LoadConfigFrom(projectDir); err != nil {
+			t.Fatalf("LoadConfigFrom() error: %v", err)
+		}
+		if out.String() != "" {
+			t.Errorf("Expected no notice after SuppressDeprecationNotice, got:\n%s", out.String())
+		}
+	})
+}
+
+func TestMigrateCheck(t *testing.T) {
+	legacy := "export __tfm_repo_name='infra'\nexport __tfm_env_rel_path='envs'\nexport __tfm_module_rel_path='modules'\n"
+	header := "# tf-manage2 configuration file\n# For documentation, see: https://github.com/sorinlg/tf-manage2\n\n"
+
+	t.Run("RenderYAML", func(t *testing.T) {
+		cfg := &Config{ConfigVersion: "2.0", RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules", LockTimeout: "5m"}
+		data, err := RenderYAML(cfg)
+		if err != nil {
+			t.Fatalf("RenderYAML() error: %v", err)
+		}
+		want := header + "config_version: \"2.0\"\nrepo_name: infra\nenv_rel_path: envs\nmodule_rel_path: modules\nlock_timeout: 5m\n"
+		if string(data) != want {
+			t.Errorf("RenderYAML() =\n%s\nwant\n%s", data, want)
+		}
+	})
+
+	t.Run("New file", func(t *testing.T) {
+		projectDir := t.TempDir()
+		writeFile(t, filepath.Join(projectDir, ".tfm.conf"), legacy)
+		yamlPath := filepath.Join(projectDir, ".tfm.yaml")
+
+		preview, err := MigrateCheck(projectDir)
+		if err != nil {
+			t.Fatalf("MigrateCheck() error: %v", err)
+		}
+		want := "--- /dev/null\n+++ " + yamlPath + "\n@@ -0,0 +1,7 @@\n" +
+			"+# tf-manage2 configuration file\n+# For documentation, see: https://github.com/sorinlg/tf-manage2\n+\n" +
+			"+config_version: \"2.0\"\n+repo_name: infra\n+env_rel_path: envs\n+module_rel_path: modules\n"
+		if preview != want {
+			t.Errorf("MigrateCheck() =\n%s\nwant\n%s", preview, want)
+		}
+		if _, err := os.Stat(yamlPath); !os.IsNotExist(err) {
+			t.Error("MigrateCheck() must not write .tfm.yaml")
+		}
+	})
+
+	t.Run("Existing YAML", func(t *testing.T) {
+		projectDir := t.TempDir()
+		writeFile(t, filepath.Join(projectDir, ".tfm.conf"), legacy)
+		existing := header + "config_version: \"2.0\"\nrepo_name: infra\nenv_rel_path: environments\nmodule_rel_path: modules\n"
+		writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), existing)
+
+		preview, err := MigrateCheck(projectDir)
+		if err != nil {
+			t.Fatalf("MigrateCheck() error: %v", err)
+		}
+		if !strings.Contains(preview, "\n-env_rel_path: environments\n+env_rel_path: envs\n") {
+			t.Errorf("Expected the env_rel_path change in:\n%s", preview)
+		}
+		if strings.Contains(preview, "-repo_name") || strings.Contains(preview, "+repo_name") {
+			t.Errorf("Unchanged lines must not be marked as changed:\n%s", preview)
+		}
+
+		writeFile(t, filepath.Join(projectDir, ".tfm.yaml"), strings.Replace(existing, "environments", "envs", 1))
+		if preview, err := MigrateCheck(projectDir); err != nil || preview != "" {
+			t.Errorf("MigrateCheck() = %q, %v; want no changes", preview, err)
+		}
+	})
+
+	t.Run("No legacy file", func(t *testing.T) {
+		if _, err := MigrateCheck(t.TempDir()); err == nil {
+			t.Error("Expected an error without .tfm.conf")
+		}
+	})
+}
+
+func TestValidateWorkspacePrefix(t *testing.T) {
+	for _, prefix := range []string{"", "team-a", "shared_infra2"} {
+		if err := ValidateWorkspacePrefix(prefix); err != nil {
+			t.Errorf("ValidateWorkspacePrefix(%q) unexpected error: %v", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"team.a", "team/a", "team a", "."} {
+		if err := ValidateWorkspacePrefix(prefix); err == nil {
+			t.Errorf("ValidateWorkspacePrefix(%q) expected an error", prefix)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	projectWith := func(t *testing.T, name, content string) string {
+		t.Helper()
+		projectDir := t.TempDir()
+		writeFile(t, filepath.Join(projectDir, name), content)
+		return projectDir
+	}
+
+	tests := []struct {
+		name string
+		load func(t *testing.T) error
+		kind error
+		path bool // ConfigError.Path is set
+	}{
+		{"Not in a git repository", func(t *testing.T) error {
+			t.Chdir(t.TempDir())
+			_, err := LoadConfig()
+			return err
+		}, ErrNoProjectDir, false},
+		{"Missing project directory", func(t *testing.T) error {
+			_, err := LoadConfigFrom(filepath.Join(t.TempDir(), "missing"))
+			return err
+		}, ErrNoProjectDir, true},
+		{"No config file", func(t *testing.T) error {
+			t.Setenv(ConfigURLEnv, "")
+			_, err := LoadConfigFrom(t.TempDir())
+			return err
+		}, ErrConfigNotFound, true},
+		{"Missing --config file", func(t *testing.T) error {
+			_, err := LoadConfigFile(t.TempDir(), "custom.yaml")
+			return err
+		}, ErrConfigNotFound, true},
+		{"Invalid YAML", func(t *testing.T) error {
+			_, err := LoadConfigFrom(projectWith(t, ".tfm.yaml", "repo_name: [unclosed\n"))
+			return err
+		}, ErrInvalidConfig, true},
+		{"Missing required field", func(t *testing.T) error {
+			_, err := LoadConfigFrom(projectWith(t, ".tfm.yaml", "repo_name: infra\nenv_rel_path: \"\"\n"))
+			return err
+		}, ErrInvalidConfig, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.load(t)
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("error %v does not match %v", err, tt.kind)
+			}
+			for _, other := range []error{ErrNoProjectDir, ErrConfigNotFound, ErrInvalidConfig} {
+				if other != tt.kind && errors.Is(err, other) {
+					t.Errorf("error %v also matches %v", err, other)
+				}
+			}
+
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("error %v is not a *ConfigError", err)
+			}
+			if configErr.Kind != tt.kind || (configErr.Path != "") != tt.path {
+				t.Errorf("ConfigError = {Kind: %v, Path: %q}, want kind %v (path set: %v)", configErr.Kind, configErr.Path, tt.kind, tt.path)
+			}
+		})
+	}
+
+	t.Run("Validate", func(t *testing.T) {
+		err := (&Config{RepoName: "infra", EnvRelPath: "envs"}).Validate()
+		var configErr *ConfigError
+		if !errors.Is(err, ErrInvalidConfig) || !errors.As(err, &configErr) {
+			t.Fatalf("Validate() = %v, want an ErrInvalidConfig *ConfigError", err)
+		}
+		if err.Error() != "module_rel_path is required" {
+			t.Errorf("Validate() message = %q, want it unchanged", err.Error())
+		}
+
+		err = (&Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules", RedactPatterns: []string{"("}}).Validate()
+		var syntaxErr *syntax.Error
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Validate() = %v, want the regexp error as its cause", err)
+		}
+	})
+}
+
+func TestProviderLockPlatforms(t *testing.T) {
+	cfg := &Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules"}
+	if got := cfg.LockPlatforms(); !reflect.DeepEqual(got, DefaultProviderLockPlatforms) {
+		t.Errorf("LockPlatforms() = %v, want the defaults", got)
+	}
+
+	cfg.ProviderLockPlatforms = []string{"linux_arm64", "darwin_amd64"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if got := cfg.LockPlatforms(); !reflect.DeepEqual(got, cfg.ProviderLockPlatforms) {
+		t.Errorf("LockPlatforms() = %v, want %v", got, cfg.ProviderLockPlatforms)
+	}
+
+	cfg.ProviderLockPlatforms = []string{"-platform=linux_amd64"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an invalid platform to be rejected")
+	}
+}
+
+func TestTfmVarsMode(t *testing.T) {
+	cfg := &Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules"}
+	if got := cfg.TfmVarsMode(); got != InjectTfmVarsAlways {
+		t.Errorf("TfmVarsMode() = %q, want %q by default", got, InjectTfmVarsAlways)
+	}
+
+	for _, mode := range []string{InjectTfmVarsAlways, InjectTfmVarsAuto, InjectTfmVarsNever} {
+		cfg.InjectTfmVars = mode
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() with inject_tfm_vars %q unexpected error: %v", mode, err)
+		}
+	}
+
+	// A YAML boolean switches injection on or off
+	for data, want := range map[string]string{"inject_tfm_vars: false\n": InjectTfmVarsNever, "inject_tfm_vars: true\n": InjectTfmVarsAlways} {
+		var decoded Config
+		if err := yaml.Unmarshal([]byte(data), &decoded); err != nil {
+			t.Fatalf("Unmarshal(%q) error = %v", data, err)
+		}
+		if got := decoded.TfmVarsMode(); got != want {
+			t.Errorf("TfmVarsMode() for %q = %q, want %q", data, got, want)
+		}
+	}
+
+	cfg.InjectTfmVars = "sometimes"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an invalid inject_tfm_vars mode to be rejected")
+	}
+}
+
+func TestEnvSeparator(t *testing.T) {
+	cfg := &Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules"}
+	if got := cfg.EnvSeparator(); got != DefaultWorkspaceEnvSeparator {
+		t.Errorf("EnvSeparator() = %q, want %q by default", got, DefaultWorkspaceEnvSeparator)
+	}
+
+	cfg.WorkspaceEnvSeparator = "--"
+	if err := cfg.Validate(); err != nil || cfg.EnvSeparator() != "--" {
+		t.Errorf("EnvSeparator() = %q, %v; want -- without error", cfg.EnvSeparator(), err)
+	}
+
+	// Dots split workspace segments and slashes are what the separator replaces
+	for _, separator := range []string{".", "/", "a.b"} {
+		cfg.WorkspaceEnvSeparator = separator
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected workspace_env_separator %q to be rejected", separator)
+		}
+	}
+}
+
+func TestBackendKeyTemplate(t *testing.T) {
+	const template = "{{.Product}}/{{.Env}}/{{.Module}}/{{.Instance}}.tfstate"
+	noWorkspaces := false
+	withWorkspaces := true
+
+	tests := []struct {
+		name          string
+		useWorkspaces *bool
+		template      string
+		wantErr       bool
+	}{
+		{"Workspaces by default", nil, "", false},
+		{"Key template without workspaces", &noWorkspaces, template, false},
+		{"No workspaces needs a template", &noWorkspaces, "", true},
+		{"Template needs workspaces disabled", &withWorkspaces, template, true},
+		{"Template with workspaces by default", nil, template, true},
+		{"Invalid syntax", &noWorkspaces, "{{.Product", true},
+		{"Unknown field", &noWorkspaces, "{{.Region}}/{{.Instance}}", true},
+		{"Empty key", &noWorkspaces, "{{if false}}x{{end}}", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules", UseWorkspaces: tt.useWorkspaces, BackendKeyTemplate: tt.template}
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal([]byte("use_workspaces: false\nbackend_key_template: \""+template+"\"\n"), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.WorkspacesEnabled() {
+		t.Error("Expected use_workspaces: false to disable workspaces")
+	}
+	key, err := cfg.RenderBackendKey(BackendKeyVars{Product: "product1", Repo: "infra", Module: "vpc", Env: "prod/eu", Instance: "main"})
+	if err != nil || key != "product1/prod/eu/vpc/main.tfstate" {
+		t.Errorf("RenderBackendKey() = %q, %v; want product1/prod/eu/vpc/main.tfstate", key, err)
+	}
+}
+
+func TestConfigSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		check   func(cfg *Config) bool
+		wantErr bool
+	}{
+		{"String key", "repo_name", "platform", func(cfg *Config) bool { return cfg.RepoName == "platform" }, false},
+		{"Newer string key", "lock_timeout", "5m", func(cfg *Config) bool { return cfg.LockTimeout == "5m" }, false},
+		{"Boolean key", "review_plans", "true", func(cfg *Config) bool { return cfg.ReviewPlans }, false},
+		{"Optional boolean key", "use_workspaces", "true", func(cfg *Config) bool { return cfg.WorkspacesEnabled() }, false},
+		{"Config version", "config_version", "2.0", func(cfg *Config) bool { return cfg.ConfigVersion == "2.0" }, false},
+		{"Unknown key", "repository", "platform", nil, true},
+		{"Runtime field", "project_dir", "/tmp", nil, true},
+		{"List key", "protected_envs", "prod", nil, true},
+		{"Invalid boolean", "review_plans", "sometimes", nil, true},
+		{"Invalid value", "lock_timeout", "soon", nil, true},
+		{"Unsupported config version", "config_version", "9.9", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{RepoName: "infra", EnvRelPath: "envs", ModuleRelPath: "modules"}
+			err := cfg.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%s, %s) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			}
+			if tt.check != nil && !tt.check(cfg) {
+				t.Errorf("Set(%s, %s) did not update the config: %+v", tt.key, tt.value, cfg)
+			}
+		})
+	}
+
+	if keys := SettableKeys(); !slices.Contains(keys, "workspace_select_env") || slices.Contains(keys, "env_overrides") {
+		t.Errorf("SettableKeys() = %v", keys)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/goccy/go-yaml"
 )
@@ -23,18 +24,11 @@ func ConvertLegacyToYAML(projectDir string) error {
 		return fmt.Errorf("YAML config file already exists at %s", yamlPath)
 	}
 
-	// Load legacy config
-	config := DefaultConfig()
-	config.ProjectDir = projectDir
-	config.ConfigPath = legacyPath
-
-	if err := parseLegacyConfigFile(legacyPath, config); err != nil {
-		return fmt.Errorf("failed to parse legacy config: %w", err)
+	config, err := loadLegacyForConversion(legacyPath)
+	if err != nil {
+		return err
 	}
 
-	// Set version for new format
-	config.ConfigVersion = "2.0"
-
 	// Convert to YAML
 	if err := WriteYAMLConfig(yamlPath, config); err != nil {
 		return fmt.Errorf("failed to write YAML config: %w", err)
@@ -50,24 +44,128 @@ func ConvertLegacyToYAML(projectDir string) error {
 	return nil
 }
 
+// MigrateCheck previews the .tfm.yaml that ConvertLegacyToYAML would write for projectDir,
+// as a diff against the current .tfm.yaml (if any), without writing anything
+func MigrateCheck(projectDir string) (string, error) {
+	legacyPath := filepath.Join(projectDir, ".tfm.conf")
+	yamlPath := filepath.Join(projectDir, ".tfm.yaml")
+
+	if _, err := os.Stat(legacyPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("legacy config file not found at %s", legacyPath)
+	}
+
+	config, err := loadLegacyForConversion(legacyPath)
+	if err != nil {
+		return "", err
+	}
+	rendered, err := RenderYAML(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to render YAML config: %w", err)
+	}
+
+	oldName := "/dev/null"
+	existing, err := os.ReadFile(yamlPath)
+	if err == nil {
+		oldName = yamlPath
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", yamlPath, err)
+	}
+
+	return unifiedDiff(oldName, yamlPath, string(existing), string(rendered)), nil
+}
+
+// loadLegacyForConversion parses a legacy config file and prepares it for the YAML format
+func loadLegacyForConversion(legacyPath string) (*Config, error) {
+	config := DefaultConfig()
+	config.ProjectDir = filepath.Dir(legacyPath)
+	config.ConfigPath = legacyPath
+
+	if err := parseLegacyConfigFile(legacyPath, config); err != nil {
+		return nil, fmt.Errorf("failed to parse legacy config: %w", err)
+	}
+
+	// Set version for new format
+	config.ConfigVersion = "2.0"
+	return config, nil
+}
+
 // WriteYAMLConfig writes a Config struct to a YAML file
 func WriteYAMLConfig(configPath string, config *Config) error {
+	data, err := RenderYAML(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0644)
+}
+
+// RenderYAML returns the .tfm.yaml contents for a Config, header comment included
+func RenderYAML(config *Config) ([]byte, error) {
+	// Keep repo_name: "auto" rather than freezing the derived name
+	repoName := config.RepoName
+	if config.repoNameAuto {
+		repoName = AutoRepoName
+	}
+
 	// Create a clean config struct for YAML output (excluding runtime fields)
 	yamlConfig := struct {
-		ConfigVersion string `yaml:"config_version"`
-		RepoName      string `yaml:"repo_name"`
-		EnvRelPath    string `yaml:"env_rel_path"`
-		ModuleRelPath string `yaml:"module_rel_path"`
+		ConfigVersion         string                 `yaml:"config_version"`
+		RepoName              string                 `yaml:"repo_name"`
+		EnvRelPath            string                 `yaml:"env_rel_path"`
+		ModuleRelPath         string                 `yaml:"module_rel_path"`
+		MinTerraformVersion   string                 `yaml:"min_terraform_version,omitempty"`
+		RedactPatterns        []string               `yaml:"redact_patterns,omitempty"`
+		ProtectedEnvs         []string               `yaml:"protected_envs,omitempty"`
+		ApprovalCommand       string                 `yaml:"approval_command,omitempty"`
+		LockTimeout           string                 `yaml:"lock_timeout,omitempty"`
+		RequireFreshPlan      bool                   `yaml:"require_fresh_plan,omitempty"`
+		ReviewPlans           bool                   `yaml:"review_plans,omitempty"`
+		WorkspaceEnvSeparator string                 `yaml:"workspace_env_separator,omitempty"`
+		UseWorkspaces         *bool                  `yaml:"use_workspaces,omitempty"`
+		WorkspaceSelectEnv    bool                   `yaml:"workspace_select_env,omitempty"`
+		BackendKeyTemplate    string                 `yaml:"backend_key_template,omitempty"`
+		InjectTfmVars         string                 `yaml:"inject_tfm_vars,omitempty"`
+		CheckTfvarsSyntax     bool                   `yaml:"check_tfvars_syntax,omitempty"`
+		PlanDir               string                 `yaml:"plan_dir,omitempty"`
+		ArtifactDir           string                 `yaml:"artifact_dir,omitempty"`
+		PluginCacheDir        string                 `yaml:"plugin_cache_dir,omitempty"`
+		ProviderLockPlatforms []string               `yaml:"provider_lock_platforms,omitempty"`
+		WorkspacePrefix       string                 `yaml:"workspace_prefix,omitempty"`
+		GlobalTerraformFlags  []string               `yaml:"global_terraform_flags,omitempty"`
+		ActionTerraformFlags  map[string][]string    `yaml:"action_terraform_flags,omitempty"`
+		EnvOverrides          map[string]EnvOverride `yaml:"env_overrides,omitempty"`
+		MaskIdentifiers       bool                   `yaml:"mask_identifiers,omitempty"`
 	}{
-		ConfigVersion: config.ConfigVersion,
-		RepoName:      config.RepoName,
-		EnvRelPath:    config.EnvRelPath,
-		ModuleRelPath: config.ModuleRelPath,
+		ConfigVersion:         config.ConfigVersion,
+		RepoName:              repoName,
+		EnvRelPath:            config.EnvRelPath,
+		ModuleRelPath:         config.ModuleRelPath,
+		MinTerraformVersion:   config.MinTerraformVersion,
+		RedactPatterns:        config.RedactPatterns,
+		ProtectedEnvs:         config.ProtectedEnvs,
+		ApprovalCommand:       config.ApprovalCommand,
+		LockTimeout:           config.LockTimeout,
+		RequireFreshPlan:      config.RequireFreshPlan,
+		ReviewPlans:           config.ReviewPlans,
+		WorkspaceEnvSeparator: config.WorkspaceEnvSeparator,
+		UseWorkspaces:         config.UseWorkspaces,
+		WorkspaceSelectEnv:    config.WorkspaceSelectEnv,
+		BackendKeyTemplate:    config.BackendKeyTemplate,
+		InjectTfmVars:         config.InjectTfmVars,
+		CheckTfvarsSyntax:     config.CheckTfvarsSyntax,
+		PlanDir:               config.PlanDir,
+		ArtifactDir:           config.ArtifactDir,
+		PluginCacheDir:        config.PluginCacheDir,
+		ProviderLockPlatforms: config.ProviderLockPlatforms,
+		WorkspacePrefix:       config.WorkspacePrefix,
+		GlobalTerraformFlags:  config.GlobalTerraformFlags,
+		ActionTerraformFlags:  config.ActionTerraformFlags,
+		EnvOverrides:          config.EnvOverrides,
+		MaskIdentifiers:       config.MaskIdentifiers,
 	}
 
 	data, err := yaml.Marshal(yamlConfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Add header comment
@@ -76,15 +174,20 @@ func WriteYAMLConfig(configPath string, config *Config) error {
 
 `
 
-	return os.WriteFile(configPath, append([]byte(header), data...), 0644)
+	return append([]byte(header), data...), nil
 }
 
 // ValidateConfigVersion checks if the config version is supported
 func ValidateConfigVersion(version string) error {
-	switch version {
-	case "", "2.0":
+	if version == "" {
 		return nil
-	default:
-		return fmt.Errorf("unsupported config version: %s (supported: 2.0)", version)
 	}
+
+	supported := SupportedVersions()
+	for _, v := range supported {
+		if v == version {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported config version: %s (supported: %s)", version, strings.Join(supported, ", "))
 }
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of config loading failure; match them with errors.Is
+var (
+	ErrNoProjectDir   = errors.New("project directory not found")
+	ErrConfigNotFound = errors.New("config file not found")
+	ErrInvalidConfig  = errors.New("invalid configuration")
+)
+
+// ConfigError describes why a configuration could not be loaded
+type ConfigError struct {
+	Kind error  // ErrNoProjectDir, ErrConfigNotFound or ErrInvalidConfig
+	Path string // Project directory or config file concerned, when known
+	Err  error  // Underlying cause, may be nil
+
+	message string
+}
+
+// newConfigError returns a ConfigError of kind with a formatted message; a trailing %w
+// argument becomes the underlying cause
+func newConfigError(kind error, path string, format string, args ...any) *ConfigError {
+	wrapped := fmt.Errorf(format, args...)
+	return &ConfigError{Kind: kind, Path: path, Err: errors.Unwrap(wrapped), message: wrapped.Error()}
+}
+
+func (e *ConfigError) Error() string {
+	return e.message
+}
+
+// Unwrap exposes both the failure kind and the underlying cause to errors.Is and errors.As
+func (e *ConfigError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
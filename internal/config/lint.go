@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// LintWarning is a non-fatal configuration issue with a suggested fix
+type LintWarning struct {
+	Field      string
+	Message    string
+	Suggestion string
+}
+
+// Lint returns guidance about deprecated or questionable settings in a loaded configuration.
+// Unlike Validate, none of these prevent tf-manage from running.
+func Lint(cfg *Config) ([]LintWarning, error) {
+	var warnings []LintWarning
+
+	if IsYAMLConfigPath(cfg.ConfigPath) {
+		hasVersion, err := declaresConfigVersion(cfg.ConfigPath)
+		if err != nil {
+			return nil, err
+		}
+		if !hasVersion {
+			warnings = append(warnings, LintWarning{
+				Field:      "config_version",
+				Message:    "config_version is not set, so the schema version is assumed",
+				Suggestion: fmt.Sprintf("add config_version: %q", BaseConfigVersion),
+			})
+		} else if cfg.ConfigVersion != LatestConfigVersion() {
+			warnings = append(warnings, LintWarning{
+				Field:      "config_version",
+				Message:    fmt.Sprintf("config_version %s is older than the latest %s", cfg.ConfigVersion, LatestConfigVersion()),
+				Suggestion: fmt.Sprintf("run 'tf config bump-version %s'", LatestConfigVersion()),
+			})
+		}
+	} else {
+		warnings = append(warnings, LintWarning{
+			Field:      "format",
+			Message:    fmt.Sprintf("%s uses the deprecated legacy bash format", filepath.Base(cfg.ConfigPath)),
+			Suggestion: "run 'tf config convert' to migrate to .tfm.yaml",
+		})
+	}
+
+	for _, path := range []struct{ field, value string }{
+		{"env_rel_path", cfg.EnvRelPath},
+		{"module_rel_path", cfg.ModuleRelPath},
+	} {
+		if len(path.value) > 1 && strings.HasSuffix(path.value, "/") {
+			warnings = append(warnings, LintWarning{
+				Field:      path.field,
+				Message:    fmt.Sprintf("%s %q has a trailing slash", path.field, path.value),
+				Suggestion: fmt.Sprintf("use %q", strings.TrimRight(path.value, "/")),
+			})
+		}
+	}
+
+	if dirName := filepath.Base(cfg.ProjectDir); !cfg.repoNameAuto && cfg.RepoName != dirName {
+		warnings = append(warnings, LintWarning{
+			Field:      "repo_name",
+			Message:    fmt.Sprintf("repo_name %q does not match the project directory %q", cfg.RepoName, dirName),
+			Suggestion: "ignore if intentional; repo_name is part of every workspace name, so renaming it orphans existing workspaces",
+		})
+	}
+
+	return warnings, nil
+}
+
+// declaresConfigVersion reports whether a YAML config file sets config_version explicitly
+func declaresConfigVersion(configPath string) (bool, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return false, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return false, fmt.Errorf("invalid YAML format: %w", err)
+	}
+
+	_, ok := raw["config_version"]
+	return ok, nil
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BaseConfigVersion is the first YAML config schema version
+const BaseConfigVersion = "2.0"
+
+// Migration upgrades a configuration from one config_version to the next
+type Migration struct {
+	From        string
+	To          string
+	Description string
+	Apply       func(cfg *Config) error
+}
+
+// migrations is the ordered chain of schema upgrades, oldest first
+var migrations []Migration
+
+// SupportedVersions returns every config version this build understands, oldest first
+func SupportedVersions() []string {
+	versions := []string{BaseConfigVersion}
+	for _, migration := range migrations {
+		versions = append(versions, migration.To)
+	}
+	return versions
+}
+
+// LatestConfigVersion returns the newest supported config version
+func LatestConfigVersion() string {
+	versions := SupportedVersions()
+	return versions[len(versions)-1]
+}
+
+// MigrateConfig upgrades cfg in place to the target version and returns the applied migrations
+func MigrateConfig(cfg *Config, target string) ([]Migration, error) {
+	if err := ValidateConfigVersion(target); err != nil {
+		return nil, err
+	}
+
+	current := cfg.ConfigVersion
+	if current == "" {
+		current = BaseConfigVersion
+	}
+
+	cmp, err := compareConfigVersions(target, current)
+	if err != nil {
+		return nil, err
+	}
+	if cmp < 0 {
+		return nil, fmt.Errorf("refusing to downgrade config_version from %s to %s", current, target)
+	}
+
+	var applied []Migration
+	for current != target {
+		migration, ok := findMigration(current)
+		if !ok {
+			return applied, fmt.Errorf("no migration path from config_version %s to %s", current, target)
+		}
+		if err := migration.Apply(cfg); err != nil {
+			return applied, fmt.Errorf("migration %s -> %s failed: %w", migration.From, migration.To, err)
+		}
+		applied = append(applied, migration)
+		current = migration.To
+	}
+
+	cfg.ConfigVersion = target
+	return applied, nil
+}
+
+// findMigration returns the migration that starts at version
+func findMigration(version string) (Migration, bool) {
+	for _, migration := range migrations {
+		if migration.From == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// compareConfigVersions compares two "major.minor" versions, returning -1, 0 or 1
+func compareConfigVersions(a, b string) (int, error) {
+	pa, err := parseConfigVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseConfigVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1, nil
+		case pa[i] > pb[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseConfigVersion(version string) ([2]int, error) {
+	var parsed [2]int
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return parsed, fmt.Errorf("invalid config version: %s (expected major.minor)", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid config version: %s (expected major.minor)", version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EnvOverride holds settings that replace the base configuration for a single env
+type EnvOverride struct {
+	ModuleRelPath string `json:"module_rel_path" yaml:"module_rel_path,omitempty"`
+	LockTimeout   string `json:"lock_timeout"    yaml:"lock_timeout,omitempty"`
+	Protected     *bool  `json:"protected"       yaml:"protected,omitempty"`
+}
+
+// envOverrideKeys are the settings an env override may contain
+var envOverrideKeys = map[string]bool{
+	"module_rel_path": true,
+	"lock_timeout":    true,
+	"protected":       true,
+}
+
+// UnmarshalYAML rejects keys that an env override cannot set, so typos do not silently fall back to the base config
+func (o *EnvOverride) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw map[string]interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	var unknown []string
+	for key := range raw {
+		if !envOverrideKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown env_overrides key(s) %s (supported: lock_timeout, module_rel_path, protected)", strings.Join(unknown, ", "))
+	}
+
+	type plain EnvOverride
+	return unmarshal((*plain)(o))
+}
+
+// validateLockTimeout checks a lock_timeout value is a duration terraform accepts
+func validateLockTimeout(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(value); err != nil || d < 0 {
+		return fmt.Errorf("invalid %s %q (expected a duration like 30s or 5m)", field, value)
+	}
+	return nil
+}
+
+// validateEnvOverrides checks every env override holds usable values
+func (c *Config) validateEnvOverrides() error {
+	for env, override := range c.EnvOverrides {
+		if err := validateLockTimeout(fmt.Sprintf("env_overrides.%s.lock_timeout", env), override.LockTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForEnv returns a copy of the configuration with the env's overrides applied
+func (c *Config) ForEnv(env string) *Config {
+	merged := *c
+
+	override, ok := c.EnvOverrides[env]
+	if !ok {
+		return &merged
+	}
+
+	if override.ModuleRelPath != "" {
+		merged.ModuleRelPath = override.ModuleRelPath
+	}
+	if override.LockTimeout != "" {
+		merged.LockTimeout = override.LockTimeout
+	}
+
+	return &merged
+}
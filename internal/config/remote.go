@@ -0,0 +1,171 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Environment variables selecting a remote base config
+const (
+	ConfigURLEnv   = "TFM_CONFIG_URL"
+	ConfigTokenEnv = "TFM_CONFIG_TOKEN"
+)
+
+// remoteConfigTimeout bounds the whole request for a remote config
+const remoteConfigTimeout = 10 * time.Second
+
+// remoteConfigCacheTTL is how long a fetched config is reused before fetching it again
+const remoteConfigCacheTTL = 5 * time.Minute
+
+// maxRemoteConfigSize caps how much of a remote response is read
+const maxRemoteConfigSize = 1 << 20
+
+// remoteConfigCacheDir is where fetched configs are cached; replaced in tests.
+// TFM_CACHE_DIR overrides the default user cache directory.
+var remoteConfigCacheDir = func() (string, error) {
+	dir := os.Getenv("TFM_CACHE_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cacheDir, "tf-manage2")
+	}
+	return filepath.Join(dir, "remote-config"), nil
+}
+
+// allowInsecureConfigURL permits http:// URLs; only tests enable it
+var allowInsecureConfigURL = false
+
+// fetchRemoteConfig downloads the YAML config at rawURL, reusing a recent copy when there
+// is one, and returns the path of the local copy
+func fetchRemoteConfig(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && !(allowInsecureConfigURL && parsed.Scheme == "http")) {
+		return "", fmt.Errorf("invalid %s %q (expected an https:// URL)", ConfigURLEnv, rawURL)
+	}
+
+	cacheDir, err := privateCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+
+	// The token is part of the key so a config fetched with one token is never served for another
+	token := os.Getenv(ConfigTokenEnv)
+	sum := sha256.Sum256([]byte(rawURL + "\x00" + token))
+	cachePath := filepath.Join(cacheDir, fmt.Sprintf("tfm-config-%s.yaml", hex.EncodeToString(sum[:16])))
+	if trustedCacheFile(cachePath) {
+		return cachePath, nil
+	}
+
+	request, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %w", ConfigURLEnv, err)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: remoteConfigTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch remote config: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch remote config from %s: %s", parsed.Redacted(), response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxRemoteConfigSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read remote config: %w", err)
+	}
+
+	// Write atomically so a concurrent run never parses a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), filepath.Base(cachePath)+".*")
+	if err != nil {
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return "", fmt.Errorf("failed to cache remote config: %w", err)
+	}
+
+	return cachePath, nil
+}
+
+// privateCacheDir creates the remote config cache directory if needed and makes sure only
+// the current user can write to it, since a cached config decides which commands run
+func privateCacheDir() (string, error) {
+	dir, err := remoteConfigCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || !ownedByCurrentUser(info) {
+		return "", fmt.Errorf("%s is not a directory owned by the current user", dir)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// trustedCacheFile reports whether path is a recent cached config that only the current
+// user could have written
+func trustedCacheFile(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular() &&
+		ownedByCurrentUser(info) &&
+		info.Mode().Perm()&0022 == 0 &&
+		time.Since(info.ModTime()) < remoteConfigCacheTTL
+}
+
+// ownedByCurrentUser reports whether info describes a file owned by the current user
+func ownedByCurrentUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// loadRemoteConfig loads the config served at rawURL for the project in projectDir
+func loadRemoteConfig(projectDir, rawURL string) (*Config, error) {
+	configPath, err := fetchRemoteConfig(rawURL)
+	if err != nil {
+		return nil, newConfigError(ErrConfigNotFound, "", "%w", err)
+	}
+
+	config, err := loadConfigAt(projectDir, configPath)
+	if err != nil {
+		return nil, err
+	}
+	config.ConfigURL = rawURL
+	return config, nil
+}
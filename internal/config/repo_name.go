@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// AutoRepoName as repo_name derives the name from the git remote at load time
+const AutoRepoName = "auto"
+
+// gitRemoteURL returns the origin remote URL of the repository in projectDir; replaced in tests
+var gitRemoteURL = func(projectDir string) string {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := framework.RunCmd(
+		fmt.Sprintf("git -C \"%s\" config --get remote.origin.url", projectDir),
+		"Reading git remote",
+		flags,
+	)
+	if !result.Success {
+		return ""
+	}
+	return strings.TrimSpace(result.Output)
+}
+
+// resolveRepoName replaces repo_name "auto" with the name of the git remote, falling back
+// to the project directory name when there is no usable remote
+func (c *Config) resolveRepoName() {
+	if c.RepoName != AutoRepoName {
+		return
+	}
+
+	c.repoNameAuto = true
+	if name := repoNameFromURL(gitRemoteURL(c.ProjectDir)); name != "" {
+		c.RepoName = name
+		return
+	}
+	c.RepoName = filepath.Base(c.ProjectDir)
+}
+
+// repoNameFromURL returns the last path segment of a git remote URL without its .git suffix.
+// It handles HTTPS (https://host/org/repo.git), SSH (ssh://git@host/org/repo.git) and
+// scp-like (git@host:org/repo.git) remotes.
+func repoNameFromURL(url string) string {
+	url = strings.TrimRight(strings.TrimSpace(url), "/")
+	if idx := strings.LastIndexAny(url, "/:"); idx >= 0 {
+		url = url[idx+1:]
+	}
+	return strings.TrimSuffix(url, ".git")
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SettableKeys returns the config keys 'tf config set' accepts, in Config field order
+func SettableKeys() []string {
+	var keys []string
+	configType := reflect.TypeOf(Config{})
+	for i := range configType.NumField() {
+		field := configType.Field(i)
+		if key, ok := yamlKey(field); ok && settableKind(field.Type) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Set updates the field stored under key from its text form and validates the result.
+// Only string and boolean settings can be set; lists and maps are edited in the file.
+func (c *Config) Set(key, value string) error {
+	field, ok := c.fieldByKey(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q (known keys: %s)", key, strings.Join(SettableKeys(), ", "))
+	}
+	if !settableKind(field.Type()) {
+		return fmt.Errorf("%s is a %s; edit it in the config file", key, field.Kind())
+	}
+
+	switch key {
+	case "config_version":
+		if err := ValidateConfigVersion(value); err != nil {
+			return err
+		}
+	case "repo_name":
+		c.repoNameAuto = value == AutoRepoName
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q (expected true or false)", key, value)
+		}
+		field.SetBool(enabled)
+	case reflect.Pointer:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q (expected true or false)", key, value)
+		}
+		field.Set(reflect.ValueOf(&enabled))
+	}
+
+	return c.Validate()
+}
+
+// fieldByKey returns the settable field whose yaml key is key
+func (c *Config) fieldByKey(key string) (reflect.Value, bool) {
+	value := reflect.ValueOf(c).Elem()
+	for i := range value.NumField() {
+		if name, ok := yamlKey(value.Type().Field(i)); ok && name == key {
+			return value.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// yamlKey returns the key a field is written under in .tfm.yaml
+func yamlKey(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// settableKind reports whether a field can be set from a single command-line value
+func settableKind(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Bool:
+		return true
+	case reflect.Pointer:
+		return fieldType.Elem().Kind() == reflect.Bool
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// managedTerraformFlags are set by tf-manage itself and cannot be configured
+var managedTerraformFlags = []string{"-var-file", "-var", "-out"}
+
+// TerraformFlagsFor returns the configured terraform flags for action: the global flags
+// followed by the action's own flags
+func (c *Config) TerraformFlagsFor(action string) []string {
+	flags := append([]string{}, c.GlobalTerraformFlags...)
+	return append(flags, c.ActionTerraformFlags[action]...)
+}
+
+// validateTerraformFlags checks configured terraform flags are flags and do not replace managed ones
+func (c *Config) validateTerraformFlags() error {
+	if err := validateFlagList("global_terraform_flags", c.GlobalTerraformFlags); err != nil {
+		return err
+	}
+	for action, flags := range c.ActionTerraformFlags {
+		if err := validateFlagList(fmt.Sprintf("action_terraform_flags.%s", action), flags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFlagList(field string, flags []string) error {
+	for _, flag := range flags {
+		if !strings.HasPrefix(flag, "-") || strings.ContainsAny(flag, " \t") {
+			return fmt.Errorf("invalid %s entry %q (expected a single flag like -no-color)", field, flag)
+		}
+		name, _, _ := strings.Cut(flag, "=")
+		for _, managed := range managedTerraformFlags {
+			if name == managed {
+				return fmt.Errorf("invalid %s entry %q: %s is managed by tf-manage", field, flag, managed)
+			}
+		}
+	}
+	return nil
+}
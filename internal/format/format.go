@@ -0,0 +1,78 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Result is the common shape handed to every formatter
+type Result struct {
+	Title   string     // Optional heading (used by human-readable formats)
+	Columns []string   // Column names for tabular formats
+	Rows    [][]string // Row values, one entry per column
+	Data    any        // Optional structured payload preferred by structured formats
+}
+
+// Formatter writes a Result to an io.Writer in a specific format
+type Formatter interface {
+	Format(w io.Writer, result *Result) error
+}
+
+// FormatterFunc adapts a plain function to the Formatter interface
+type FormatterFunc func(w io.Writer, result *Result) error
+
+// Format calls f(w, result)
+func (f FormatterFunc) Format(w io.Writer, result *Result) error {
+	return f(w, result)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Formatter{}
+)
+
+// Register makes a formatter available under the given name, replacing any previous one
+func Register(name string, formatter Formatter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = formatter
+}
+
+// Get returns the formatter registered under name
+func Get(name string) (Formatter, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	formatter, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %s (supported: %v)", name, namesLocked())
+	}
+	return formatter, nil
+}
+
+// Names returns the sorted list of registered format names
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return namesLocked()
+}
+
+func namesLocked() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Write formats result with the named formatter
+func Write(w io.Writer, name string, result *Result) error {
+	formatter, err := Get(name)
+	if err != nil {
+		return err
+	}
+	return formatter.Format(w, result)
+}
@@ -0,0 +1,106 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func sampleResult() *Result {
+	return &Result{
+		Title:   "Instances",
+		Columns: []string{"env", "instance"},
+		Rows: [][]string{
+			{"dev", "instance_x"},
+			{"prod", "instance|y"},
+		},
+	}
+}
+
+func TestRegistryDispatch(t *testing.T) {
+	called := false
+	Register("test-format", FormatterFunc(func(w io.Writer, result *Result) error {
+		called = true
+		_, err := io.WriteString(w, result.Title)
+		return err
+	}))
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "test-format", sampleResult()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !called || buf.String() != "Instances" {
+		t.Errorf("Expected registered formatter to be dispatched, got %q", buf.String())
+	}
+
+	found := false
+	for _, name := range Names() {
+		if name == "test-format" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected test-format in Names(): %v", Names())
+	}
+
+	if err := Write(&buf, "does-not-exist", sampleResult()); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "json", sampleResult()); err != nil {
+		t.Fatalf("json formatter failed: %v", err)
+	}
+
+	var records []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, buf.String())
+	}
+	if len(records) != 2 || records[1]["instance"] != "instance|y" {
+		t.Errorf("Unexpected records: %v", records)
+	}
+
+	// Data takes precedence over rows
+	buf.Reset()
+	if err := Write(&buf, "json", &Result{Data: map[string]int{"add": 1}}); err != nil {
+		t.Fatalf("json formatter failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"add": 1`) {
+		t.Errorf("Expected Data payload in output: %s", buf.String())
+	}
+}
+
+func TestCSVFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "csv", sampleResult()); err != nil {
+		t.Fatalf("csv formatter failed: %v", err)
+	}
+
+	expected := "env,instance\ndev,instance_x\nprod,instance|y\n"
+	if buf.String() != expected {
+		t.Errorf("csv output = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestMarkdownFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "markdown", sampleResult()); err != nil {
+		t.Fatalf("markdown formatter failed: %v", err)
+	}
+
+	expected := []string{
+		"### Instances",
+		"| env | instance |",
+		"| --- | --- |",
+		"| prod | instance\\|y |",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("Expected %q in markdown output:\n%s", exp, buf.String())
+		}
+	}
+}
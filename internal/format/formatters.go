@@ -0,0 +1,95 @@
+package format
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	Register("text", FormatterFunc(formatText))
+	Register("json", FormatterFunc(formatJSON))
+	Register("csv", FormatterFunc(formatCSV))
+	Register("markdown", FormatterFunc(formatMarkdown))
+}
+
+// formatText writes an aligned, human-readable table
+func formatText(w io.Writer, result *Result) error {
+	if result.Title != "" {
+		fmt.Fprintln(w, result.Title)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(result.Columns) > 0 {
+		fmt.Fprintln(tw, strings.Join(result.Columns, "\t"))
+	}
+	for _, row := range result.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatJSON writes Data when set, otherwise the rows as a list of column-keyed objects
+func formatJSON(w io.Writer, result *Result) error {
+	payload := result.Data
+	if payload == nil {
+		records := make([]map[string]string, 0, len(result.Rows))
+		for _, row := range result.Rows {
+			record := make(map[string]string, len(result.Columns))
+			for i, column := range result.Columns {
+				if i < len(row) {
+					record[column] = row[i]
+				}
+			}
+			records = append(records, record)
+		}
+		payload = records
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(payload)
+}
+
+// formatCSV writes a header line followed by one line per row
+func formatCSV(w io.Writer, result *Result) error {
+	writer := csv.NewWriter(w)
+	if len(result.Columns) > 0 {
+		if err := writer.Write(result.Columns); err != nil {
+			return err
+		}
+	}
+	if err := writer.WriteAll(result.Rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// formatMarkdown writes a GitHub-flavored markdown table
+func formatMarkdown(w io.Writer, result *Result) error {
+	if result.Title != "" {
+		fmt.Fprintf(w, "### %s\n\n", result.Title)
+	}
+	if len(result.Columns) == 0 {
+		return nil
+	}
+
+	separators := make([]string, len(result.Columns))
+	for i := range separators {
+		separators[i] = "---"
+	}
+
+	fmt.Fprintf(w, "| %s |\n", strings.Join(result.Columns, " | "))
+	fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+	for _, row := range result.Rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.ReplaceAll(cell, "|", "\\|")
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+	return nil
+}
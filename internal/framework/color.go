@@ -0,0 +1,44 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// Color modes accepted by SetColorMode
+const (
+	ColorAuto   = "auto"   // Color when stderr is a terminal and NO_COLOR is not set
+	ColorAlways = "always" // Always color, even with NO_COLOR set
+	ColorNever  = "never"  // Never color
+)
+
+// colorEnabled reports whether the AddEmphasis helpers emit ANSI codes (see SetColorMode)
+var colorEnabled atomic.Bool
+
+func init() {
+	SetColorMode(ColorAuto)
+}
+
+// SetColorMode selects when tf-manage colors its own output: auto, always or never
+func SetColorMode(mode string) error {
+	switch mode {
+	case ColorAuto:
+		colorEnabled.Store(os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(os.Stderr))
+	case ColorAlways:
+		colorEnabled.Store(true)
+	case ColorNever:
+		colorEnabled.Store(false)
+	default:
+		return fmt.Errorf("invalid color mode %q (expected %s, %s or %s)", mode, ColorAuto, ColorAlways, ColorNever)
+	}
+	return nil
+}
+
+// colorize wraps text in the given ANSI color when color is enabled
+func colorize(color, text string) string {
+	if !colorEnabled.Load() {
+		return text
+	}
+	return color + text + Reset
+}
@@ -0,0 +1,48 @@
+package framework
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSetColorMode(t *testing.T) {
+	originalIsTerminal := isTerminal
+	t.Cleanup(func() {
+		isTerminal = originalIsTerminal
+		SetColorMode(ColorAuto)
+	})
+
+	colored := Blue + "dev" + Reset
+	tests := []struct {
+		name    string
+		mode    string
+		tty     bool
+		noColor string
+		want    string
+	}{
+		{"Auto on a terminal", ColorAuto, true, "", colored},
+		{"Auto when piped", ColorAuto, false, "", "dev"},
+		{"Auto respects NO_COLOR", ColorAuto, true, "1", "dev"},
+		{"Always when piped", ColorAlways, false, "", colored},
+		{"Always overrides NO_COLOR", ColorAlways, false, "1", colored},
+		{"Never on a terminal", ColorNever, true, "", "dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isTerminal = func(*os.File) bool { return tt.tty }
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("TERM", "xterm")
+
+			if err := SetColorMode(tt.mode); err != nil {
+				t.Fatalf("SetColorMode(%q) error: %v", tt.mode, err)
+			}
+			if got := AddEmphasisBlue("dev"); got != tt.want {
+				t.Errorf("AddEmphasisBlue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := SetColorMode("sometimes"); err == nil {
+		t.Error("Expected an invalid color mode to be rejected")
+	}
+}
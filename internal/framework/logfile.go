@@ -0,0 +1,138 @@
+package framework
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+)
+
+// transcript receives a copy of everything written through Stdout and Stderr (see OpenLogFile)
+var (
+	transcriptMu sync.Mutex
+	transcript   *logFile
+)
+
+// logFile writes complete lines to a file with ANSI escape sequences removed. Each stream
+// keeps its own partial line so interleaved stdout and stderr writes are not mixed up.
+type logFile struct {
+	mu      sync.Mutex
+	file    *os.File
+	pending map[string][]byte
+	err     error
+}
+
+// logStream is the io.Writer for one output stream of a logFile
+type logStream struct {
+	log  *logFile
+	name string
+}
+
+func (s logStream) Write(p []byte) (int, error) {
+	s.log.mu.Lock()
+	defer s.log.mu.Unlock()
+
+	buf := append(s.log.pending[s.name], p...)
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		s.log.write(buf[:i+1])
+		buf = buf[i+1:]
+	}
+	s.log.pending[s.name] = append([]byte(nil), buf...)
+
+	// The terminal copy must never fail because of the transcript
+	return len(p), nil
+}
+
+// write appends stripped text to the file, keeping the first error for Close
+func (l *logFile) write(text []byte) {
+	if l.err != nil {
+		return
+	}
+	_, l.err = io.WriteString(l.file, stripAnsiCodes(string(text)))
+}
+
+// close writes any unterminated lines and closes the file
+func (l *logFile) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, name := range []string{"stdout", "stderr"} {
+		if len(l.pending[name]) > 0 {
+			l.write(append(l.pending[name], '\n'))
+			delete(l.pending, name)
+		}
+	}
+	if err := l.file.Close(); l.err == nil {
+		l.err = err
+	}
+	return l.err
+}
+
+// OpenLogFile starts copying all output written through Stdout and Stderr, including
+// terraform's, to the file at path with colors removed. The file is truncated first.
+// Call CloseLogFile to flush and close it.
+func OpenLogFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	transcriptMu.Lock()
+	previous := transcript
+	transcript = &logFile{file: file, pending: map[string][]byte{}}
+	transcriptMu.Unlock()
+
+	if previous != nil {
+		previous.close()
+	}
+	return nil
+}
+
+// CloseLogFile flushes and closes the file opened by OpenLogFile. It is safe to call
+// when no log file is open.
+func CloseLogFile() error {
+	transcriptMu.Lock()
+	current := transcript
+	transcript = nil
+	transcriptMu.Unlock()
+
+	if current == nil {
+		return nil
+	}
+	return current.close()
+}
+
+// LogFileOpen reports whether a log file opened by OpenLogFile is still open
+func LogFileOpen() bool {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	return transcript != nil
+}
+
+// Stdout returns the writer for standard output, teeing to the log file when one is open
+func Stdout() io.Writer {
+	return teeTo(os.Stdout, "stdout")
+}
+
+// Stderr returns the writer for standard error, teeing to the log file when one is open
+func Stderr() io.Writer {
+	return teeTo(os.Stderr, "stderr")
+}
+
+// teeTo resolves the stream at call time so redirections of os.Stdout (e.g. --json) apply.
+// The log file comes first so a failing terminal write does not drop the transcript.
+func teeTo(stream *os.File, name string) io.Writer {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+
+	if transcript == nil {
+		return stream
+	}
+	return io.MultiWriter(logStream{log: transcript, name: name}, stream)
+}
+
+// exit closes the log file before terminating the process, since deferred calls do not run
+func exit(code int) {
+	CloseLogFile()
+	os.Exit(code)
+}
@@ -0,0 +1,88 @@
+package framework
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogFile(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "terraform.sh")
+	body := "printf '\\033[32mPlan:\\033[0m 1 to add\\n'\nprintf 'Enter a value: ' >&2\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatalf("Failed to write script: %v", err)
+	}
+	logPath := filepath.Join(dir, "run.log")
+
+	// Keep the terminal copy out of the test output
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+	originalStdout, originalStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	defer func() { os.Stdout, os.Stderr = originalStdout, originalStderr }()
+
+	if err := OpenLogFile(logPath); err != nil {
+		t.Fatalf("OpenLogFile() error = %v", err)
+	}
+	defer CloseLogFile()
+	if !LogFileOpen() {
+		t.Error("LogFileOpen() = false after OpenLogFile")
+	}
+
+	Info("Running " + AddEmphasisBlue("plan"))
+
+	flags := DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true
+	RunCmd("sh "+script, "Decorated", flags)
+
+	// Interactive commands are teed too, and an unterminated prompt is kept on close
+	flags.DecorateOutput = false
+	RunCmd("sh "+script, "Interactive", flags)
+
+	if err := CloseLogFile(); err != nil {
+		t.Fatalf("CloseLogFile() error = %v", err)
+	}
+	if LogFileOpen() {
+		t.Error("LogFileOpen() = true after CloseLogFile")
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	want := "[" + GetEntrypointScript() + "] Running plan\n" +
+		"[cmd] Plan: 1 to add\n" +
+		"[err] Enter a value: \n" +
+		"Plan: 1 to add\n" +
+		"Enter a value: \n"
+	if string(data) != want {
+		t.Errorf("Log file contents:\n%q\nwant:\n%q", data, want)
+	}
+
+	// Output after closing is no longer recorded
+	Info("after close")
+	if after, _ := os.ReadFile(logPath); string(after) != want {
+		t.Errorf("Log file changed after CloseLogFile: %q", after)
+	}
+}
+
+func TestOpenLogFileError(t *testing.T) {
+	if err := OpenLogFile(filepath.Join(t.TempDir(), "missing", "run.log")); err == nil {
+		CloseLogFile()
+		t.Fatal("Expected an error for a log file in a missing directory")
+	}
+	if err := CloseLogFile(); err != nil {
+		t.Errorf("CloseLogFile() without an open log file error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package framework
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNativePathChecks(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "hook.sh")
+	plain := filepath.Join(dir, "backend.hcl")
+	locked := filepath.Join(dir, "secret.tfvars")
+	link := filepath.Join(dir, "current")
+	dangling := filepath.Join(dir, "dangling")
+	missing := filepath.Join(dir, "missing")
+
+	for path, mode := range map[string]os.FileMode{script: 0755, plain: 0644, locked: 0000} {
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatalf("Failed to create %s: %v", path, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("Failed to chmod %s: %v", path, err)
+		}
+	}
+	if err := os.Symlink(script, link); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := os.Symlink(missing, dangling); err != nil {
+		t.Fatalf("Failed to create dangling symlink: %v", err)
+	}
+
+	type checkCase struct {
+		name      string
+		check     NativeFunc
+		success   bool
+		wantError string
+	}
+	tests := []checkCase{
+		{"Symlink", NativeTestSymlink(link), true, ""},
+		{"Dangling symlink", NativeTestSymlink(dangling), true, ""},
+		{"Regular file is not a symlink", NativeTestSymlink(script), false, "not a symlink"},
+		{"Missing symlink", NativeTestSymlink(missing), false, "does not exist"},
+		{"Executable", NativeTestExecutable(script), true, ""},
+		{"Executable through symlink", NativeTestExecutable(link), true, ""},
+		{"Not executable", NativeTestExecutable(plain), false, "not executable"},
+		{"Directory is not executable file", NativeTestExecutable(dir), false, "is a directory"},
+		{"Missing executable", NativeTestExecutable(missing), false, "does not exist"},
+		{"Readable", NativeTestReadable(plain), true, ""},
+		{"Missing readable", NativeTestReadable(missing), false, "does not exist"},
+		{"Dangling symlink is not readable", NativeTestReadable(dangling), false, "does not exist"},
+	}
+
+	// Permission bits do not stop root from reading
+	if os.Geteuid() != 0 {
+		tests = append(tests, checkCase{"Unreadable", NativeTestReadable(locked), false, "not readable"})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.check()
+			if result.Success != tt.success {
+				t.Fatalf("Success = %v, want %v (error: %s)", result.Success, tt.success, result.Error)
+			}
+			if tt.success && result.ExitCode != 0 {
+				t.Errorf("ExitCode = %d, want 0", result.ExitCode)
+			}
+			if !tt.success && (result.ExitCode != 1 || !strings.Contains(result.Error, tt.wantError)) {
+				t.Errorf("Got exit code %d and error %q, want 1 and %q", result.ExitCode, result.Error, tt.wantError)
+			}
+		})
+	}
+}
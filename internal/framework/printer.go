@@ -5,6 +5,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Color constants for ANSI escape codes
@@ -17,7 +21,7 @@ const (
 	Gray    = "\033[30;1m"
 )
 
-// Visual indicators
+// Default status indicators (see SetASCII)
 const (
 	CheckMark = "\u2713" // ✓
 	CrossMark = "\u2717" // ✗
@@ -25,23 +29,23 @@ const (
 
 // Color formatting functions
 func AddEmphasisBlue(text string) string {
-	return Blue + text + Reset
+	return colorize(Blue, text)
 }
 
 func AddEmphasisRed(text string) string {
-	return Red + text + Reset
+	return colorize(Red, text)
 }
 
 func AddEmphasisGreen(text string) string {
-	return Green + text + Reset
+	return colorize(Green, text)
 }
 
 func AddEmphasisMagenta(text string) string {
-	return Magenta + text + Reset
+	return colorize(Magenta, text)
 }
 
 func AddEmphasisGray(text string) string {
-	return Gray + text + Reset
+	return colorize(Gray, text)
 }
 
 // GetEntrypointScript returns the name of the main executable
@@ -52,22 +56,106 @@ func GetEntrypointScript() string {
 	return "tf"
 }
 
+var (
+	masksMu     sync.RWMutex
+	masks       = map[string]string{} // sensitive value -> replacement
+	maskPattern *regexp.Regexp
+)
+
+// Mask registers a value that must be replaced with replacement in everything printed
+// by this package. Command execution is unaffected and still sees the real value.
+func Mask(value, replacement string) {
+	if value == "" {
+		return
+	}
+
+	masksMu.Lock()
+	defer masksMu.Unlock()
+
+	masks[value] = replacement
+
+	// Match longer values first so overlapping identifiers are masked whole,
+	// and replace in a single pass so replacements are never masked again
+	values := make([]string, 0, len(masks))
+	for v := range masks {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	for i, v := range values {
+		values[i] = regexp.QuoteMeta(v)
+	}
+	maskPattern = regexp.MustCompile(`\b(?:` + strings.Join(values, "|") + `)\b`)
+}
+
+// ClearMasks removes all registered masks
+func ClearMasks() {
+	masksMu.Lock()
+	defer masksMu.Unlock()
+	masks = map[string]string{}
+	maskPattern = nil
+}
+
+// MaskText applies all registered masks to text
+func MaskText(text string) string {
+	masksMu.RLock()
+	defer masksMu.RUnlock()
+
+	if maskPattern == nil {
+		return text
+	}
+	return maskPattern.ReplaceAllStringFunc(text, func(match string) string {
+		return masks[match]
+	})
+}
+
+// quiet suppresses informational output when set (see SetQuiet)
+var quiet atomic.Bool
+
+func init() {
+	quiet.Store(os.Getenv("TFM_QUIET") != "")
+}
+
+// SetQuiet enables or disables quiet mode. In quiet mode Info messages and successful
+// status lines are suppressed; errors, prompts and command output are still printed.
+func SetQuiet(enabled bool) {
+	quiet.Store(enabled)
+}
+
+// IsQuiet reports whether quiet mode is enabled
+func IsQuiet() bool {
+	return quiet.Load()
+}
+
 // Info prints an info message with consistent formatting
 func Info(message string) {
+	if IsQuiet() {
+		return
+	}
 	format := AddEmphasisGray(fmt.Sprintf("[%s]", GetEntrypointScript())) + " %s\n"
-	fmt.Fprintf(os.Stderr, format, message)
+	fmt.Fprintf(Stderr(), format, MaskText(message))
 }
 
 // Error prints an error message with consistent formatting
 func Error(message string) {
 	format := AddEmphasisRed(fmt.Sprintf("[%s]", GetEntrypointScript())) + " %s\n"
-	fmt.Fprintf(os.Stderr, format, message)
+	fmt.Fprintf(Stderr(), format, MaskText(message))
+}
+
+// Prompt prints a prompt message without a trailing newline so input follows on the same line
+func Prompt(message string) {
+	format := AddEmphasisGray(fmt.Sprintf("[%s]", GetEntrypointScript())) + " %s "
+	fmt.Fprintf(Stderr(), format, MaskText(message))
 }
 
 // Debug prints a debug message (only if debug is enabled)
 func Debug(message string) {
 	if os.Getenv("TFM_DEBUG") != "" {
-		fmt.Fprintf(os.Stderr, "[DEBUG] %s\n", message)
+		fmt.Fprintf(Stderr(), "[DEBUG] %s\n", MaskText(message))
 	}
 }
 
@@ -79,7 +167,7 @@ func stripAnsiCodes(str string) string {
 	return ansiRegex.ReplaceAllString(str, "")
 }
 
-// getVisualLength returns the visual length of a string (excluding ANSI codes)
+// getVisualLength returns the number of terminal columns a string occupies (excluding ANSI codes)
 func getVisualLength(str string) int {
-	return len(stripAnsiCodes(str))
+	return displayWidth(stripAnsiCodes(str))
 }
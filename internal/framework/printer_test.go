@@ -0,0 +1,62 @@
+package framework
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// captureStderr captures stderr during function execution
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+
+	originalStderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = originalStderr
+	}()
+
+	fn()
+
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read captured stderr: %v", err)
+	}
+	return string(data)
+}
+
+func TestQuietMode(t *testing.T) {
+	defer SetQuiet(false)
+
+	SetQuiet(true)
+	output := captureStderr(t, func() {
+		Info("Detected exec mode: operator")
+		parseStatus("Checking product is valid", &CmdResult{Success: true}, DefaultCmdFlags())
+	})
+	if output != "" {
+		t.Errorf("Expected no informational output in quiet mode, got %q", output)
+	}
+
+	output = captureStderr(t, func() {
+		Error("Terraform plan failed")
+		parseStatus("Checking module exists", &CmdResult{Success: false, ExitCode: 1}, DefaultCmdFlags())
+	})
+	if !strings.Contains(output, "Terraform plan failed") || !strings.Contains(output, "Checking module exists") {
+		t.Errorf("Expected errors and failed status in quiet mode, got %q", output)
+	}
+
+	SetQuiet(false)
+	output = captureStderr(t, func() {
+		Info("Detected exec mode: operator")
+	})
+	if !strings.Contains(output, "Detected exec mode") {
+		t.Errorf("Expected info output when not quiet, got %q", output)
+	}
+}
@@ -0,0 +1,68 @@
+package framework
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// RedactedText replaces secrets matched by a redaction pattern
+const RedactedText = "***"
+
+var (
+	redactMu       sync.RWMutex
+	redactPatterns []*regexp.Regexp
+)
+
+// SetRedactPatterns replaces the patterns used to hide secrets in echoed commands and
+// command output. When a pattern has capture groups only the groups are replaced,
+// otherwise the whole match is.
+func SetRedactPatterns(patterns []string) error {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid redact pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	redactPatterns = compiled
+	return nil
+}
+
+// Redact hides every match of the configured redaction patterns in text
+func Redact(text string) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	for _, re := range redactPatterns {
+		text = redactWith(re, text)
+	}
+	return text
+}
+
+func redactWith(re *regexp.Regexp, text string) string {
+	if re.NumSubexp() == 0 {
+		return re.ReplaceAllLiteralString(text, RedactedText)
+	}
+
+	// Replace only the captured groups, keeping the surrounding context
+	var out []byte
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(text, -1) {
+		for group := 1; group <= re.NumSubexp(); group++ {
+			start, end := match[2*group], match[2*group+1]
+			if start < last || start < 0 {
+				continue
+			}
+			out = append(out, text[last:start]...)
+			out = append(out, RedactedText...)
+			last = end
+		}
+	}
+	out = append(out, text[last:]...)
+	return string(out)
+}
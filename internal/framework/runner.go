@@ -2,13 +2,42 @@ package framework
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// DefaultGracePeriod is how long a timed out command may take to exit after being interrupted
+const DefaultGracePeriod = 10 * time.Second
+
+// TimeoutExitCode is reported when a command is stopped because it exceeded its timeout
+const TimeoutExitCode = 124
+
+// NotFoundExitCode is reported when the command's executable is not on PATH (as in shells)
+const NotFoundExitCode = 127
+
+// strictExit makes strict mode failures terminate the process (see SetStrictExit)
+var strictExit atomic.Bool
+
+func init() {
+	strictExit.Store(true)
+}
+
+// SetStrictExit controls whether strict mode failures exit the process and returns the
+// previous setting. When disabled the failure is only reported through the CmdResult,
+// so code embedding tf-manage keeps control of the process.
+func SetStrictExit(enabled bool) bool {
+	return strictExit.Swap(enabled)
+}
+
 // CmdFlags represents the configuration flags for command execution
 type CmdFlags struct {
 	Strict          bool   // Whether to exit on command failure
@@ -21,6 +50,16 @@ type CmdFlags struct {
 	StrictMessage   string // Message to show in strict mode on failure
 	NoStrictMessage string // Message to show in non-strict mode on failure
 	ValidExitCodes  []int  // List of valid exit codes (default: [0])
+	DisplayCommand  string // Text echoed instead of the command when PrintCmd is set (e.g. redacted)
+
+	CaptureCombined bool // Whether to also capture stdout and stderr as a single time-ordered stream
+	Spinner         bool // Whether to animate a spinner on stderr while a silent command runs (terminals only)
+
+	Timeout     time.Duration // Maximum run time before the command is interrupted (0: no limit)
+	GracePeriod time.Duration // Time allowed to exit after interrupt before killing (default: DefaultGracePeriod)
+
+	Dir string   // Working directory of the command (default: the process's working directory)
+	Env []string // KEY=VALUE entries added to the process environment for this command only
 }
 
 // DefaultCmdFlags returns the default command flags
@@ -34,7 +73,7 @@ func DefaultCmdFlags() *CmdFlags {
 		PrintStatus:     true,
 		PrintOutcome:    false,
 		StrictMessage:   "aborting...",
-		NoStrictMessage: "continuing...",
+		NoStrictMessage: getStatusMarks().continuing,
 		ValidExitCodes:  []int{0},
 	}
 }
@@ -45,6 +84,9 @@ type CmdResult struct {
 	Success  bool
 	Output   string
 	Error    string
+	Combined string // Interleaved stdout and stderr in arrival order (only with CaptureCombined)
+	TimedOut bool   // The command exceeded CmdFlags.Timeout
+	Graceful bool   // A timed out command exited on interrupt, without being killed
 }
 
 // RunCmd executes a system command with the specified flags and message
@@ -60,11 +102,17 @@ func RunCmd(command, message string, flags *CmdFlags, failMessage ...string) *Cm
 
 	// Print the command if enabled
 	if flags.PrintCmd {
-		Info(command)
+		if flags.DisplayCommand != "" {
+			Info(Redact(flags.DisplayCommand))
+		} else {
+			Info(Redact(command))
+		}
 	}
 
 	// Execute the system command
+	spin := startSpinner(message, flags)
 	result := execSystemCommand(command, flags)
+	spin.Stop()
 
 	// Parse and display status
 	parseStatus(message, result, flags, failMessage...)
@@ -107,7 +155,8 @@ func RunCmdSilentStrict(command, message string, failMessage ...string) *CmdResu
 }
 
 // RunCmdInteractive executes a command that requires user interaction (stdin)
-// It automatically disables decoration to ensure stdin works properly
+// It automatically disables decoration to ensure stdin works properly.
+// A first Ctrl-C lets the command cancel gracefully; a second one kills it.
 func RunCmdInteractive(command, message string, failMessage ...string) *CmdResult {
 	flags := DefaultCmdFlags()
 	flags.DecorateOutput = false // Disable decoration for interactive commands
@@ -185,7 +234,10 @@ func execSystemCommand(command string, flags *CmdFlags) *CmdResult {
 		}
 	}
 
+	traceCommand(program, args, flags)
+
 	cmd := exec.Command(program, args...)
+	cmd.Dir = flags.Dir
 	return execCommand(cmd, flags)
 }
 
@@ -196,10 +248,34 @@ type outputLine struct {
 	decorate bool
 }
 
+// combinedBuffer collects lines from both output streams in the order they were read
+type combinedBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (c *combinedBuffer) writeLine(line string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.WriteString(line + "\n")
+}
+
+func (c *combinedBuffer) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
 // execCommand is the common execution function for both direct and shell commands
 func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	var output strings.Builder
 	var errorOutput strings.Builder
+	var combined combinedBuffer
+
+	// Later entries win, so the extra variables override inherited ones
+	if len(flags.Env) > 0 {
+		cmd.Env = append(os.Environ(), flags.Env...)
+	}
 
 	// For interactive commands, connect pipes differently to handle unbuffered output
 	isInteractive := !flags.DecorateOutput
@@ -207,20 +283,21 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	if isInteractive {
 		// Interactive mode: pass through stdout/stderr directly and capture in background
 		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd.Stdout = Stdout()
+		cmd.Stderr = Stderr()
 
 		// Start the command
 		if err := cmd.Start(); err != nil {
-			return &CmdResult{
-				ExitCode: 1,
-				Success:  false,
-				Error:    err.Error(),
-			}
+			return startFailure(cmd, err)
 		}
 
 		// Wait for the command to complete
-		err := cmd.Wait()
+		stopInterrupts := handleInterrupts(cmd)
+		untrack := trackProcess(cmd, true)
+		outcome := waitForCommand(cmd, flags, nil)
+		untrack()
+		stopInterrupts()
+		err := outcome.err
 
 		exitCode := 0
 		if err != nil {
@@ -230,6 +307,9 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 				exitCode = 1
 			}
 		}
+		if outcome.timedOut {
+			exitCode = TimeoutExitCode
+		}
 
 		// Check if exit code is valid
 		success := false
@@ -244,7 +324,9 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 			ExitCode: exitCode,
 			Success:  success,
 			Output:   "", // No output captured in interactive mode
-			Error:    "",
+			Error:    outcome.message(flags),
+			TimedOut: outcome.timedOut,
+			Graceful: outcome.graceful,
 		}
 	}
 
@@ -260,6 +342,8 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 
 	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
+		// cmd.Wait will never run to close the stdout pipe
+		stdoutPipe.Close()
 		return &CmdResult{
 			ExitCode: 1,
 			Success:  false,
@@ -267,14 +351,14 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 		}
 	}
 
+	// Run in a separate process group so Ctrl-C reaches the command only once, via ForwardSignals
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		return &CmdResult{
-			ExitCode: 1,
-			Success:  false,
-			Error:    err.Error(),
-		}
+		return startFailure(cmd, err)
 	}
+	untrack := trackProcess(cmd, false)
 
 	// Use WaitGroups to coordinate goroutines
 	var pumpWg sync.WaitGroup  // For stdout/stderr pump goroutines
@@ -289,19 +373,20 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	go func() {
 		defer printWg.Done()
 		for line := range outputChan {
+			line.text = Redact(line.text)
 			if line.decorate {
 				if line.isStderr {
 					decoratedLine := AddEmphasisRed(fmt.Sprintf("[%s]", "err")) + " " + line.text
-					fmt.Fprintln(os.Stderr, decoratedLine)
+					fmt.Fprintln(Stderr(), decoratedLine)
 				} else {
 					decoratedLine := AddEmphasisBlue(fmt.Sprintf("[%s]", "cmd")) + " " + line.text
-					fmt.Println(decoratedLine)
+					fmt.Fprintln(Stdout(), decoratedLine)
 				}
 			} else {
 				if line.isStderr {
-					fmt.Fprintln(os.Stderr, line.text)
+					fmt.Fprintln(Stderr(), line.text)
 				} else {
-					fmt.Println(line.text)
+					fmt.Fprintln(Stdout(), line.text)
 				}
 			}
 		}
@@ -311,10 +396,14 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	pumpWg.Add(1)
 	go func() {
 		defer pumpWg.Done()
+		defer drainPipe(stdoutPipe)
 		scanner := bufio.NewScanner(stdoutPipe)
 		for scanner.Scan() {
 			line := scanner.Text()
 			output.WriteString(line + "\n")
+			if flags.CaptureCombined {
+				combined.writeLine(line)
+			}
 			if flags.PrintOutput {
 				outputChan <- outputLine{
 					text:     line,
@@ -329,10 +418,14 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	pumpWg.Add(1)
 	go func() {
 		defer pumpWg.Done()
+		defer drainPipe(stderrPipe)
 		scanner := bufio.NewScanner(stderrPipe)
 		for scanner.Scan() {
 			line := scanner.Text()
 			errorOutput.WriteString(line + "\n")
+			if flags.CaptureCombined {
+				combined.writeLine(line)
+			}
 			if flags.PrintOutput {
 				outputChan <- outputLine{
 					text:     line,
@@ -344,10 +437,10 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 	}()
 
 	// Wait for the command to complete
-	err = cmd.Wait()
-
-	// Wait for all pump goroutines to finish reading
-	pumpWg.Wait()
+	// All pump goroutines must finish reading before Wait closes the pipes
+	outcome := waitForCommand(cmd, flags, pumpWg.Wait)
+	untrack()
+	err = outcome.err
 
 	// Close channel after all pumps are done
 	close(outputChan)
@@ -363,6 +456,10 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 			exitCode = 1
 		}
 	}
+	if outcome.timedOut {
+		exitCode = TimeoutExitCode
+		errorOutput.WriteString(outcome.message(flags) + "\n")
+	}
 
 	// Check if exit code is valid
 	success := false
@@ -378,9 +475,105 @@ func execCommand(cmd *exec.Cmd, flags *CmdFlags) *CmdResult {
 		Success:  success,
 		Output:   output.String(),
 		Error:    errorOutput.String(),
+		Combined: combined.String(),
+		TimedOut: outcome.timedOut,
+		Graceful: outcome.graceful,
 	}
 }
 
+// drainPipe discards whatever a pump left unread, e.g. after a line too long for its scanner,
+// so the command never blocks on a full pipe and cmd.Wait can close it
+func drainPipe(pipe io.Reader) {
+	_, _ = io.Copy(io.Discard, pipe)
+}
+
+// startFailure describes a command that could not be started, calling out a missing binary
+func startFailure(cmd *exec.Cmd, err error) *CmdResult {
+	if errors.Is(err, exec.ErrNotFound) {
+		return &CmdResult{
+			ExitCode: NotFoundExitCode,
+			Success:  false,
+			Error:    fmt.Sprintf("%s: executable not found on PATH", filepath.Base(cmd.Path)),
+		}
+	}
+
+	return &CmdResult{
+		ExitCode: 1,
+		Success:  false,
+		Error:    err.Error(),
+	}
+}
+
+// waitOutcome describes how a started command finished
+type waitOutcome struct {
+	err      error
+	timedOut bool
+	graceful bool
+}
+
+// message describes a timeout for CmdResult.Error, or returns "" if there was none
+func (o waitOutcome) message(flags *CmdFlags) string {
+	switch {
+	case !o.timedOut:
+		return ""
+	case o.graceful:
+		return fmt.Sprintf("command timed out after %s and was interrupted", flags.Timeout)
+	default:
+		return fmt.Sprintf("command timed out after %s and was killed", flags.Timeout)
+	}
+}
+
+// waitForCommand waits for a started command, enforcing flags.Timeout when set.
+// drain (optional) is called before cmd.Wait to finish consuming the command's pipes.
+// On timeout the process is interrupted first so terraform can release its state lock,
+// and only killed if it is still running once the grace period has elapsed.
+func waitForCommand(cmd *exec.Cmd, flags *CmdFlags, drain func()) waitOutcome {
+	wait := func() error {
+		if drain != nil {
+			drain()
+		}
+		return cmd.Wait()
+	}
+
+	if flags.Timeout <= 0 {
+		return waitOutcome{err: wait()}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- wait()
+	}()
+
+	select {
+	case err := <-done:
+		return waitOutcome{err: err}
+	case <-time.After(flags.Timeout):
+	}
+
+	gracePeriod := flags.GracePeriod
+	if gracePeriod <= 0 {
+		gracePeriod = DefaultGracePeriod
+	}
+
+	Error(fmt.Sprintf("Command timed out after %s, interrupting (grace period %s)", flags.Timeout, gracePeriod))
+	if err := cmd.Process.Signal(os.Interrupt); err != nil {
+		Debug(fmt.Sprintf("Failed to interrupt process: %v", err))
+	}
+
+	select {
+	case err := <-done:
+		return waitOutcome{err: err, timedOut: true, graceful: true}
+	case <-time.After(gracePeriod):
+	}
+
+	Error("Command did not exit within the grace period, killing it")
+	if err := cmd.Process.Kill(); err != nil {
+		Debug(fmt.Sprintf("Failed to kill process: %v", err))
+	}
+
+	return waitOutcome{err: <-done, timedOut: true}
+}
+
 // CommandType represents the type of command to execute
 type CommandType int
 
@@ -477,6 +670,101 @@ func TestNotEmpty(value string) *CmdResult {
 	}
 }
 
+// TestSymlink checks if a path is a symbolic link (replacement for "test -L")
+func TestSymlink(path string) *CmdResult {
+	Debug(fmt.Sprintf("Native symlink check: %s", path))
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    fmt.Sprintf("Path does not exist: %s", path),
+		}
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    fmt.Sprintf("Path is not a symlink: %s", path),
+		}
+	}
+
+	target, _ := os.Readlink(path)
+	return &CmdResult{
+		ExitCode: 0,
+		Success:  true,
+		Output:   fmt.Sprintf("Symlink exists: %s -> %s", path, target),
+		Error:    "",
+	}
+}
+
+// TestExecutable checks if a path is an executable file (replacement for "test -x")
+func TestExecutable(path string) *CmdResult {
+	Debug(fmt.Sprintf("Native executable check: %s", path))
+
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    fmt.Sprintf("File does not exist: %s", path),
+		}
+	case info.IsDir():
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    fmt.Sprintf("Path is a directory, not an executable file: %s", path),
+		}
+	case info.Mode().Perm()&0111 == 0:
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    fmt.Sprintf("File is not executable (mode %s): %s", info.Mode().Perm(), path),
+		}
+	}
+
+	return &CmdResult{
+		ExitCode: 0,
+		Success:  true,
+		Output:   fmt.Sprintf("File is executable: %s", path),
+		Error:    "",
+	}
+}
+
+// TestReadable checks if a file can be opened for reading (replacement for "test -r")
+func TestReadable(path string) *CmdResult {
+	Debug(fmt.Sprintf("Native readable check: %s", path))
+
+	file, err := os.Open(path)
+	if err != nil {
+		message := fmt.Sprintf("File is not readable: %s", path)
+		if os.IsNotExist(err) {
+			message = fmt.Sprintf("File does not exist: %s", path)
+		}
+		return &CmdResult{
+			ExitCode: 1,
+			Success:  false,
+			Output:   "",
+			Error:    message,
+		}
+	}
+	file.Close()
+
+	return &CmdResult{
+		ExitCode: 0,
+		Success:  true,
+		Output:   fmt.Sprintf("File is readable: %s", path),
+		Error:    "",
+	}
+}
+
 // Helper functions that return NativeFunc for easier usage
 
 // NativeTestDir returns a NativeFunc that checks if a directory exists
@@ -500,21 +788,45 @@ func NativeTestNotEmpty(value string) NativeFunc {
 	}
 }
 
+// NativeTestSymlink returns a NativeFunc that checks if a path is a symlink
+func NativeTestSymlink(path string) NativeFunc {
+	return func() *CmdResult {
+		return TestSymlink(path)
+	}
+}
+
+// NativeTestExecutable returns a NativeFunc that checks if a file is executable
+func NativeTestExecutable(path string) NativeFunc {
+	return func() *CmdResult {
+		return TestExecutable(path)
+	}
+}
+
+// NativeTestReadable returns a NativeFunc that checks if a file is readable
+func NativeTestReadable(path string) NativeFunc {
+	return func() *CmdResult {
+		return TestReadable(path)
+	}
+}
+
 // parseStatus displays the status of command execution
 func parseStatus(message string, result *CmdResult, flags *CmdFlags, failMessage ...string) {
-	if !flags.PrintStatus {
+	if !flags.PrintStatus || (result.Success && IsQuiet()) {
 		return
 	}
 
+	message = MaskText(message)
+
 	// Prepare status indicators
 	var statusIndicator string
 	var outcomeMessage string
 
+	marks := getStatusMarks()
 	if result.Success {
-		statusIndicator = fmt.Sprintf("[ %s ]", AddEmphasisGreen(CheckMark))
-		outcomeMessage = "(done)"
+		statusIndicator = fmt.Sprintf(marks.format, AddEmphasisGreen(marks.check))
+		outcomeMessage = fmt.Sprintf("(%s)", marks.done)
 	} else {
-		statusIndicator = fmt.Sprintf("[ %s ]", AddEmphasisRed(CrossMark))
+		statusIndicator = fmt.Sprintf(marks.format, AddEmphasisRed(marks.cross))
 		if flags.Strict {
 			outcomeMessage = fmt.Sprintf("(%s)", AddEmphasisRed(flags.StrictMessage))
 		} else {
@@ -529,8 +841,8 @@ func parseStatus(message string, result *CmdResult, flags *CmdFlags, failMessage
 
 	// Calculate the actual visual width needed
 	messageVisualLength := getVisualLength(message)
-	entrypointVisualLength := getVisualLength(entrypoint) // Use uncolored version for length
-	statusVisualLength := 5                               // "[ ✓ ]" or "[ ✗ ]"
+	entrypointVisualLength := getVisualLength(entrypoint)  // Use uncolored version for length
+	statusVisualLength := getVisualLength(statusIndicator) // "[ ✓ ]", "[FAIL]", ...
 
 	// Total target width minus the parts we know
 	totalWidth := 120
@@ -551,7 +863,7 @@ func parseStatus(message string, result *CmdResult, flags *CmdFlags, failMessage
 		format += " " + outcomeMessage
 	}
 
-	fmt.Fprintln(os.Stderr, format)
+	fmt.Fprintln(Stderr(), format)
 
 	// Handle failure
 	if !result.Success {
@@ -559,8 +871,8 @@ func parseStatus(message string, result *CmdResult, flags *CmdFlags, failMessage
 			Error(failMessage[0])
 		}
 
-		if flags.Strict {
-			os.Exit(result.ExitCode)
+		if flags.Strict && strictExit.Load() {
+			exit(result.ExitCode)
 		}
 	}
 }
@@ -0,0 +1,192 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// trackedProcess is a running child that receives forwarded signals
+type trackedProcess struct {
+	cmd *exec.Cmd
+	// sharesTerminal is set for interactive children, which stay in our process
+	// group and already get terminal generated signals such as Ctrl-C directly
+	sharesTerminal bool
+	done           chan struct{}
+}
+
+var (
+	processesMu sync.Mutex
+	processes   = map[*trackedProcess]struct{}{}
+)
+
+// interactiveRuns counts running interactive commands that handle SIGINT themselves (see handleInterrupts)
+var interactiveRuns atomic.Int32
+
+// exitProcess terminates tf-manage after a forwarded signal (replaced in tests)
+var exitProcess = exit
+
+// trackProcess registers a started command and returns the function that unregisters it
+func trackProcess(cmd *exec.Cmd, sharesTerminal bool) func() {
+	process := &trackedProcess{cmd: cmd, sharesTerminal: sharesTerminal, done: make(chan struct{})}
+
+	processesMu.Lock()
+	processes[process] = struct{}{}
+	processesMu.Unlock()
+
+	return func() {
+		processesMu.Lock()
+		delete(processes, process)
+		processesMu.Unlock()
+		close(process.done)
+	}
+}
+
+// ForwardSignals relays SIGINT and SIGTERM to running child processes so terraform
+// can release its state lock, waits up to gracePeriod for them to exit, kills any
+// that remain and then exits with 128+signal. The returned function stops forwarding.
+func ForwardSignals(gracePeriod time.Duration) func() {
+	signals := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				// An interactive command is cancelling on its own; see handleInterrupts
+				if sig == os.Interrupt && interactiveRuns.Load() > 0 {
+					continue
+				}
+				forwardSignal(sig, gracePeriod)
+				exitProcess(signalExitCode(sig))
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stop)
+	}
+}
+
+// handleInterrupts mirrors terraform's double Ctrl-C while an interactive command runs:
+// the first SIGINT reaches the command so it can cancel gracefully and release its state
+// lock, however long that takes, and a second one kills it. Ctrl-C typed in the terminal
+// already reaches the command, so it is only sent on when stdin is not a terminal.
+// The returned function stops handling SIGINT and restores the previous behavior.
+func handleInterrupts(cmd *exec.Cmd) func() {
+	signals := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	interactiveRuns.Add(1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		defer close(done)
+		interrupted := false
+		for {
+			select {
+			case <-signals:
+			case <-stop:
+				return
+			}
+
+			if !interrupted {
+				interrupted = true
+				Error("Received interrupt, waiting for the command to cancel (interrupt again to kill it)")
+				if !isTerminal(os.Stdin) {
+					if err := cmd.Process.Signal(os.Interrupt); err != nil {
+						Debug(fmt.Sprintf("Failed to forward interrupt: %v", err))
+					}
+				}
+				continue
+			}
+
+			Error("Received second interrupt, killing the command")
+			if err := cmd.Process.Kill(); err != nil {
+				Debug(fmt.Sprintf("Failed to kill process: %v", err))
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(stop)
+		<-done
+		interactiveRuns.Add(-1)
+	}
+}
+
+// forwardSignal delivers sig to every tracked child, then kills those still
+// running once gracePeriod has elapsed
+func forwardSignal(sig os.Signal, gracePeriod time.Duration) {
+	processesMu.Lock()
+	pending := make([]*trackedProcess, 0, len(processes))
+	for process := range processes {
+		pending = append(pending, process)
+	}
+	processesMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	Error(fmt.Sprintf("Received %s, waiting up to %s for running commands to exit", sig, gracePeriod))
+	for _, process := range pending {
+		// The terminal already delivered Ctrl-C to interactive children; a second
+		// interrupt would make terraform abort immediately without cleaning up
+		if process.sharesTerminal && sig == os.Interrupt {
+			continue
+		}
+		if err := signalProcess(process, sig); err != nil {
+			Debug(fmt.Sprintf("Failed to forward %s: %v", sig, err))
+		}
+	}
+
+	deadline := time.After(gracePeriod)
+	for _, process := range pending {
+		select {
+		case <-process.done:
+			continue
+		case <-deadline:
+		}
+
+		Error("Commands did not exit within the grace period, killing them")
+		for _, process := range pending {
+			if err := signalProcess(process, syscall.SIGKILL); err != nil {
+				Debug(fmt.Sprintf("Failed to kill process: %v", err))
+			}
+		}
+		return
+	}
+}
+
+// signalProcess sends sig to a child, including its own children when it runs in a separate process group
+func signalProcess(process *trackedProcess, sig os.Signal) error {
+	if process.cmd.Process == nil {
+		return nil
+	}
+
+	sysSig, ok := sig.(syscall.Signal)
+	if !ok || process.sharesTerminal {
+		return process.cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-process.cmd.Process.Pid, sysSig)
+}
+
+// signalExitCode follows the shell convention of 128 + signal number
+func signalExitCode(sig os.Signal) int {
+	if sysSig, ok := sig.(syscall.Signal); ok {
+		return 128 + int(sysSig)
+	}
+	return 1
+}
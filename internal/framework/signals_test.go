@@ -0,0 +1,168 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startTracked runs command through execCommand in the background and waits until it is tracked
+func startTracked(t *testing.T, command string) <-chan *CmdResult {
+	t.Helper()
+
+	flags := DefaultCmdFlags()
+	flags.DecorateOutput = true
+	flags.PrintOutput = false
+	return startTrackedWith(t, command, flags)
+}
+
+// startTrackedWith is startTracked with explicit command flags
+func startTrackedWith(t *testing.T, command string, flags *CmdFlags) <-chan *CmdResult {
+	t.Helper()
+
+	results := make(chan *CmdResult, 1)
+	go func() {
+		results <- execCommand(exec.Command("sh", "-c", command), flags)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		processesMu.Lock()
+		running := len(processes)
+		processesMu.Unlock()
+		if running > 0 {
+			// Give the shell a moment to install its traps
+			time.Sleep(100 * time.Millisecond)
+			return results
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Command was never tracked")
+	return nil
+}
+
+func TestForwardSignal(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	t.Run("Child exits gracefully on forwarded signal", func(t *testing.T) {
+		results := startTracked(t, `trap 'echo released lock; exit 3' TERM; while :; do sleep 0.1; done`)
+
+		start := time.Now()
+		forwardSignal(syscall.SIGTERM, 5*time.Second)
+
+		result := <-results
+		if result.ExitCode != 3 {
+			t.Errorf("Expected the child's own exit code 3, got %d", result.ExitCode)
+		}
+		if result.Output != "released lock\n" {
+			t.Errorf("Expected cleanup output, got %q", result.Output)
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Errorf("Forwarding waited for the whole grace period: %s", time.Since(start))
+		}
+	})
+
+	t.Run("Child ignoring the signal is killed after the grace period", func(t *testing.T) {
+		results := startTracked(t, `trap '' TERM; while :; do sleep 0.1; done`)
+
+		stderr := captureStderr(t, func() {
+			forwardSignal(syscall.SIGTERM, 200*time.Millisecond)
+		})
+
+		select {
+		case result := <-results:
+			if result.Success {
+				t.Error("Expected killed command to fail")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Command was not killed after the grace period")
+		}
+		if !strings.Contains(stderr, "Received terminated") || !strings.Contains(stderr, "killing them") {
+			t.Errorf("Unexpected stderr: %s", stderr)
+		}
+	})
+
+	t.Run("Nothing running", func(t *testing.T) {
+		stderr := captureStderr(t, func() {
+			forwardSignal(syscall.SIGTERM, time.Second)
+		})
+		if stderr != "" {
+			t.Errorf("Expected no output without running commands, got %q", stderr)
+		}
+	})
+}
+
+func TestSignalExitCode(t *testing.T) {
+	if got := signalExitCode(syscall.SIGINT); got != 130 {
+		t.Errorf("signalExitCode(SIGINT) = %d, want 130", got)
+	}
+	if got := signalExitCode(syscall.SIGTERM); got != 143 {
+		t.Errorf("signalExitCode(SIGTERM) = %d, want 143", got)
+	}
+}
+
+func TestHandleInterrupts(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	originalIsTerminal := isTerminal
+	isTerminal = func(*os.File) bool { return false }
+	t.Cleanup(func() { isTerminal = originalIsTerminal })
+
+	interrupts := filepath.Join(t.TempDir(), "interrupts")
+	countInterrupts := func() int {
+		data, _ := os.ReadFile(interrupts)
+		return strings.Count(string(data), "INT")
+	}
+
+	flags := DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.DecorateOutput = false // Interactive
+	command := fmt.Sprintf(`trap 'echo INT >> %s' INT; while :; do sleep 0.1; done`, interrupts)
+
+	stderr := captureStderr(t, func() {
+		results := startTrackedWith(t, command, flags)
+
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		deadline := time.Now().Add(5 * time.Second)
+		for countInterrupts() == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if got := countInterrupts(); got != 1 {
+			t.Fatalf("Expected the first interrupt to be forwarded once, child saw %d", got)
+		}
+
+		select {
+		case <-results:
+			t.Fatal("Expected the command to keep running after the first interrupt")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		select {
+		case result := <-results:
+			if result.Success {
+				t.Error("Expected the killed command to fail")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Command was not killed on the second interrupt")
+		}
+	})
+
+	if got := countInterrupts(); got != 1 {
+		t.Errorf("Expected the second interrupt to kill instead of forwarding, child saw %d", got)
+	}
+	if !strings.Contains(stderr, "interrupt again to kill it") || !strings.Contains(stderr, "killing the command") {
+		t.Errorf("Unexpected stderr: %s", stderr)
+	}
+	if interactiveRuns.Load() != 0 {
+		t.Error("Expected the interrupt handler to be removed once the command finished")
+	}
+}
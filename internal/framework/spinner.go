@@ -0,0 +1,72 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// spinnerFrames are drawn in turn while a silent command runs
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// spinnerInterval is the delay between spinner frames
+const spinnerInterval = 100 * time.Millisecond
+
+// spinnerOut is where the spinner is drawn; replaced in tests
+var spinnerOut io.Writer = os.Stderr
+
+// isTerminal reports whether f is attached to a terminal; replaced in tests
+var isTerminal = func(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// spinner animates a progress indicator on a single line until stopped
+type spinner struct {
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// startSpinner starts a spinner for a command run with flags, or returns nil when the
+// command prints its own output, quiet mode is on, or stdout/stderr is not a terminal
+func startSpinner(message string, flags *CmdFlags) *spinner {
+	if !flags.Spinner || flags.PrintOutput || !flags.DecorateOutput || IsQuiet() {
+		return nil
+	}
+	if !isTerminal(os.Stdout) || !isTerminal(os.Stderr) {
+		return nil
+	}
+
+	s := &spinner{stop: make(chan struct{})}
+	prefix := AddEmphasisGray(fmt.Sprintf("[%s]", GetEntrypointScript())) + " " + MaskText(message)
+
+	s.done.Add(1)
+	go func() {
+		defer s.done.Done()
+		ticker := time.NewTicker(spinnerInterval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			fmt.Fprintf(spinnerOut, "\r%s %s", prefix, spinnerFrames[frame%len(spinnerFrames)])
+			select {
+			case <-s.stop:
+				// Clear the line so the status line printed next starts on a clean line
+				fmt.Fprint(spinnerOut, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return s
+}
+
+// Stop halts the animation and clears its line. It is safe to call on a nil spinner.
+func (s *spinner) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	s.done.Wait()
+}
@@ -0,0 +1,76 @@
+package framework
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeTerminal makes stdout/stderr appear to be a terminal (or not) and captures spinner output
+func fakeTerminal(t *testing.T, tty bool) *bytes.Buffer {
+	t.Helper()
+
+	var out bytes.Buffer
+	originalIsTerminal, originalOut := isTerminal, spinnerOut
+	isTerminal = func(*os.File) bool { return tty }
+	spinnerOut = &out
+	t.Cleanup(func() {
+		isTerminal, spinnerOut = originalIsTerminal, originalOut
+	})
+	return &out
+}
+
+func TestSpinner(t *testing.T) {
+	silentFlags := func() *CmdFlags {
+		flags := DefaultCmdFlags()
+		flags.PrintOutput = false
+		flags.DecorateOutput = true
+		flags.Spinner = true
+		return flags
+	}
+
+	t.Run("Suppressed without a terminal", func(t *testing.T) {
+		out := fakeTerminal(t, false)
+		s := startSpinner("Listing workspaces", silentFlags())
+		s.Stop()
+		if s != nil || out.Len() != 0 {
+			t.Errorf("Expected no spinner in non-TTY mode, got output %q", out.String())
+		}
+	})
+
+	t.Run("Suppressed in quiet mode", func(t *testing.T) {
+		defer SetQuiet(false)
+		SetQuiet(true)
+		out := fakeTerminal(t, true)
+		if s := startSpinner("Listing workspaces", silentFlags()); s != nil {
+			s.Stop()
+			t.Errorf("Expected no spinner in quiet mode, got output %q", out.String())
+		}
+	})
+
+	t.Run("Suppressed when output is printed", func(t *testing.T) {
+		fakeTerminal(t, true)
+		flags := silentFlags()
+		flags.PrintOutput = true
+		if s := startSpinner("Listing workspaces", flags); s != nil {
+			s.Stop()
+			t.Error("Expected no spinner when command output is printed")
+		}
+	})
+
+	t.Run("Animates and clears its line on a terminal", func(t *testing.T) {
+		out := fakeTerminal(t, true)
+		s := startSpinner("Listing workspaces", silentFlags())
+		if s == nil {
+			t.Fatal("Expected a spinner on a terminal")
+		}
+		s.Stop()
+		if !strings.Contains(out.String(), "Listing workspaces") {
+			t.Errorf("Expected spinner to show the message, got %q", out.String())
+		}
+		if !strings.HasSuffix(out.String(), "\r\033[K") {
+			t.Errorf("Expected spinner to clear its line when stopped, got %q", out.String())
+		}
+	})
+}
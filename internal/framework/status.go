@@ -0,0 +1,51 @@
+package framework
+
+import (
+	"os"
+	"sync/atomic"
+)
+
+// statusMarks are the indicators and outcome text of status lines (see SetASCII)
+type statusMarks struct {
+	check      string // Indicator of a successful command
+	cross      string // Indicator of a failed command
+	format     string // Wraps the indicator in the status column
+	done       string // Outcome of a successful command
+	continuing string // Outcome of a failed command that does not abort the run
+}
+
+// asciiStatusMarks avoid symbols that some terminals, themes and log parsers render poorly
+var asciiStatusMarks = statusMarks{check: "OK", cross: "FAIL", format: "[%s]", done: "done", continuing: "continuing..."}
+
+var currentStatusMarks atomic.Pointer[statusMarks]
+
+func init() {
+	SetASCII(os.Getenv("TFM_ASCII") != "")
+}
+
+// SetASCII selects [OK] and [FAIL] status indicators instead of ✓ and ✗. Either set can be
+// overridden with TFM_CHECK_MARK, TFM_CROSS_MARK, TFM_DONE_MESSAGE and TFM_CONTINUE_MESSAGE.
+func SetASCII(enabled bool) {
+	marks := statusMarks{check: CheckMark, cross: CrossMark, format: "[ %s ]", done: "done", continuing: "continuing..."}
+	if enabled {
+		marks = asciiStatusMarks
+	}
+
+	for env, field := range map[string]*string{
+		"TFM_CHECK_MARK":       &marks.check,
+		"TFM_CROSS_MARK":       &marks.cross,
+		"TFM_DONE_MESSAGE":     &marks.done,
+		"TFM_CONTINUE_MESSAGE": &marks.continuing,
+	} {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+
+	currentStatusMarks.Store(&marks)
+}
+
+// getStatusMarks returns the status indicators in use
+func getStatusMarks() *statusMarks {
+	return currentStatusMarks.Load()
+}
@@ -0,0 +1,72 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStatusMarks(t *testing.T) {
+	t.Cleanup(func() { SetASCII(false) })
+
+	// statusLine returns a status line without colors
+	statusLine := func(t *testing.T, success bool) string {
+		t.Helper()
+		output := captureStderr(t, func() {
+			parseStatus("Checking module exists", &CmdResult{Success: success, ExitCode: 1}, DefaultCmdFlags())
+		})
+		return strings.SplitN(stripAnsiCodes(output), "\n", 2)[0]
+	}
+
+	tests := []struct {
+		name   string
+		ascii  bool
+		env    map[string]string
+		passed string
+		failed string
+	}{
+		{"Default", false, nil, "[ ✓ ]", "[ ✗ ]"},
+		{"ASCII", true, nil, "[OK]", "[FAIL]"},
+		{"Multi-byte marks from env", false, map[string]string{"TFM_CHECK_MARK": "✔✔", "TFM_CROSS_MARK": "×"}, "[ ✔✔ ]", "[ × ]"},
+		{"Env overrides ASCII", true, map[string]string{"TFM_CHECK_MARK": "PASS"}, "[PASS]", "[FAIL]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			SetASCII(tt.ascii)
+
+			for _, c := range []struct {
+				success bool
+				want    string
+			}{{true, tt.passed}, {false, tt.failed}} {
+				line := statusLine(t, c.success)
+				if !strings.HasSuffix(line, " "+c.want) {
+					t.Errorf("Status line %q does not end with %q", line, c.want)
+				}
+				// Padding is computed from the visual width, so every line spans 120 columns
+				if width := utf8.RuneCountInString(line); width != 120 {
+					t.Errorf("Status line %q is %d columns wide, want 120", line, width)
+				}
+			}
+		})
+	}
+}
+
+func TestOutcomeMessages(t *testing.T) {
+	t.Setenv("TFM_DONE_MESSAGE", "ok")
+	t.Setenv("TFM_CONTINUE_MESSAGE", "ignored")
+	SetASCII(true)
+	t.Cleanup(func() { SetASCII(false) })
+
+	flags := DefaultCmdFlags()
+	flags.PrintOutcome = true
+	output := stripAnsiCodes(captureStderr(t, func() {
+		parseStatus("Checking product", &CmdResult{Success: true}, flags)
+		parseStatus("Checking env", &CmdResult{Success: false, ExitCode: 1}, flags)
+	}))
+	if !strings.Contains(output, "[OK] (ok)") || !strings.Contains(output, "[FAIL] (ignored)") {
+		t.Errorf("Unexpected outcome messages: %q", output)
+	}
+}
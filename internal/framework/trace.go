@@ -0,0 +1,57 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// trace logs every command tf-manage executes when set (see SetTrace)
+var trace atomic.Bool
+
+func init() {
+	trace.Store(os.Getenv("TFM_TRACE") != "")
+}
+
+// SetTrace enables or disables trace mode. In trace mode every executed command is logged
+// to stderr as parsed, with its working directory and environment overrides.
+func SetTrace(enabled bool) {
+	trace.Store(enabled)
+}
+
+// IsTrace reports whether trace mode is enabled
+func IsTrace() bool {
+	return trace.Load()
+}
+
+// traceRecord is the structured form of a traced command
+type traceRecord struct {
+	Program string   `json:"program"`
+	Args    []string `json:"args"`
+	Dir     string   `json:"dir"`
+	Env     []string `json:"env,omitempty"`
+}
+
+// traceCommand logs program and args exactly as they are passed to exec, so quoting and
+// splitting mistakes show up as unexpected tokens
+func traceCommand(program string, args []string, flags *CmdFlags) {
+	if !IsTrace() {
+		return
+	}
+
+	dir := flags.Dir
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	record := traceRecord{Program: program, Args: args, Dir: dir, Env: flags.Env}
+	if record.Args == nil {
+		record.Args = []string{}
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(Stderr(), "[TRACE] %s\n", MaskText(Redact(string(data))))
+}
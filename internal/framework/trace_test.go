@@ -0,0 +1,100 @@
+package framework
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// captureTrace runs fn with stderr redirected and returns the traced command records
+func captureTrace(t *testing.T, fn func()) []traceRecord {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create stderr file: %v", err)
+	}
+	originalStderr := os.Stderr
+	os.Stderr = file
+	fn()
+	os.Stderr = originalStderr
+	file.Close()
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatalf("Failed to read stderr: %v", err)
+	}
+	var records []traceRecord
+	for _, line := range strings.Split(string(data), "\n") {
+		payload, ok := strings.CutPrefix(line, "[TRACE] ")
+		if !ok {
+			continue
+		}
+		var record traceRecord
+		if err := json.Unmarshal([]byte(payload), &record); err != nil {
+			t.Fatalf("Trace line is not JSON: %q", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestTrace(t *testing.T) {
+	if _, err := exec.LookPath("printf"); err != nil {
+		t.Skip("printf not available")
+	}
+
+	dir := t.TempDir()
+	flags := DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintStatus = false
+	flags.PrintOutput = false
+	flags.DecorateOutput = true
+	flags.Dir = dir
+	flags.Env = []string{"TF_WORKSPACE=ws"}
+	command := `printf "%s|" "two words" -var=x=1`
+
+	t.Run("Disabled by default", func(t *testing.T) {
+		SetTrace(false)
+		if records := captureTrace(t, func() { RunCmd(command, "Tracing", flags) }); len(records) != 0 {
+			t.Errorf("Expected no trace without trace mode, got %v", records)
+		}
+	})
+
+	t.Run("Logs the parsed command", func(t *testing.T) {
+		SetTrace(true)
+		t.Cleanup(func() { SetTrace(false) })
+
+		records := captureTrace(t, func() { RunCmd(command, "Tracing", flags) })
+		want := []traceRecord{{
+			Program: "printf",
+			Args:    []string{"%s|", "two words", "-var=x=1"},
+			Dir:     dir,
+			Env:     []string{"TF_WORKSPACE=ws"},
+		}}
+		if !reflect.DeepEqual(records, want) {
+			t.Errorf("Trace = %+v, want %+v", records, want)
+		}
+	})
+
+	t.Run("Redacts configured patterns", func(t *testing.T) {
+		SetTrace(true)
+		if err := SetRedactPatterns([]string{`x=1`}); err != nil {
+			t.Fatalf("SetRedactPatterns() error = %v", err)
+		}
+		t.Cleanup(func() {
+			SetTrace(false)
+			SetRedactPatterns(nil)
+		})
+
+		records := captureTrace(t, func() { RunCmd(command, "Tracing", flags) })
+		leaked := func(arg string) bool { return strings.Contains(arg, "x=1") }
+		if len(records) != 1 || slices.ContainsFunc(records[0].Args, leaked) {
+			t.Errorf("Expected the matched argument to be redacted, got %+v", records)
+		}
+	})
+}
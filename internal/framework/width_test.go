@@ -0,0 +1,32 @@
+package framework
+
+import "testing"
+
+func TestGetVisualLength(t *testing.T) {
+	tests := []struct {
+		name string
+		str  string
+		want int
+	}{
+		{"ASCII", "Checking module exists", 22},
+		{"Empty", "", 0},
+		{"ANSI codes are ignored", AddEmphasisBlue("dev") + " env", 7},
+		{"Accented Latin", "Déploiement réussi", 18},
+		{"Combining accent", "été", 3},
+		{"CJK", "環境を確認", 10},
+		{"Hangul", "한국어", 6},
+		{"Fullwidth", "ＡＢＣ", 6},
+		{"Emoji", "✅ done", 7},
+		{"Emoji with variation selector", "⚠️ warning", 10},
+		{"Emoji beyond the BMP", "🚀 deploy", 9},
+		{"Joined emoji", "👩‍💻", 2},
+		{"Narrow symbols", "[ ✓ ] [ ✗ ]", 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getVisualLength(tt.str); got != tt.want {
+				t.Errorf("getVisualLength(%q) = %d, want %d", tt.str, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package terraform
+
+import "fmt"
+
+// Action is an action tf-manage can run against an instance
+type Action struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// supportedActions are the actions executeTerraformAction dispatches through actionHandlers,
+// in the order they are suggested
+var supportedActions = []Action{
+	{"init", "Initialize the module's working directory"},
+	{"plan", "Save a plan of the instance's changes"},
+	{"apply", "Apply the instance's changes"},
+	{"apply_plan", "Apply the saved plan"},
+	{"deploy", "Plan, confirm once, then apply exactly that plan"},
+	{"destroy", "Destroy the instance's resources"},
+	{"output", "Show the instance's outputs"},
+	{"get", "Download the module's child modules"},
+	{"workspace", "Run terraform workspace subcommands"},
+	{"providers", "Show the module's providers"},
+	{"import", "Import existing infrastructure into state"},
+	{"taint", "Mark a resource for replacement (deprecated; use --replace)"},
+	{"untaint", "Remove a resource's taint (deprecated)"},
+	{"state", "Run terraform state subcommands"},
+	{"refresh", "Update state from real infrastructure"},
+	{"validate", "Validate the module's configuration"},
+	{"fmt", "Format the module's files"},
+	{"format", "Alias of fmt"},
+	{"show", "Show the state or the saved plan"},
+	{"delete-workspace", "Delete the instance's workspace"},
+	{"metadata", "Run terraform metadata for editor integrations"},
+	{"raw", "Run any terraform subcommand as typed (unvalidated)"},
+}
+
+// SupportedActions returns the supported actions with their descriptions
+func SupportedActions() []Action {
+	return append([]Action{}, supportedActions...)
+}
+
+// IsValidAction reports whether action is a supported action
+func IsValidAction(action string) bool {
+	for _, known := range supportedActions {
+		if action == known.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAction returns a validation error for an unsupported action, suggesting the
+// closest supported one when there is a likely typo
+func ValidateAction(action string) error {
+	if IsValidAction(action) {
+		return nil
+	}
+
+	message := fmt.Sprintf("unknown action '%s'", action)
+	if suggestion := SuggestAction(action); suggestion != "" {
+		message += fmt.Sprintf("; did you mean '%s'?", suggestion)
+	} else {
+		message += "; run 'tf --help' for the supported actions"
+	}
+	return NewExitCodeError(message, ExitValidationFailed)
+}
+
+// SuggestAction returns the supported action closest to action, or "" if none is close enough
+func SuggestAction(action string) string {
+	best, bestDistance := "", 0
+	for _, known := range supportedActions {
+		distance := levenshtein(action, known.Name)
+		if best == "" || distance < bestDistance {
+			best, bestDistance = known.Name, distance
+		}
+	}
+
+	// Only suggest plausible typos, not unrelated words
+	if bestDistance > 2 && bestDistance*2 > len(action) {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
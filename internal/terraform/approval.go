@@ -0,0 +1,46 @@
+package terraform
+
+import (
+	"fmt"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// approvalActions need approval_command to succeed before they run unattended
+var approvalActions = map[string]bool{
+	"apply":      true,
+	"apply_plan": true,
+	"deploy":     true,
+	"destroy":    true,
+	"raw":        true, // Raw commands are not rewritten, but still gated
+}
+
+// requestApproval runs the configured approval_command before an unattended apply or
+// destroy and aborts unless it exits 0. The command sees the run's target as TFM_* variables.
+func (m *Manager) requestApproval(cmd *Command, workspaceName string) error {
+	if m.config.ApprovalCommand == "" || !approvalActions[cmd.Action] || !m.isUnattended() {
+		return nil
+	}
+
+	flags := framework.DefaultCmdFlags()
+	flags.Env = []string{
+		"TFM_ACTION=" + cmd.Action,
+		"TFM_WORKSPACE=" + workspaceName,
+		"TFM_PRODUCT=" + cmd.Product,
+		"TFM_REPO=" + m.config.RepoName,
+		"TFM_MODULE=" + cmd.Module,
+		"TFM_ENV=" + cmd.Env,
+		"TFM_INSTANCE=" + cmd.ModuleInstance,
+	}
+	flags.DecorateOutput = true
+	result := m.run(
+		m.config.ApprovalCommand,
+		fmt.Sprintf("Requesting approval to %s %s", cmd.Action, framework.AddEmphasisBlue(workspaceName)),
+		flags,
+		fmt.Sprintf("Approval command denied %s, aborting", cmd.Action),
+	)
+	if !result.Success {
+		return NewExitCodeError(fmt.Sprintf("%s not approved (approval command exited %d)", cmd.Action, result.ExitCode), ExitApprovalDenied)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// contextFreeActions run without a product/module/env/instance: tf login [hostname]
+var contextFreeActions = []string{"login", "logout"}
+
+// IsContextFreeAction reports whether action is run as 'tf <action> [hostname]'
+func IsContextFreeAction(action string) bool {
+	for _, known := range contextFreeActions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+// hostnamePattern matches a terraform service hostname, optionally with a port
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?(:[0-9]+)?$`)
+
+// Authenticate forwards login and logout to terraform on the operator's terminal.
+// cmd.ActionFlags holds the optional hostname (terraform defaults to app.terraform.io).
+func (m *Manager) Authenticate(cmd *Command) error {
+	m.info = RunInfo{Action: cmd.Action, ExecMode: m.execModeName(), CI: detectCI()}
+
+	if !IsContextFreeAction(cmd.Action) {
+		framework.Error(fmt.Sprintf("%s is not a login action", framework.AddEmphasisRed(cmd.Action)))
+		return NewExitCodeError(fmt.Sprintf("unknown action '%s'", cmd.Action), ExitValidationFailed)
+	}
+	if cmd.ActionFlags != "" && !hostnamePattern.MatchString(cmd.ActionFlags) {
+		framework.Error(fmt.Sprintf("Invalid hostname %s", framework.AddEmphasisRed(cmd.ActionFlags)))
+		return NewExitCodeError(fmt.Sprintf("invalid hostname '%s'", cmd.ActionFlags), ExitValidationFailed)
+	}
+
+	// terraform login waits for a token pasted from the browser
+	if cmd.Action == "login" && m.isUnattended() {
+		framework.Error("terraform login needs an operator; set a TF_TOKEN_<hostname> variable in unattended runs")
+		return NewExitCodeError("login requires an interactive terminal", ExitValidationFailed)
+	}
+
+	if err := checkTerraformInstalled(); err != nil {
+		return err
+	}
+
+	terraformCmd := "terraform " + cmd.Action
+	if cmd.ActionFlags != "" {
+		terraformCmd += " " + cmd.ActionFlags
+	}
+
+	result := m.runInteractive(
+		terraformCmd,
+		fmt.Sprintf("Running terraform %s", cmd.Action),
+		fmt.Sprintf("Terraform %s failed", cmd.Action),
+	)
+	return NewExitCodeError("command failed", result.ExitCode)
+}
@@ -0,0 +1,62 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// backendKey renders backend_key_template for the command's instance
+func (m *Manager) backendKey(cmd *Command) (string, error) {
+	return m.config.RenderBackendKey(config.BackendKeyVars{
+		Product:  cmd.Product,
+		Repo:     m.config.RepoName,
+		Module:   cmd.Module,
+		Env:      cmd.Env,
+		Instance: cmd.ModuleInstance,
+	})
+}
+
+// checkBackendKey makes sure the module was last initialized with this instance's backend key.
+// Without workspaces every instance shares the module's .terraform directory, so running
+// against another instance's key would read and write the wrong state.
+func (m *Manager) checkBackendKey(cmd *Command, paths *Paths) error {
+	want, err := m.backendKey(cmd)
+	if err != nil {
+		return NewExitCodeError(err.Error(), ExitValidationFailed)
+	}
+
+	dataDir := os.Getenv("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(paths.ModulePath, dataDir)
+	}
+
+	// Terraform reports a missing init itself
+	data, err := os.ReadFile(filepath.Join(dataDir, "terraform.tfstate"))
+	if err != nil {
+		return nil
+	}
+	var backendState struct {
+		Backend struct {
+			Config map[string]any `json:"config"`
+		} `json:"backend"`
+	}
+	if err := json.Unmarshal(data, &backendState); err != nil {
+		return nil
+	}
+	got, ok := backendState.Backend.Config["key"].(string)
+	if !ok || got == want {
+		return nil
+	}
+
+	framework.Error(fmt.Sprintf("The module is initialized with backend key %s, not %s; run init for this instance first",
+		framework.AddEmphasisRed(got), framework.AddEmphasisBlue(want)))
+	return NewExitCodeError(fmt.Sprintf("backend key mismatch: initialized with %s, instance uses %s", got, want), ExitValidationFailed)
+}
@@ -0,0 +1,79 @@
+package terraform
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// changedFiles returns the files changed between base and HEAD, relative to the project root
+func (m *Manager) changedFiles(base string) ([]string, error) {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run(
+		fmt.Sprintf("git -C \"%s\" diff --name-only --relative %s", m.config.ProjectDir, quoteArg(base+"...HEAD")),
+		fmt.Sprintf("Listing files changed since %s", framework.AddEmphasisBlue(base)),
+		flags,
+	)
+	if !result.Success {
+		return nil, NewExitCodeError(fmt.Sprintf("git diff against %s failed: %s", base, strings.TrimSpace(result.Error)), ExitValidationFailed)
+	}
+
+	var files []string
+	for _, line := range strings.Split(result.Output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.ToSlash(line))
+		}
+	}
+	return files, nil
+}
+
+// changedInstances keeps the instances affected by the files changed since base: every
+// instance when the module's sources changed, otherwise those whose tfvars file changed
+func (m *Manager) changedInstances(base, product, module, env string, instances []string) ([]string, error) {
+	files, err := m.changedFiles(base)
+	if err != nil {
+		return nil, err
+	}
+
+	modulePrefix := m.projectRelPath(filepath.Join(m.config.GetModulePath(), module)) + "/"
+	instancePrefix := m.projectRelPath(filepath.Join(m.config.GetEnvPath(), product, env, module)) + "/"
+
+	changed := map[string]bool{}
+	for _, file := range files {
+		if strings.HasPrefix(file, modulePrefix) {
+			framework.Info(fmt.Sprintf("Module %s changed since %s; keeping every instance", framework.AddEmphasisBlue(module), base))
+			return instances, nil
+		}
+		name, ok := strings.CutPrefix(file, instancePrefix)
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		if instance, ok := instanceFromVarFile(name); ok {
+			changed[instance] = true
+		}
+	}
+
+	var selected []string
+	for _, instance := range instances {
+		if changed[instance] {
+			selected = append(selected, instance)
+		}
+	}
+	framework.Info(fmt.Sprintf("%d of %d instances changed since %s", len(selected), len(instances), base))
+	return selected, nil
+}
+
+// projectRelPath returns path relative to the project root with forward slashes, as git prints it
+func (m *Manager) projectRelPath(path string) string {
+	rel, err := filepath.Rel(m.config.ProjectDir, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
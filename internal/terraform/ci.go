@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+)
+
+// CIInfo ties a run to the CI pipeline run that executed it
+type CIInfo struct {
+	Provider   string `json:"provider"`
+	Repository string `json:"repository,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+	RunURL     string `json:"run_url,omitempty"`
+	Job        string `json:"job,omitempty"`
+}
+
+// ciProviders detect a CI system from its marker variable and read its pipeline metadata
+var ciProviders = []struct {
+	name   string
+	detect func() bool
+	read   func() CIInfo
+}{
+	{"github", envEquals("GITHUB_ACTIONS", "true"), func() CIInfo {
+		info := CIInfo{Repository: os.Getenv("GITHUB_REPOSITORY"), Commit: os.Getenv("GITHUB_SHA"), RunID: os.Getenv("GITHUB_RUN_ID"), Job: os.Getenv("GITHUB_JOB")}
+		if server := os.Getenv("GITHUB_SERVER_URL"); server != "" && info.Repository != "" && info.RunID != "" {
+			info.RunURL = fmt.Sprintf("%s/%s/actions/runs/%s", server, info.Repository, info.RunID)
+		}
+		return info
+	}},
+	{"gitlab", envEquals("GITLAB_CI", "true"), func() CIInfo {
+		return CIInfo{Repository: os.Getenv("CI_PROJECT_PATH"), Commit: os.Getenv("CI_COMMIT_SHA"), RunID: os.Getenv("CI_PIPELINE_ID"), RunURL: os.Getenv("CI_JOB_URL"), Job: os.Getenv("CI_JOB_NAME")}
+	}},
+	{"bitbucket", envSet("BITBUCKET_BUILD_NUMBER"), func() CIInfo {
+		info := CIInfo{Repository: os.Getenv("BITBUCKET_REPO_FULL_NAME"), Commit: os.Getenv("BITBUCKET_COMMIT"), RunID: os.Getenv("BITBUCKET_BUILD_NUMBER"), Job: os.Getenv("BITBUCKET_STEP_UUID")}
+		if info.Repository != "" {
+			info.RunURL = fmt.Sprintf("https://bitbucket.org/%s/pipelines/results/%s", info.Repository, info.RunID)
+		}
+		return info
+	}},
+	{"circleci", envEquals("CIRCLECI", "true"), func() CIInfo {
+		info := CIInfo{Commit: os.Getenv("CIRCLE_SHA1"), RunID: os.Getenv("CIRCLE_WORKFLOW_ID"), RunURL: os.Getenv("CIRCLE_BUILD_URL"), Job: os.Getenv("CIRCLE_JOB")}
+		if user, repo := os.Getenv("CIRCLE_PROJECT_USERNAME"), os.Getenv("CIRCLE_PROJECT_REPONAME"); user != "" && repo != "" {
+			info.Repository = user + "/" + repo
+		}
+		return info
+	}},
+	{"buildkite", envEquals("BUILDKITE", "true"), func() CIInfo {
+		return CIInfo{Repository: os.Getenv("BUILDKITE_REPO"), Commit: os.Getenv("BUILDKITE_COMMIT"), RunID: os.Getenv("BUILDKITE_BUILD_ID"), RunURL: os.Getenv("BUILDKITE_BUILD_URL"), Job: os.Getenv("BUILDKITE_LABEL")}
+	}},
+	{"azure", envEquals("TF_BUILD", "True"), func() CIInfo {
+		info := CIInfo{Repository: os.Getenv("BUILD_REPOSITORY_NAME"), Commit: os.Getenv("BUILD_SOURCEVERSION"), RunID: os.Getenv("BUILD_BUILDID"), Job: os.Getenv("SYSTEM_JOBDISPLAYNAME")}
+		if collection, project := os.Getenv("SYSTEM_COLLECTIONURI"), os.Getenv("SYSTEM_TEAMPROJECT"); collection != "" && project != "" && info.RunID != "" {
+			info.RunURL = fmt.Sprintf("%s%s/_build/results?buildId=%s", collection, project, info.RunID)
+		}
+		return info
+	}},
+	{"jenkins", envSet("JENKINS_URL"), func() CIInfo {
+		return CIInfo{Repository: os.Getenv("GIT_URL"), Commit: os.Getenv("GIT_COMMIT"), RunID: os.Getenv("BUILD_NUMBER"), RunURL: os.Getenv("BUILD_URL"), Job: os.Getenv("JOB_NAME")}
+	}},
+}
+
+// detectCI returns the metadata of the CI pipeline running tf-manage, or nil outside a known CI system
+func detectCI() *CIInfo {
+	for _, provider := range ciProviders {
+		if provider.detect() {
+			info := provider.read()
+			info.Provider = provider.name
+			return &info
+		}
+	}
+	return nil
+}
+
+// envEquals reports whether the variable key is set to value
+func envEquals(key, value string) func() bool {
+	return func() bool { return os.Getenv(key) == value }
+}
+
+// envSet reports whether the variable key is set to a non-empty value
+func envSet(key string) func() bool {
+	return func() bool { return os.Getenv(key) != "" }
+}
@@ -0,0 +1,104 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// CleanOptions controls what Clean removes and how
+type CleanOptions struct {
+	All      bool // Clean every module instead of a single one
+	LockFile bool // Also remove .terraform.lock.hcl
+	DryRun   bool // Only list what would be removed
+	Force    bool // Skip the confirmation prompt
+}
+
+// Clean removes the .terraform directory (and optionally .terraform.lock.hcl) from a module,
+// or from every module with opts.All, writing each removed path to w
+func (m *Manager) Clean(w io.Writer, product, module string, opts CleanOptions) error {
+	moduleDirs, err := m.cleanTargets(product, module, opts.All)
+	if err != nil {
+		return err
+	}
+
+	var targets []string
+	for _, dir := range moduleDirs {
+		names := []string{".terraform"}
+		if opts.LockFile {
+			names = append(names, ".terraform.lock.hcl")
+		}
+		for _, name := range names {
+			if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+				targets = append(targets, filepath.Join(dir, name))
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		framework.Info("Nothing to clean")
+		return nil
+	}
+
+	if opts.DryRun {
+		for _, target := range targets {
+			fmt.Fprintf(w, "would remove %s\n", target)
+		}
+		return nil
+	}
+
+	if !opts.Force {
+		if m.isUnattended() {
+			framework.Error("Unattended clean requires --force")
+			return NewExitCodeError("clean requires --force in unattended mode", ExitValidationFailed)
+		}
+		framework.Info(fmt.Sprintf("About to remove %d path(s):", len(targets)))
+		for _, target := range targets {
+			framework.Info(fmt.Sprintf("  %s", target))
+		}
+		if !m.confirmByTyping("clean") {
+			framework.Error("Confirmation did not match, aborting clean")
+			return fmt.Errorf("clean aborted by operator")
+		}
+	}
+
+	for _, target := range targets {
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+		fmt.Fprintf(w, "removed %s\n", target)
+	}
+	return nil
+}
+
+// cleanTargets returns the module directories to clean
+func (m *Manager) cleanTargets(product, module string, all bool) ([]string, error) {
+	if !all {
+		productPath := filepath.Join(m.config.GetEnvPath(), product)
+		if info, err := os.Stat(productPath); err != nil || !info.IsDir() {
+			return nil, NewExitCodeError(fmt.Sprintf("product path does not exist: %s", productPath), ExitValidationFailed)
+		}
+		modulePath := filepath.Join(m.config.GetModulePath(), module)
+		if info, err := os.Stat(modulePath); err != nil || !info.IsDir() {
+			return nil, NewExitCodeError(fmt.Sprintf("module path does not exist: %s", modulePath), ExitValidationFailed)
+		}
+		return []string{modulePath}, nil
+	}
+
+	entries, err := os.ReadDir(m.config.GetModulePath())
+	if err != nil {
+		return nil, NewExitCodeError(fmt.Sprintf("module path does not exist: %s", m.config.GetModulePath()), ExitValidationFailed)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(m.config.GetModulePath(), entry.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
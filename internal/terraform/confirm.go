@@ -0,0 +1,68 @@
+package terraform
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// printTargetSummary shows the fully resolved target of a destructive action
+func (m *Manager) printTargetSummary(cmd *Command, workspaceName string) {
+	framework.Info("Target summary:")
+	framework.Info(fmt.Sprintf("  product:   %s", framework.AddEmphasisBlue(cmd.Product)))
+	framework.Info(fmt.Sprintf("  module:    %s", framework.AddEmphasisBlue(cmd.Module)))
+	framework.Info(fmt.Sprintf("  env:       %s", framework.AddEmphasisBlue(cmd.Env)))
+	framework.Info(fmt.Sprintf("  instance:  %s", framework.AddEmphasisBlue(cmd.ModuleInstance)))
+	framework.Info(fmt.Sprintf("  workspace: %s", framework.AddEmphasisBlue(workspaceName)))
+}
+
+// confirmByTyping asks the operator to type the expected value and reports whether it matched
+func (m *Manager) confirmByTyping(expected string) bool {
+	framework.Prompt(fmt.Sprintf("Type %s to confirm:", framework.AddEmphasisRed(expected)))
+
+	reader := bufio.NewReader(m.stdin)
+	answer, err := reader.ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+
+	return strings.TrimSpace(answer) == expected
+}
+
+// protectedActions need an explicit confirmation when the target env is protected
+var protectedActions = map[string]bool{
+	"apply":      true,
+	"apply_plan": true,
+	"deploy":     true,
+	"destroy":    true,
+	"import":     true,
+	"raw":        true, // Raw commands are not rewritten, but still gated
+}
+
+// guardProtectedEnv asks the operator to type the env name before changing a protected env.
+// Unattended runs must set TFM_ALLOW_PROTECTED=1 instead.
+func (m *Manager) guardProtectedEnv(cmd *Command) error {
+	if !protectedActions[cmd.Action] || !m.config.IsProtectedEnv(cmd.Env) {
+		return nil
+	}
+
+	if m.isUnattended() {
+		if os.Getenv("TFM_ALLOW_PROTECTED") != "1" {
+			framework.Error(fmt.Sprintf("Environment %s is protected; set TFM_ALLOW_PROTECTED=1 to %s in unattended mode", framework.AddEmphasisRed(cmd.Env), cmd.Action))
+			return fmt.Errorf("refusing to %s protected environment %s", cmd.Action, cmd.Env)
+		}
+		framework.Info(fmt.Sprintf("Environment %s is protected, allowed by TFM_ALLOW_PROTECTED", framework.AddEmphasisRed(cmd.Env)))
+		return nil
+	}
+
+	framework.Info(fmt.Sprintf("Environment %s is protected.", framework.AddEmphasisRed(cmd.Env)))
+	if !m.confirmByTyping(cmd.Env) {
+		framework.Error(fmt.Sprintf("Confirmation did not match, aborting %s", cmd.Action))
+		return fmt.Errorf("%s aborted by operator", cmd.Action)
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// terraformDeploy plans to the instance's plan file and applies exactly that plan in one
+// invocation, so state cannot change between the reviewed plan and the apply. Operators
+// confirm once after seeing the plan. Action flags go to the plan, except --outputs-file.
+func (m *Manager) terraformDeploy(cmd *Command, paths *Paths, workspaceName string) error {
+	applyCmd := *cmd
+	applyCmd.Action = "apply_plan"
+	applyCmd.ActionFlags = ""
+	if outputsPath, ok := takeValueFlag(cmd, "outputs-file"); ok {
+		applyCmd.ActionFlags = "--outputs-file=" + outputsPath
+	}
+
+	planCmd := *cmd
+	planCmd.Action = "plan"
+	var exitCodeErr *ExitCodeError
+	if err := m.terraformPlan(&planCmd, paths); !errors.As(err, &exitCodeErr) || exitCodeErr.ExitCode != 0 {
+		framework.Error("Plan did not succeed, skipping apply")
+		return err
+	}
+
+	// review_plans already had the operator approve the plan before it was saved
+	if !m.isUnattended() && !m.config.ReviewPlans {
+		m.printTargetSummary(cmd, workspaceName)
+		framework.Info("Review the plan above; it is applied exactly as shown.")
+		if !m.confirmByTyping(cmd.ModuleInstance) {
+			framework.Error("Confirmation did not match, aborting deploy")
+			return fmt.Errorf("deploy aborted by operator")
+		}
+	}
+
+	return m.terraformApplyPlan(&applyCmd, paths)
+}
@@ -0,0 +1,178 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/format"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// Drift statuses reported per instance
+const (
+	DriftInSync  = "in-sync"
+	DriftDrifted = "drifted"
+	DriftError   = "error"
+)
+
+// driftExitCode is returned when at least one instance drifted, matching -detailed-exitcode
+const driftExitCode = 2
+
+// InstanceDrift is the outcome of checking a single module instance for drift
+type InstanceDrift struct {
+	Instance  string `json:"instance"`
+	Workspace string `json:"workspace"`
+	Status    string `json:"status"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DriftOptions controls which instances DetectDrift checks
+type DriftOptions struct {
+	ChangedSince string // Only check instances whose tfvars file or module changed since this git ref
+}
+
+// DetectDrift runs a refresh-only plan for every instance of module in product/env and
+// writes a summary in the requested format. It exits 2 if any instance drifted and 1 if
+// any check failed.
+func (m *Manager) DetectDrift(w io.Writer, formatName, product, module, env string, opts DriftOptions) error {
+	if err := checkTerraformInstalled(); err != nil {
+		return err
+	}
+
+	base := m.config
+	m.config = base.ForEnv(env)
+	defer func() { m.config = base }()
+
+	instances, err := m.listInstances(product, module, env)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("no instances found for %s/%s/%s", product, env, module)
+	}
+	if opts.ChangedSince != "" {
+		if instances, err = m.changedInstances(opts.ChangedSince, product, module, env, instances); err != nil {
+			return err
+		}
+	}
+
+	results := make([]InstanceDrift, 0, len(instances))
+	for _, instance := range instances {
+		cmd := &Command{Product: product, Module: module, Env: env, ModuleInstance: instance, Action: "drift"}
+		results = append(results, m.checkDrift(cmd))
+	}
+
+	if err := format.Write(w, formatName, driftSummary(results)); err != nil {
+		return err
+	}
+
+	drifted, failed := countDrift(results)
+	if failed > 0 {
+		framework.Error(fmt.Sprintf("%d of %d instances could not be checked for drift", failed, len(results)))
+		return NewExitCodeError("drift check failed", 1)
+	}
+	if drifted > 0 {
+		framework.Error(fmt.Sprintf("%d of %d instances have drifted", drifted, len(results)))
+		return NewExitCodeError("drift detected", driftExitCode)
+	}
+
+	framework.Info(fmt.Sprintf("All %d instances are in sync", len(results)))
+	return nil
+}
+
+// listInstances returns the instance names (tfvars files) configured for module in product/env
+func (m *Manager) listInstances(product, module, env string) ([]string, error) {
+	moduleEnvPath := filepath.Join(m.config.GetEnvPath(), product, env, module)
+	entries, err := os.ReadDir(moduleEnvPath)
+	if err != nil {
+		return nil, fmt.Errorf("instance path does not exist: %s", moduleEnvPath)
+	}
+
+	var instances []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		// An instance with both a .tfvars and a .tfvars.json file is listed once
+		if instance, ok := instanceFromVarFile(entry.Name()); ok && !slices.Contains(instances, instance) {
+			instances = append(instances, instance)
+		}
+	}
+	return instances, nil
+}
+
+// checkDrift runs `terraform plan -detailed-exitcode -refresh-only` for one instance
+func (m *Manager) checkDrift(cmd *Command) InstanceDrift {
+	paths := m.computePaths(cmd)
+	workspaceName, err := m.instanceWorkspace(cmd, paths)
+	result := InstanceDrift{Instance: cmd.ModuleInstance, Workspace: workspaceName}
+
+	if err == nil {
+		var leave func()
+		if _, leave, err = m.enterInstance(cmd); err == nil {
+			defer leave()
+		}
+	}
+	if err != nil {
+		result.Status = DriftError
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result
+	}
+
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintMessage = false
+	flags.DecorateOutput = true                    // Force non-interactive mode to capture output
+	flags.ValidExitCodes = []int{0, driftExitCode} // Exit code 2 means changes, not failure
+
+	res := m.run(
+		fmt.Sprintf("terraform plan %s%s -detailed-exitcode -refresh-only -input=false -no-color%s",
+			m.generateVarFlags(cmd, paths), m.lockTimeoutFlag(), m.actionFlags(cmd)),
+		fmt.Sprintf("Checking instance %s for drift", framework.AddEmphasisBlue(cmd.ModuleInstance)),
+		flags,
+	)
+
+	result.ExitCode = res.ExitCode
+	switch {
+	case !res.Success:
+		result.Status = DriftError
+		result.Error = strings.TrimSpace(res.Error)
+	case res.ExitCode == driftExitCode:
+		result.Status = DriftDrifted
+	default:
+		result.Status = DriftInSync
+	}
+	return result
+}
+
+// driftSummary converts drift results into a formatter result
+func driftSummary(results []InstanceDrift) *format.Result {
+	summary := &format.Result{
+		Title:   "Drift summary",
+		Columns: []string{"instance", "status", "exit_code"},
+		Data:    results,
+	}
+	for _, result := range results {
+		summary.Rows = append(summary.Rows, []string{result.Instance, result.Status, fmt.Sprint(result.ExitCode)})
+	}
+	return summary
+}
+
+// countDrift returns how many instances drifted and how many could not be checked
+func countDrift(results []InstanceDrift) (drifted, failed int) {
+	for _, result := range results {
+		switch result.Status {
+		case DriftDrifted:
+			drifted++
+		case DriftError:
+			failed++
+		}
+	}
+	return drifted, failed
+}
@@ -0,0 +1,134 @@
+package terraform
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// envVar is one KEY=VALUE assignment from a dotenv file
+type envVar struct {
+	Key   string
+	Value string
+}
+
+// envKeyPattern matches the variable names a dotenv file may assign
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// loadEnvFile passes the variables in the dotenv file at path to the following terraform
+// commands, leaving tf-manage's own environment alone. Only the variable names are logged,
+// never their values.
+func (m *Manager) loadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		framework.Error(fmt.Sprintf("Could not read env file %s", framework.AddEmphasisRed(path)))
+		return NewExitCodeError(fmt.Sprintf("failed to read env file: %v", err), ExitValidationFailed)
+	}
+	defer file.Close()
+
+	vars, err := parseEnvFile(file)
+	if err != nil {
+		framework.Error(fmt.Sprintf("Invalid env file %s", framework.AddEmphasisRed(path)))
+		return NewExitCodeError(fmt.Sprintf("invalid env file %s: %v", path, err), ExitValidationFailed)
+	}
+
+	names := make([]string, 0, len(vars))
+	for _, v := range vars {
+		m.setEnv(v.Key, v.Value)
+		names = append(names, v.Key)
+	}
+	framework.Info(fmt.Sprintf("Loaded %d variables from %s: %s", len(vars), framework.AddEmphasisBlue(path), strings.Join(names, ", ")))
+	return nil
+}
+
+// parseEnvFile reads KEY=VALUE lines. Blank lines and lines starting with # are skipped,
+// an "export " prefix is allowed, single quoted values are taken literally, double quoted
+// values support \n, \t, \" and \\ escapes, and unquoted values end at " #".
+func parseEnvFile(r io.Reader) ([]envVar, error) {
+	var vars []envVar
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !envKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		value, err := parseEnvValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNumber, key, err)
+		}
+		vars = append(vars, envVar{Key: key, Value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseEnvValue unquotes a dotenv value
+func parseEnvValue(raw string) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		return raw[1 : end+1], checkTrailing(raw[end+2:])
+
+	case '"':
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; {
+			case c == '"':
+				return value.String(), checkTrailing(raw[i+1:])
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(raw[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+	}
+
+	// Unquoted: an inline comment starts at whitespace followed by #
+	if idx := strings.Index(raw, " #"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	if idx := strings.Index(raw, "\t#"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	return strings.TrimSpace(raw), nil
+}
+
+// checkTrailing allows only whitespace or a comment after a quoted value
+func checkTrailing(rest string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected text after quoted value")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsEnvPattern reports whether env is a glob such as prod/* rather than a single env
+func IsEnvPattern(env string) bool {
+	return strings.ContainsAny(env, "*?[")
+}
+
+// ExpandEnvPattern returns the envs of product matching pattern (e.g. prod/* for every
+// region of prod) that have a directory for module, in lexical order
+func (m *Manager) ExpandEnvPattern(product, module, pattern string) ([]string, error) {
+	productPath := filepath.Join(m.config.GetEnvPath(), product)
+	matches, err := filepath.Glob(filepath.Join(productPath, filepath.FromSlash(pattern)))
+	if err != nil {
+		return nil, NewExitCodeError(fmt.Sprintf("invalid env pattern %q: %v", pattern, err), ExitValidationFailed)
+	}
+
+	var envs []string
+	for _, match := range matches {
+		if info, err := os.Stat(filepath.Join(match, module)); err != nil || !info.IsDir() {
+			continue
+		}
+		env, err := filepath.Rel(productPath, match)
+		if err != nil {
+			continue
+		}
+		envs = append(envs, filepath.ToSlash(env))
+	}
+
+	if len(envs) == 0 {
+		return nil, NewExitCodeError(fmt.Sprintf("no env of %s matching %s has a %s directory", product, pattern, module), ExitValidationFailed)
+	}
+	return envs, nil
+}
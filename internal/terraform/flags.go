@@ -0,0 +1,126 @@
+package terraform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// takeBoolFlag removes a tf-manage specific --name flag from the action flags
+// and reports whether it was present
+func takeBoolFlag(cmd *Command, name string) bool {
+	found := false
+	var rest []string
+	for _, field := range strings.Fields(cmd.ActionFlags) {
+		if field == "--"+name {
+			found = true
+			continue
+		}
+		rest = append(rest, field)
+	}
+	cmd.ActionFlags = strings.Join(rest, " ")
+	return found
+}
+
+// takeValueFlag removes a tf-manage specific --name=value or --name value flag
+// from the action flags and returns its value
+func takeValueFlag(cmd *Command, name string) (string, bool) {
+	values := takeValueFlags(cmd, name)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[len(values)-1], true
+}
+
+// takeValueFlags removes every occurrence of a repeatable --name=value or --name value
+// flag from the action flags and returns the values in order
+func takeValueFlags(cmd *Command, name string) []string {
+	var values []string
+	fields := strings.Fields(cmd.ActionFlags)
+	var rest []string
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case strings.HasPrefix(field, "--"+name+"="):
+			values = append(values, strings.TrimPrefix(field, "--"+name+"="))
+		case field == "--"+name && i+1 < len(fields):
+			values = append(values, fields[i+1])
+			i++
+		default:
+			rest = append(rest, field)
+		}
+	}
+	cmd.ActionFlags = strings.Join(rest, " ")
+	return values
+}
+
+// hasActionFlag reports whether a terraform flag was passed in the action flags
+func hasActionFlag(cmd *Command, flag string) bool {
+	for _, field := range strings.Fields(cmd.ActionFlags) {
+		if field == flag || strings.HasPrefix(field, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// parallelismFlag takes --parallelism N from the action flags and returns terraform's
+// -parallelism=N argument, or "" when the flag was not given
+func parallelismFlag(cmd *Command) (string, error) {
+	value, found := takeValueFlag(cmd, "parallelism")
+	if !found {
+		if hasActionFlag(cmd, "--parallelism") {
+			return "", NewExitCodeError("--parallelism requires a value", ExitValidationFailed)
+		}
+		return "", nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return "", NewExitCodeError(fmt.Sprintf("invalid --parallelism value %q (expected a positive integer)", value), ExitValidationFailed)
+	}
+	return fmt.Sprintf(" -parallelism=%d", n), nil
+}
+
+// noRefreshFlag takes --no-refresh from the action flags and returns terraform's -refresh=false
+// argument, or "" when the flag was not given. It cannot be combined with -refresh-only.
+func noRefreshFlag(cmd *Command) (string, error) {
+	if !takeBoolFlag(cmd, "no-refresh") {
+		return "", nil
+	}
+	if hasActionFlag(cmd, "-refresh-only") || hasActionFlag(cmd, "--refresh-only") {
+		return "", NewExitCodeError("--no-refresh cannot be combined with -refresh-only", ExitValidationFailed)
+	}
+	framework.Info(fmt.Sprintf("Skipping refresh (%s); the result may be based on stale state", framework.AddEmphasisRed("-refresh=false")))
+	return " -refresh=false", nil
+}
+
+// Address patterns: a resource such as module.app[0].aws_instance.web["a"], and a bare
+// module path such as module.app, which -replace does not accept
+var (
+	resourceAddressPattern = regexp.MustCompile(
+		`^(?:module\.[A-Za-z_][A-Za-z0-9_-]*(?:\[(?:[0-9]+|"[^"]*")\])?\.)*` +
+			`(?:data\.)?[A-Za-z_][A-Za-z0-9_-]*\.[A-Za-z_][A-Za-z0-9_-]*(?:\[(?:[0-9]+|"[^"]*")\])?$`)
+	moduleAddressPattern = regexp.MustCompile(`^(?:module\.[A-Za-z_][A-Za-z0-9_-]*(?:\[(?:[0-9]+|"[^"]*")\])?\.?)+$`)
+)
+
+// replaceFlags takes every --replace ADDRESS from the action flags and returns terraform's
+// -replace=ADDRESS arguments, or "" when the flag was not given
+func replaceFlags(cmd *Command) (string, error) {
+	addresses := takeValueFlags(cmd, "replace")
+	if len(addresses) == 0 && hasActionFlag(cmd, "--replace") {
+		return "", NewExitCodeError("--replace requires a resource address", ExitValidationFailed)
+	}
+
+	var flags strings.Builder
+	for _, address := range addresses {
+		if !resourceAddressPattern.MatchString(address) || moduleAddressPattern.MatchString(address) {
+			return "", NewExitCodeError(fmt.Sprintf("invalid --replace address %q (expected a resource address such as aws_instance.web)", address), ExitValidationFailed)
+		}
+		flags.WriteString(" " + quoteArg("-replace="+address))
+	}
+	return flags.String(), nil
+}
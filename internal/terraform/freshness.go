@@ -0,0 +1,56 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// checkPlanFresh refuses a plan file that is missing or older than the module's .tf files
+// or the instance tfvars file, since applying it could revert those changes
+func checkPlanFresh(paths *Paths) error {
+	planInfo, err := os.Stat(paths.PlanFile)
+	if err != nil {
+		framework.Error(fmt.Sprintf("No plan found at %s, run plan first", framework.AddEmphasisBlue(paths.PlanFile)))
+		return fmt.Errorf("refusing to apply without a plan")
+	}
+
+	newer, err := filesNewerThan(planInfo.ModTime().UnixNano(), paths)
+	if err != nil {
+		return err
+	}
+	if len(newer) == 0 {
+		return nil
+	}
+
+	framework.Error("The plan is older than these files, run plan again:")
+	for _, path := range newer {
+		framework.Error(fmt.Sprintf("  %s", path))
+	}
+	return fmt.Errorf("refusing to apply a stale plan (%d newer files)", len(newer))
+}
+
+// filesNewerThan returns the module .tf files and the tfvars file modified after planTime
+func filesNewerThan(planTime int64, paths *Paths) ([]string, error) {
+	candidates, err := filepath.Glob(filepath.Join(paths.ModulePath, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	candidates = append(candidates, paths.VarFile)
+
+	var newer []string
+	for _, path := range candidates {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().UnixNano() > planTime {
+			newer = append(newer, path)
+		}
+	}
+	sort.Strings(newer)
+	return newer, nil
+}
@@ -0,0 +1,78 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// Inspection is what tf-manage resolves for an invocation before running terraform
+type Inspection struct {
+	Product       string `json:"product"`
+	Module        string `json:"module"`
+	Env           string `json:"env"`
+	Instance      string `json:"instance"`
+	ModulePath    string `json:"module_path"`
+	EnvPath       string `json:"env_path"`
+	ModuleEnvPath string `json:"module_env_path"`
+	VarFile       string `json:"var_file"`
+	PlanFile      string `json:"plan_file"`
+	Workspace     string `json:"workspace"`
+}
+
+// Inspect writes the paths and workspace tf-manage would use for cmd without running
+// terraform or changing anything. Paths that do not exist are marked in the text output.
+func (m *Manager) Inspect(w io.Writer, cmd *Command, asJSON bool) error {
+	base := m.config
+	m.config = base.ForEnv(cmd.Env)
+	defer func() { m.config = base }()
+
+	paths := m.computePaths(cmd)
+	workspaceName, err := m.instanceWorkspace(cmd, paths)
+	if err != nil {
+		return err
+	}
+	inspection := Inspection{
+		Product:       cmd.Product,
+		Module:        cmd.Module,
+		Env:           cmd.Env,
+		Instance:      cmd.ModuleInstance,
+		ModulePath:    paths.ModulePath,
+		EnvPath:       paths.EnvPath,
+		ModuleEnvPath: paths.ModuleEnvPath,
+		VarFile:       paths.VarFile,
+		PlanFile:      paths.PlanFile,
+		Workspace:     workspaceName,
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(inspection)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	rows := []struct {
+		label, value string
+		path         bool
+	}{
+		{"Module path", inspection.ModulePath, true},
+		{"Env path", inspection.EnvPath, true},
+		{"Module env path", inspection.ModuleEnvPath, true},
+		{"Var file", inspection.VarFile, true},
+		{"Plan file", inspection.PlanFile, true},
+		{"Workspace", inspection.Workspace, false},
+	}
+	for _, row := range rows {
+		value := row.value
+		if row.path {
+			if _, err := os.Stat(value); err != nil {
+				value += " (missing)"
+			}
+		}
+		fmt.Fprintf(tw, "%s:\t%s\n", row.label, value)
+	}
+	return tw.Flush()
+}
@@ -1,11 +1,18 @@
 package terraform
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/sorinlg/tf-manage2/internal/config"
 	"github.com/sorinlg/tf-manage2/internal/framework"
@@ -21,6 +28,14 @@ func (e *ExitCodeError) Error() string {
 	return e.Message
 }
 
+// Exit codes for failures detected by tf-manage itself. Terraform runtime failures
+// keep terraform's own exit code so callers can tell the categories apart.
+const (
+	ExitValidationFailed = 64 // Config, product, module, env or instance is missing or invalid
+	ExitWorkspaceFailed  = 65 // The terraform workspace could not be listed, created or selected
+	ExitApprovalDenied   = 66 // approval_command did not approve an unattended apply or destroy
+)
+
 // NewExitCodeError creates a new error with the specified exit code
 func NewExitCodeError(message string, exitCode int) *ExitCodeError {
 	return &ExitCodeError{
@@ -29,16 +44,141 @@ func NewExitCodeError(message string, exitCode int) *ExitCodeError {
 	}
 }
 
+// runCmd executes system commands; replaced in tests to fake terraform
+var runCmd = framework.RunCmd
+
+// runNative executes the filesystem checks of validateCommand; replaced in tests to count them
+var runNative = framework.RunNative
+
+// Options tunes how the Manager runs terraform
+type Options struct {
+	Verbose    bool          // Echo every terraform command before running it
+	RedactVars bool          // Hide -var values in echoed commands
+	Timeout    time.Duration // Interrupt terraform commands running longer than this (0: no limit)
+
+	TerraformColor      bool // Keep terraform's colored output in unattended mode instead of adding -no-color
+	RespectEnvWorkspace bool // Use a TF_WORKSPACE exported before tf-manage ran instead of the computed workspace
+
+	EnvFile string // Dotenv file exported before terraform runs (relative to the invocation directory)
+
+	Timings bool // Record and print how long validation, workspace selection and the action took
+
+	OutDir string // Absolute base directory for generated artifacts, replacing the artifact_dir setting
+
+	SkipValidation bool // Trust the project layout and skip the product, repo, module, env and config checks
+	SkipIfMissing  bool // Succeed without running terraform when the instance tfvars does not exist
+
+	ErrorOnWarn bool // Fail instead of warning when exported TF_VAR_tfm_* values conflict with the injected ones
+}
+
 // Manager handles terraform operations with tf-manage conventions
 type Manager struct {
-	config *config.Config
+	config        *config.Config
+	options       Options
+	stdin         io.Reader // Source for tf-manage level confirmation prompts
+	invocationDir string    // Working directory tf-manage was invoked from
+	workDir       string    // Directory terraform commands run in (the module directory once selected)
+	env           []string  // KEY=VALUE entries passed to terraform commands (TF_WORKSPACE once selected)
+	info          RunInfo   // What the last Execute call ran
+}
+
+// RunInfo describes the command run by the last Execute call
+type RunInfo struct {
+	Action           string `json:"action"`
+	Workspace        string `json:"workspace"`
+	ExecMode         string `json:"exec_mode"`
+	TerraformVersion string `json:"terraform_version"`
+
+	Timings []PhaseTiming `json:"timings,omitempty"`
+	CI      *CIInfo       `json:"ci,omitempty"` // Pipeline run that executed tf-manage, when in CI
+}
+
+// RunInfo returns what the last Execute call ran. Fields are empty when Execute stopped
+// before they were known.
+func (m *Manager) RunInfo() RunInfo {
+	return m.info
 }
 
 // NewManager creates a new terraform manager
 func NewManager(cfg *config.Config) *Manager {
 	return &Manager{
 		config: cfg,
+		stdin:  os.Stdin,
+	}
+}
+
+// SetOptions replaces the manager's runtime options
+func (m *Manager) SetOptions(opts Options) {
+	m.options = opts
+}
+
+// run executes a terraform command, applying the manager's options to flags
+func (m *Manager) run(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+	if flags == nil {
+		flags = framework.DefaultCmdFlags()
+	}
+
+	if m.options.Timeout > 0 && flags.Timeout == 0 {
+		flags.Timeout = m.options.Timeout
 	}
+	if flags.Dir == "" {
+		flags.Dir = m.workDir
+	}
+	if len(m.env) > 0 {
+		// Entries set on the command itself come last so they win
+		flags.Env = append(slices.Clone(m.env), flags.Env...)
+	}
+
+	if m.options.Verbose {
+		flags.PrintCmd = true
+		if m.options.RedactVars {
+			flags.DisplayCommand = redactVarValues(command)
+		}
+	}
+
+	return runCmd(command, message, flags, failMessage...)
+}
+
+// setEnv passes key=value to the following terraform commands, replacing an earlier value
+func (m *Manager) setEnv(key, value string) {
+	m.env = slices.DeleteFunc(m.env, func(entry string) bool {
+		return strings.HasPrefix(entry, key+"=")
+	})
+	m.env = append(m.env, key+"="+value)
+}
+
+// lookupEnv returns the value terraform commands will see for key: one passed with setEnv,
+// otherwise the process environment's
+func (m *Manager) lookupEnv(key string) (string, bool) {
+	for i := len(m.env) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(m.env[i], key+"="); ok {
+			return value, true
+		}
+	}
+	return os.LookupEnv(key)
+}
+
+// runInteractive executes a terraform command that needs the operator's terminal
+func (m *Manager) runInteractive(command, message string, failMessage ...string) *framework.CmdResult {
+	flags := framework.DefaultCmdFlags()
+	flags.DecorateOutput = false // Disable decoration for interactive commands
+	flags.PrintOutput = true     // Keep output enabled for user feedback
+
+	return m.run(command, message, flags, failMessage...)
+}
+
+// varValuePatterns match the value part of -var assignments in a command line
+var varValuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(-var\s+')([^'=]+)=[^']*(?:'"'"'[^']*)*'`),
+	regexp.MustCompile(`(-var\s+")([^"=]+)=[^"]*"`),
+	regexp.MustCompile(`(-var[\s=]+)([^\s'"=]+)=\S*`),
+}
+
+// redactVarValues replaces the values of -var assignments with *** while keeping the names
+func redactVarValues(command string) string {
+	command = varValuePatterns[0].ReplaceAllString(command, "${1}${2}=***'")
+	command = varValuePatterns[1].ReplaceAllString(command, `${1}${2}=***"`)
+	return varValuePatterns[2].ReplaceAllString(command, "${1}${2}=***")
 }
 
 // Command represents a terraform command to execute
@@ -50,14 +190,41 @@ type Command struct {
 	Action         string
 	ActionFlags    string
 	Workspace      string
+	Vars           []string // Extra key=value variable overrides, applied in order
 }
 
 // Execute runs the terraform command with tf-manage conventions
 func (m *Manager) Execute(cmd *Command) error {
+	// Resolve paths and flags against the env's overrides for the duration of this command
+	base := m.config
+	m.config = base.ForEnv(cmd.Env)
+	defer func() { m.config = base }()
+
+	if m.config.MaskIdentifiers {
+		m.registerMasks(cmd)
+	}
+
+	m.info = RunInfo{Action: cmd.Action, ExecMode: m.execModeName(), CI: detectCI()}
+	defer m.printTimings()
+
+	// Fail early with one clear message instead of an exec error from the first command
+	if err := checkTerraformInstalled(); err != nil {
+		return err
+	}
+
 	framework.Info(fmt.Sprintf("Detected exec mode: %s", m.detectExecMode()))
 
+	// Teardown loops treat an instance that is already gone as done
+	if m.options.SkipIfMissing {
+		varFile := m.computePaths(cmd).VarFile
+		if _, err := os.Stat(varFile); os.IsNotExist(err) {
+			framework.Info(fmt.Sprintf("Config file \"%s\" does not exist, skipping %s", framework.AddEmphasisBlue(varFile), cmd.Action))
+			return nil
+		}
+	}
+
 	// Validate the command
-	if err := m.validateCommand(cmd); err != nil {
+	if err := m.timePhase("validate", func() error { return m.validateCommand(cmd) }); err != nil {
 		return err
 	}
 
@@ -65,33 +232,101 @@ func (m *Manager) Execute(cmd *Command) error {
 	paths := m.computePaths(cmd)
 
 	// Generate workspace name
-	workspaceName := m.generateWorkspace(cmd, paths)
+	workspaceName, err := m.instanceWorkspace(cmd, paths)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	workspaceName = m.resolveEnvWorkspace(workspaceName)
+	m.registerWorkspaceMask(workspaceName)
+	m.info.Workspace = workspaceName
 
 	// Show Terraform CLI version in the banner
 	ver := getTerraformVersion()
 	if ver != "unknown" && !strings.HasPrefix(ver, "v") {
 		ver = "v" + ver
 	}
+	m.info.TerraformVersion = ver
 	framework.Info(fmt.Sprintf("*** Terraform %s ***", ver))
+
+	// Refuse to touch state with a terraform older than the configured minimum
+	if err := m.checkMinTerraformVersion(cmd.Action, ver); err != nil {
+		return err
+	}
+
+	// Protected environments need an explicit confirmation before they are changed
+	if err := m.guardProtectedEnv(cmd); err != nil {
+		return err
+	}
+
+	// Change management systems get the final say on unattended changes
+	if err := m.requestApproval(cmd, workspaceName); err != nil {
+		return err
+	}
 	framework.Info(fmt.Sprintf("Running from \"%s\"", paths.ModulePath))
 
-	// Change to module directory
-	if err := os.Chdir(paths.ModulePath); err != nil {
-		return fmt.Errorf("failed to change to module directory %s: %w", paths.ModulePath, err)
+	// Remember where we were invoked from so user supplied paths stay relative to it
+	if wd, err := os.Getwd(); err == nil {
+		m.invocationDir = wd
+	}
+
+	// Run terraform in the module directory without changing the process's working directory
+	// or environment
+	m.workDir = paths.ModulePath
+	defer func() {
+		m.workDir = ""
+		m.env = nil
+	}()
+
+	// Pass per-environment secrets to terraform so it and its providers pick them up
+	if m.options.EnvFile != "" {
+		if err := m.loadEnvFile(m.resolveUserPath(m.options.EnvFile)); err != nil {
+			return err
+		}
+	}
+
+	// Exported TF_VAR_tfm_* values would be silently overridden by the injected -var flags
+	if tfmVarActions[cmd.Action] {
+		if err := m.checkTfmVarEnv(cmd, paths); err != nil {
+			return err
+		}
 	}
 
 	framework.Info(fmt.Sprintf("Executing terraform %s", cmd.Action))
 
-	// Check terraform workspace exists and is active
-	// Skip workspace validation for workspace, init, and fmt commands (matching bash __tf_controller logic)
-	if cmd.Action != "workspace" && cmd.Action != "init" && cmd.Action != "fmt" {
-		if err := m.ensureWorkspace(workspaceName); err != nil {
-			return fmt.Errorf("failed to ensure workspace: %w", err)
+	// Check terraform workspace exists and is active, or without workspaces that the
+	// module is initialized with this instance's backend key
+	switch {
+	case !m.config.WorkspacesEnabled() && (cmd.Action == "workspace" || cmd.Action == "delete-workspace"):
+		framework.Error(fmt.Sprintf("The %s action needs workspaces, which are disabled by use_workspaces: false", cmd.Action))
+		return NewExitCodeError(fmt.Sprintf("%s is not available with use_workspaces: false", cmd.Action), ExitValidationFailed)
+	case !needsWorkspace(cmd.Action):
+	case m.config.WorkspacesEnabled():
+		if err := m.timePhase("workspace", func() error { return m.ensureWorkspace(workspaceName) }); err != nil {
+			return err
+		}
+	default:
+		if err := m.timePhase("workspace", func() error { return m.checkBackendKey(cmd, paths) }); err != nil {
+			return err
 		}
 	}
 
 	// Execute the terraform command
-	return m.executeTerraformAction(cmd, paths, workspaceName)
+	return m.timePhase("terraform "+cmd.Action, func() error {
+		return m.executeTerraformAction(cmd, paths, workspaceName)
+	})
+}
+
+// needsWorkspace reports whether action must run in the instance workspace.
+// workspace, init and fmt skip workspace validation (matching bash __tf_controller logic),
+// delete-workspace must not create the workspace it is about to remove, and metadata is a
+// static query that does not touch state.
+func needsWorkspace(action string) bool {
+	switch action {
+	case "workspace", "init", "fmt", "delete-workspace", "metadata":
+		return false
+	}
+	return true
 }
 
 // Paths holds all the computed paths for the command
@@ -104,6 +339,12 @@ type Paths struct {
 }
 
 func (m *Manager) validateCommand(cmd *Command) error {
+	// Trusted pipelines guarantee the layout and can save the checks
+	if m.options.SkipValidation {
+		framework.Debug("Skipping product, repo, module, env and config validation")
+		return nil
+	}
+
 	// Check product exists
 	productPath := filepath.Join(m.config.GetEnvPath(), cmd.Product)
 
@@ -111,7 +352,7 @@ func (m *Manager) validateCommand(cmd *Command) error {
 	flags.PrintOutput = false
 	flags.PrintMessage = false
 
-	result := framework.RunNative(
+	result := runNative(
 		framework.NativeTestDir(productPath),
 		fmt.Sprintf("Checking product %s is valid", framework.AddEmphasisBlue(cmd.Product)),
 		flags,
@@ -119,16 +360,16 @@ func (m *Manager) validateCommand(cmd *Command) error {
 	)
 
 	if !result.Success {
-		return fmt.Errorf("product validation failed")
+		return NewExitCodeError("product validation failed", ExitValidationFailed)
 	}
 
 	// Check repo is valid
 	if m.config.RepoName == "" {
 		framework.Error("Repo name is empty!")
-		return fmt.Errorf("repo validation failed")
+		return NewExitCodeError("repo validation failed", ExitValidationFailed)
 	}
 
-	result = framework.RunNative(
+	result = runNative(
 		framework.NativeTestNotEmpty(m.config.RepoName),
 		fmt.Sprintf("Checking repo %s is valid", framework.AddEmphasisBlue(m.config.RepoName)),
 		flags,
@@ -136,12 +377,12 @@ func (m *Manager) validateCommand(cmd *Command) error {
 	)
 
 	if !result.Success {
-		return fmt.Errorf("repo validation failed")
+		return NewExitCodeError("repo validation failed", ExitValidationFailed)
 	}
 
 	// Check module exists
 	modulePath := filepath.Join(m.config.GetModulePath(), cmd.Module)
-	result = framework.RunNative(
+	result = runNative(
 		framework.NativeTestDir(modulePath),
 		fmt.Sprintf("Checking module %s exists", framework.AddEmphasisBlue(cmd.Module)),
 		flags,
@@ -149,12 +390,12 @@ func (m *Manager) validateCommand(cmd *Command) error {
 	)
 
 	if !result.Success {
-		return fmt.Errorf("module validation failed")
+		return NewExitCodeError("module validation failed", ExitValidationFailed)
 	}
 
 	// Check environment exists
 	envPath := filepath.Join(m.config.GetEnvPath(), cmd.Product, cmd.Env)
-	result = framework.RunNative(
+	result = runNative(
 		framework.NativeTestDir(envPath),
 		fmt.Sprintf("Checking environment %s exists", framework.AddEmphasisBlue(cmd.Env)),
 		flags,
@@ -162,20 +403,33 @@ func (m *Manager) validateCommand(cmd *Command) error {
 	)
 
 	if !result.Success {
-		return fmt.Errorf("environment validation failed")
+		return NewExitCodeError("environment validation failed", ExitValidationFailed)
+	}
+
+	// The separator stands in for "/" in workspace names, so an env containing it would be ambiguous
+	if separator := m.config.EnvSeparator(); strings.Contains(cmd.Env, separator) {
+		framework.Error(fmt.Sprintf("Environment %s contains the workspace env separator %s; rename the env or set workspace_env_separator to a string it does not contain",
+			framework.AddEmphasisRed(cmd.Env), framework.AddEmphasisRed(separator)))
+		return NewExitCodeError(fmt.Sprintf("environment %s contains the workspace env separator %q", cmd.Env, separator), ExitValidationFailed)
 	}
 
 	// Check config file exists
-	varFile := filepath.Join(envPath, cmd.Module, cmd.ModuleInstance+".tfvars")
-	result = framework.RunNative(
+	varFile := instanceVarFile(filepath.Join(envPath, cmd.Module), cmd.ModuleInstance)
+	result = runNative(
 		framework.NativeTestFile(varFile),
-		fmt.Sprintf("Checking config %s.tfvars exists", framework.AddEmphasisBlue(cmd.ModuleInstance)),
+		fmt.Sprintf("Checking config %s exists", framework.AddEmphasisBlue(filepath.Base(varFile))),
 		flags,
 		fmt.Sprintf("Config file \"%s\" was not found!", framework.AddEmphasisBlue(varFile)),
 	)
 
 	if !result.Success {
-		return fmt.Errorf("config validation failed")
+		return NewExitCodeError("config validation failed", ExitValidationFailed)
+	}
+
+	// An empty tfvars file would leave terraform prompting for every variable
+	if err := checkVarFile(varFile, m.config.CheckTfvarsSyntax); err != nil {
+		framework.Error(fmt.Sprintf("Config file \"%s\" %v", framework.AddEmphasisRed(varFile), err))
+		return NewExitCodeError(fmt.Sprintf("config file %s %v", varFile, err), ExitValidationFailed)
 	}
 
 	return nil
@@ -185,8 +439,13 @@ func (m *Manager) computePaths(cmd *Command) *Paths {
 	modulePath := filepath.Join(m.config.GetModulePath(), cmd.Module)
 	envPath := filepath.Join(m.config.GetEnvPath(), cmd.Product, cmd.Env)
 	moduleEnvPath := filepath.Join(envPath, cmd.Module)
-	varFile := filepath.Join(moduleEnvPath, cmd.ModuleInstance+".tfvars")
+	varFile := instanceVarFile(moduleEnvPath, cmd.ModuleInstance)
 	planFile := filepath.Join(moduleEnvPath, cmd.ModuleInstance+".tfvars.tfplan")
+	if artifactDir := m.artifactDir(cmd); artifactDir != "" {
+		planFile = filepath.Join(artifactDir, "plan.tfplan")
+	} else if planDir := m.config.GetPlanDir(); planDir != "" {
+		planFile = filepath.Join(planDir, cmd.Product, cmd.Env, cmd.Module, cmd.ModuleInstance+".tfplan")
+	}
 
 	return &Paths{
 		ModulePath:    modulePath,
@@ -197,9 +456,28 @@ func (m *Manager) computePaths(cmd *Command) *Paths {
 	}
 }
 
+// resolveEnvWorkspace compares the computed workspace with a TF_WORKSPACE exported before
+// tf-manage ran. The computed workspace wins, with a warning, unless RespectEnvWorkspace is set.
+func (m *Manager) resolveEnvWorkspace(workspaceName string) string {
+	preset := os.Getenv("TF_WORKSPACE")
+	if preset == "" || preset == workspaceName {
+		return workspaceName
+	}
+
+	if m.options.RespectEnvWorkspace {
+		framework.Info(fmt.Sprintf("Using workspace %s from TF_WORKSPACE instead of %s",
+			framework.AddEmphasisBlue(preset), framework.AddEmphasisBlue(workspaceName)))
+		return preset
+	}
+
+	framework.Error(fmt.Sprintf("TF_WORKSPACE is set to %s but this instance uses %s; overriding it (use --respect-env-workspace to keep it)",
+		framework.AddEmphasisRed(preset), framework.AddEmphasisBlue(workspaceName)))
+	return workspaceName
+}
+
 func (m *Manager) generateWorkspace(cmd *Command, paths *Paths) string {
-	// Replace forward slashes with double underscores in env path
-	envSanitized := strings.ReplaceAll(cmd.Env, "/", "__")
+	// Replace forward slashes in nested env paths with the separator (double underscores by default)
+	envSanitized := strings.ReplaceAll(cmd.Env, "/", m.config.EnvSeparator())
 
 	// Generate workspace name: {product}.{repo}.{module}.{env}.{module_instance}
 	workspace := fmt.Sprintf("%s.%s.%s.%s.%s",
@@ -210,25 +488,106 @@ func (m *Manager) generateWorkspace(cmd *Command, paths *Paths) string {
 		cmd.ModuleInstance,
 	)
 
+	// Namespace workspaces sharing a backend with other repositories
+	if m.config.WorkspacePrefix != "" {
+		workspace = m.config.WorkspacePrefix + "." + workspace
+	}
+
 	return workspace
 }
 
+// workspaceFileExt names the sidecar file next to an instance's tfvars that pins its workspace
+const workspaceFileExt = ".tfworkspace"
+
+// instanceWorkspace returns the workspace of cmd's instance: the command's workspace= override,
+// else the name in the instance's .tfworkspace sidecar, else the computed name
+func (m *Manager) instanceWorkspace(cmd *Command, paths *Paths) (string, error) {
+	if cmd.Workspace != "" {
+		return cmd.Workspace, nil
+	}
+
+	// Legacy instances keep pre-existing workspaces that do not follow the naming scheme
+	sidecar := filepath.Join(paths.ModuleEnvPath, cmd.ModuleInstance+workspaceFileExt)
+	data, err := os.ReadFile(sidecar)
+	if errors.Is(err, os.ErrNotExist) {
+		return m.generateWorkspace(cmd, paths), nil
+	}
+	if err != nil {
+		return "", NewExitCodeError(fmt.Sprintf("failed to read workspace file %s: %v", sidecar, err), ExitValidationFailed)
+	}
+	name := strings.TrimSpace(string(data))
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return "", NewExitCodeError(fmt.Sprintf("workspace file %s must contain a single workspace name", sidecar), ExitValidationFailed)
+	}
+	framework.Debug(fmt.Sprintf("Using workspace %s from %s", name, sidecar))
+	return name, nil
+}
+
+// registerMasks hides the command's identifiers from tf-manage logs.
+// The workspace name is masked through its components.
+func (m *Manager) registerMasks(cmd *Command) {
+	identifiers := map[string]string{
+		"product":  cmd.Product,
+		"repo":     m.config.RepoName,
+		"module":   cmd.Module,
+		"env":      cmd.Env,
+		"instance": cmd.ModuleInstance,
+	}
+	for kind, value := range identifiers {
+		framework.Mask(value, maskIdentifier(kind, value))
+	}
+
+	// Nested envs appear in workspace names with the separator instead of slashes
+	if sanitized := strings.ReplaceAll(cmd.Env, "/", m.config.EnvSeparator()); sanitized != cmd.Env {
+		framework.Mask(sanitized, maskIdentifier("env", cmd.Env))
+	}
+}
+
+// registerWorkspaceMask hides the full workspace name, which may be pinned or prefixed
+// and so not made up of the masked identifiers alone
+func (m *Manager) registerWorkspaceMask(workspaceName string) {
+	if m.config.MaskIdentifiers {
+		framework.Mask(workspaceName, maskIdentifier("workspace", workspaceName))
+	}
+}
+
+// maskIdentifier returns a stable, non-reversible stand-in for value
+func maskIdentifier(kind, value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return fmt.Sprintf("%s-%s", kind, hex.EncodeToString(sum[:])[:8])
+}
+
 func (m *Manager) detectExecMode() string {
-	unattended := framework.AddEmphasisRed("unattended")
-	interactive := framework.AddEmphasisGreen("operator")
+	if m.isUnattended() {
+		return framework.AddEmphasisRed("unattended")
+	}
 
+	// Default to interactive operator mode
+	return framework.AddEmphasisGreen("operator")
+}
+
+// execModeName returns the exec mode without color, for machine-readable output
+func (m *Manager) execModeName() string {
+	if m.isUnattended() {
+		return "unattended"
+	}
+	return "operator"
+}
+
+// IsUnattended reports whether tf-manage runs in a CI/CD system or with TF_EXEC_MODE_OVERRIDE set
+func (m *Manager) IsUnattended() bool {
+	return m.isUnattended()
+}
+
+// isUnattended reports whether tf-manage is running without an operator present
+func (m *Manager) isUnattended() bool {
 	// Allow explicit override
 	if os.Getenv("TF_EXEC_MODE_OVERRIDE") != "" {
-		return unattended
+		return true
 	}
 
 	// Check for CI/CD environment variables
-	if m.isRunningInCI() {
-		return unattended
-	}
-
-	// Default to interactive operator mode
-	return interactive
+	return m.isRunningInCI()
 }
 
 // isRunningInCI detects if we're running in any popular CI/CD system
@@ -301,9 +660,9 @@ func (m *Manager) isRunningInCI() bool {
 	return false
 }
 
-// getTerraformVersion returns the Terraform CLI version found on PATH.
+// detectTerraformVersion asks the Terraform CLI found on PATH for its version.
 // It first tries `terraform version -json` and falls back to parsing `terraform version` output.
-func getTerraformVersion() string {
+func detectTerraformVersion() string {
 	// Quiet flags: we only need the output string
 	flags := framework.DefaultCmdFlags()
 	flags.PrintMessage = false
@@ -344,8 +703,99 @@ func getTerraformVersion() string {
 	return "unknown"
 }
 
+// ensureWorkspace selects workspaceName for the following terraform commands, creating it
+// when it does not exist yet
 func (m *Manager) ensureWorkspace(workspaceName string) error {
-	// Execute terraform workspace list command directly
+	if m.config.WorkspaceSelectEnv {
+		return m.ensureWorkspaceEnv(workspaceName)
+	}
+
+	// The workspace was already resolved against an exported TF_WORKSPACE, which would
+	// override the selection, so blank it (terraform ignores it when empty)
+	if _, set := os.LookupEnv("TF_WORKSPACE"); set {
+		m.setEnv("TF_WORKSPACE", "")
+	}
+
+	result := m.selectWorkspace(workspaceName)
+	if !result.Success && workspaceNotFound(result) {
+		if err := m.createWorkspace(workspaceName); err != nil {
+			return err
+		}
+		result = m.selectWorkspace(workspaceName)
+	}
+	if !result.Success {
+		framework.Error("Could not select workspace!")
+		return NewExitCodeError(fmt.Sprintf("failed to select workspace %s", workspaceName), ExitWorkspaceFailed)
+	}
+
+	return nil
+}
+
+// ensureWorkspaceEnv creates workspaceName when it is not listed and selects it by passing
+// TF_WORKSPACE to the following terraform commands (workspace_select_env)
+func (m *Manager) ensureWorkspaceEnv(workspaceName string) error {
+	workspaceExists, err := m.workspaceExists(workspaceName)
+	if err != nil {
+		return err
+	}
+
+	if !workspaceExists {
+		if err := m.createWorkspace(workspaceName); err != nil {
+			return err
+		}
+	}
+
+	// Select workspace using environment variable (same as bash version)
+	m.setEnv("TF_WORKSPACE", workspaceName)
+	framework.Info(fmt.Sprintf("Selecting workspace %s", framework.AddEmphasisBlue(workspaceName)))
+
+	return nil
+}
+
+// selectWorkspace runs terraform workspace select, capturing its output to recognise a
+// missing workspace
+func (m *Manager) selectWorkspace(workspaceName string) *framework.CmdResult {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintOutcome = false
+	flags.DecorateOutput = true
+
+	return m.run(
+		fmt.Sprintf("terraform workspace select %s", workspaceName),
+		fmt.Sprintf("Selecting workspace %s", framework.AddEmphasisBlue(workspaceName)),
+		flags,
+	)
+}
+
+// workspaceNotFound reports whether a failed workspace select was refused because the
+// workspace does not exist
+func workspaceNotFound(result *framework.CmdResult) bool {
+	return strings.Contains(result.Error+result.Output, "doesn't exist")
+}
+
+// createWorkspace runs terraform workspace new, tolerating a workspace created concurrently
+func (m *Manager) createWorkspace(workspaceName string) error {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = true
+	flags.PrintStatus = true
+	flags.PrintOutcome = false
+	flags.DecorateOutput = true // Capture stderr to recognise a concurrent creation
+
+	result := m.run(
+		fmt.Sprintf("terraform workspace new %s", workspaceName),
+		fmt.Sprintf("Creating workspace %s", framework.AddEmphasisRed(workspaceName)),
+		flags,
+	)
+
+	if !result.Success && !m.createdConcurrently(workspaceName, result) {
+		framework.Error("Could not create workspace!")
+		return NewExitCodeError(fmt.Sprintf("failed to create workspace %s", workspaceName), ExitWorkspaceFailed)
+	}
+	return nil
+}
+
+// workspaceExists reports whether workspaceName is listed by terraform workspace list
+func (m *Manager) workspaceExists(workspaceName string) (bool, error) {
 	// Important: Use DecorateOutput = true to capture output (non-interactive mode)
 	flags := framework.DefaultCmdFlags()
 	flags.PrintOutput = false
@@ -353,16 +803,24 @@ func (m *Manager) ensureWorkspace(workspaceName string) error {
 	flags.PrintStatus = true
 	flags.PrintOutcome = false
 	flags.DecorateOutput = true // Force non-interactive mode to capture output
+	flags.Spinner = true        // Listing workspaces on a remote backend can take a while
 
-	result := framework.RunCmd(
+	result := m.run(
 		"terraform workspace list",
 		fmt.Sprintf("Checking workspace %s exists", framework.AddEmphasisBlue(workspaceName)),
 		flags,
 	)
+	if !result.Success {
+		return false, NewExitCodeError("failed to list workspaces", ExitWorkspaceFailed)
+	}
 
-	// Parse the workspace list output
-	workspaceExists := false
-	for _, line := range strings.Split(result.Output, "\n") {
+	return slices.Contains(parseWorkspaceList(result.Output), workspaceName), nil
+}
+
+// parseWorkspaceList returns the workspace names in `terraform workspace list` output
+func parseWorkspaceList(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
 		// Terraform workspace list format:
 		// '* default' (current workspace has asterisk)
 		// '  workspace1'
@@ -371,88 +829,76 @@ func (m *Manager) ensureWorkspace(workspaceName string) error {
 		if strings.HasPrefix(trimmedLine, "*") {
 			trimmedLine = strings.TrimSpace(strings.TrimPrefix(trimmedLine, "*"))
 		}
-
-		if trimmedLine == workspaceName {
-			workspaceExists = true
-			break
+		if trimmedLine != "" {
+			names = append(names, trimmedLine)
 		}
 	}
+	return names
+}
 
-	// If workspace doesn't exist, create it
-	if !workspaceExists {
-		// Create new workspace
-		flags = framework.DefaultCmdFlags()
-		flags.PrintMessage = true
-		flags.PrintStatus = true
-		flags.PrintOutcome = false
-
-		result = framework.RunCmd(
-			fmt.Sprintf("terraform workspace new %s", workspaceName),
-			fmt.Sprintf("Creating workspace %s", framework.AddEmphasisRed(workspaceName)),
-			flags,
-			"Could not create workspace!",
-		)
-
-		if !result.Success {
-			return fmt.Errorf("failed to create workspace %s", workspaceName)
-		}
+// createdConcurrently reports whether a failed workspace new lost a race with another run
+// (parallel CI jobs or batch mode) that created the same workspace
+func (m *Manager) createdConcurrently(workspaceName string, result *framework.CmdResult) bool {
+	if !strings.Contains(result.Error+result.Output, "already exists") {
+		return false
 	}
 
-	// Select workspace using environment variable (same as bash version)
-	os.Setenv("TF_WORKSPACE", workspaceName)
-	framework.Info(fmt.Sprintf("Selecting workspace %s", framework.AddEmphasisBlue(workspaceName)))
+	exists, err := m.workspaceExists(workspaceName)
+	if err != nil || !exists {
+		return false
+	}
+	framework.Info(fmt.Sprintf("Workspace %s was created concurrently, using it", framework.AddEmphasisBlue(workspaceName)))
+	return true
+}
 
-	return nil
+// actionHandler runs one action in the module directory with the workspace selected
+type actionHandler func(m *Manager, cmd *Command, paths *Paths, workspaceName string) error
+
+// actionHandlers implement the supportedActions
+var actionHandlers = map[string]actionHandler{
+	"init":       func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformInit(cmd, paths) },
+	"plan":       func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformPlan(cmd, paths) },
+	"apply":      func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformApply(cmd, paths) },
+	"apply_plan": func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformApplyPlan(cmd, paths) },
+	"deploy":     (*Manager).terraformDeploy,
+	"destroy":    (*Manager).terraformDestroy,
+	"output":     func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformOutput(cmd, paths) },
+	"get":        func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformGet(cmd, paths) },
+	"workspace":  func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformWorkspace(cmd, paths) },
+	"delete-workspace": func(m *Manager, cmd *Command, _ *Paths, workspaceName string) error {
+		return m.terraformDeleteWorkspace(cmd, workspaceName)
+	},
+	"providers": func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformProviders(cmd, paths) },
+	"import":    func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformImport(cmd, paths) },
+	"taint":     func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformTaint(cmd, paths) },
+	"untaint":   func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformUntaint(cmd, paths) },
+	"state":     func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformState(cmd, paths) },
+	"refresh":   func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformRefresh(cmd, paths) },
+	"validate":  func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformValidate(cmd, paths) },
+	"fmt":       func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformFormat(cmd, paths) },
+	"format":    func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformFormat(cmd, paths) },
+	"show":      func(m *Manager, cmd *Command, paths *Paths, _ string) error { return m.terraformShow(cmd, paths) },
+	"metadata":  func(m *Manager, cmd *Command, _ *Paths, _ string) error { return m.terraformMetadata(cmd) },
+	"raw":       func(m *Manager, cmd *Command, _ *Paths, _ string) error { return m.terraformRaw(cmd) },
 }
 
 func (m *Manager) executeTerraformAction(cmd *Command, paths *Paths, workspaceName string) error {
-	switch cmd.Action {
-	case "init":
-		return m.terraformInit(cmd, paths)
-	case "plan":
-		return m.terraformPlan(cmd, paths)
-	case "apply":
-		return m.terraformApply(cmd, paths)
-	case "apply_plan":
-		return m.terraformApplyPlan(cmd, paths)
-	case "destroy":
-		return m.terraformDestroy(cmd, paths)
-	case "output":
-		return m.terraformOutput(cmd, paths)
-	case "get":
-		return m.terraformGet(cmd, paths)
-	case "workspace":
-		return m.terraformWorkspace(cmd, paths)
-	case "providers":
-		return m.terraformProviders(cmd, paths)
-	case "import":
-		return m.terraformImport(cmd, paths)
-	case "taint":
-		return m.terraformTaint(cmd, paths)
-	case "untaint":
-		return m.terraformUntaint(cmd, paths)
-	case "state":
-		return m.terraformState(cmd, paths)
-	case "refresh":
-		return m.terraformRefresh(cmd, paths)
-	case "validate":
-		return m.terraformValidate(cmd, paths)
-	case "fmt", "format":
-		return m.terraformFormat(cmd, paths)
-	case "show":
-		return m.terraformShow(cmd, paths)
-	default:
+	handler, ok := actionHandlers[cmd.Action]
+	if !ok {
 		return fmt.Errorf("unsupported terraform action: %s", cmd.Action)
 	}
+	return handler(m, cmd, paths, workspaceName)
 }
 
 func (m *Manager) terraformInit(cmd *Command, paths *Paths) error {
-	terraformCmd := "terraform init"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	terraformCmd, err := m.initCommand(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return NewExitCodeError(err.Error(), ExitValidationFailed)
 	}
+	m.preparePluginCache()
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Initializing terraform",
 		framework.DefaultCmdFlags(),
@@ -462,36 +908,230 @@ func (m *Manager) terraformInit(cmd *Command, paths *Paths) error {
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
+// initCommand builds the terraform init command, expanding the --migrate-state and
+// --reconfigure shorthands used when a backend changes, --upgrade and --backend=false
+func (m *Manager) initCommand(cmd *Command) (string, error) {
+	migrateState := takeBoolFlag(cmd, "migrate-state")
+	reconfigure := takeBoolFlag(cmd, "reconfigure")
+	if migrateState && reconfigure {
+		return "", fmt.Errorf("--migrate-state and --reconfigure cannot be used together: migrate the existing state or discard it, not both")
+	}
+	upgrade := takeBoolFlag(cmd, "upgrade")
+	backend, hasBackend := takeValueFlag(cmd, "backend")
+	if !hasBackend && hasActionFlag(cmd, "--backend") {
+		return "", fmt.Errorf("--backend requires a value (--backend=false)")
+	}
+	if hasBackend && backend != "true" && backend != "false" {
+		return "", fmt.Errorf("invalid --backend value %q (expected true or false)", backend)
+	}
+
+	// Without workspaces each instance has its own backend key, which replaces the
+	// module's cached backend settings unless the state is being migrated
+	var backendKey string
+	if !m.config.WorkspacesEnabled() && backend != "false" {
+		key, err := m.backendKey(cmd)
+		if err != nil {
+			return "", err
+		}
+		backendKey = key
+		reconfigure = reconfigure || !migrateState
+	}
+
+	terraformCmd := "terraform init"
+	switch {
+	case migrateState:
+		terraformCmd += " -migrate-state"
+	case reconfigure:
+		terraformCmd += " -reconfigure"
+	}
+	if backendKey != "" {
+		terraformCmd += " " + quoteArg("-backend-config=key="+backendKey)
+	}
+	if upgrade {
+		terraformCmd += " -upgrade"
+	}
+	if backend == "false" {
+		terraformCmd += " -backend=false"
+	}
+	terraformCmd += m.actionFlags(cmd)
+	return terraformCmd, nil
+}
+
 func (m *Manager) terraformPlan(cmd *Command, paths *Paths) error {
-	extraVars := m.generateTfmExtraVars(cmd)
-	terraformCmd := fmt.Sprintf("terraform plan -var-file=\"%s\" -out=\"%s\" %s", paths.VarFile, paths.PlanFile, extraVars)
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	planTextPath, wantPlanText := takeValueFlag(cmd, "plan-out-text")
+	approvePlan := takeBoolFlag(cmd, "approve-plan")
+
+	parallelism, err := parallelismFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	replace, err := replaceFlags(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	noRefresh, err := noRefreshFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(paths.PlanFile), 0755); err != nil {
+		framework.Error(fmt.Sprintf("Could not create plan directory %s", framework.AddEmphasisBlue(filepath.Dir(paths.PlanFile))))
+		return fmt.Errorf("failed to create plan directory: %w", err)
+	}
+
+	// With review_plans, the plan only replaces the canonical plan once it is approved
+	planFile := paths.PlanFile
+	if m.config.ReviewPlans {
+		planFile = pendingPlanFile(paths.PlanFile)
 	}
 
-	result := framework.RunCmd(
+	terraformCmd := fmt.Sprintf("terraform plan %s%s%s%s%s -out=\"%s\"", m.generateVarFlags(cmd, paths), m.lockTimeoutFlag(), parallelism, replace, noRefresh, planFile)
+	terraformCmd += m.actionFlags(cmd)
+
+	result := m.run(
 		terraformCmd,
 		"Planning terraform changes",
 		framework.DefaultCmdFlags(),
 		"Terraform plan failed",
 	)
+	if result.Success {
+		m.printPlanSummary(planFile)
+	}
+
+	var promoteErr error
+	if m.config.ReviewPlans {
+		if result.Success {
+			promoteErr = m.promotePlan(cmd, planFile, paths.PlanFile, approvePlan)
+		} else {
+			os.Remove(planFile)
+		}
+	}
+
+	if wantPlanText {
+		planTextPath = m.resolveArtifactPath(cmd, planTextPath)
+		if !result.Success || promoteErr != nil {
+			// Never leave a stale rendering of an older plan behind
+			os.Remove(planTextPath)
+		} else if err := m.writePlanText(paths.PlanFile, planTextPath); err != nil {
+			return err
+		}
+	}
 
+	if promoteErr != nil {
+		return promoteErr
+	}
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
+// resolveUserPath makes a path given on the command line relative to the invocation directory
+func (m *Manager) resolveUserPath(path string) string {
+	if filepath.IsAbs(path) || m.invocationDir == "" {
+		return path
+	}
+	return filepath.Join(m.invocationDir, path)
+}
+
+// artifactDir returns the instance's artifact directory, or "" when no artifact directory is set
+func (m *Manager) artifactDir(cmd *Command) string {
+	base := m.config.GetArtifactDir()
+	if m.options.OutDir != "" {
+		base = m.options.OutDir
+	}
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(base, cmd.Product, cmd.Env, cmd.Module, cmd.ModuleInstance)
+}
+
+// resolveArtifactPath places a relative artifact path given on the command line in the
+// instance's artifact directory when one is set, creating it. Otherwise the path is
+// relative to the invocation directory.
+func (m *Manager) resolveArtifactPath(cmd *Command, path string) string {
+	artifactDir := m.artifactDir(cmd)
+	if filepath.IsAbs(path) || artifactDir == "" {
+		return m.resolveUserPath(path)
+	}
+	path = filepath.Join(artifactDir, path)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	return path
+}
+
+// writePlanText renders planFile with `terraform show -no-color` into outPath.
+// The file is written atomically so a failure never leaves a partial rendering.
+func (m *Manager) writePlanText(planFile, outPath string) error {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintMessage = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run(
+		fmt.Sprintf("terraform show -no-color \"%s\"", planFile),
+		fmt.Sprintf("Writing plan text to %s", framework.AddEmphasisBlue(outPath)),
+		flags,
+		"Could not render plan as text",
+	)
+	if !result.Success {
+		os.Remove(outPath)
+		return fmt.Errorf("failed to render plan text")
+	}
+
+	if err := writeFileAtomic(outPath, []byte(result.Output)); err != nil {
+		return fmt.Errorf("failed to write plan text %s: %w", outPath, err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 func (m *Manager) terraformApply(cmd *Command, paths *Paths) error {
+	outputsPath, wantOutputs := takeValueFlag(cmd, "outputs-file")
+
+	parallelism, err := parallelismFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	replace, err := replaceFlags(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+	noRefresh, err := noRefreshFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+
 	// Apply directly with var file (not using plan file)
-	extraVars := m.generateTfmExtraVars(cmd)
-	terraformCmd := fmt.Sprintf("terraform apply -var-file=\"%s\" %s", paths.VarFile, extraVars)
+	terraformCmd := fmt.Sprintf("terraform apply %s%s%s%s%s", m.generateVarFlags(cmd, paths), m.lockTimeoutFlag(), parallelism, replace, noRefresh)
 
 	// Add extra arguments in case we're running in "unattended" mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		terraformCmd += " -input=false -auto-approve"
 	}
 
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
 	// Notify user about the action
 	framework.Info("Executing terraform apply")
@@ -500,11 +1140,11 @@ func (m *Manager) terraformApply(cmd *Command, paths *Paths) error {
 	var result *framework.CmdResult
 
 	// Use interactive runner for operator mode, regular runner for unattended mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		flags := framework.DefaultCmdFlags()
 		flags.PrintMessage = false
 
-		result = framework.RunCmd(
+		result = m.run(
 			terraformCmd,
 			"Applying terraform changes",
 			flags,
@@ -512,28 +1152,57 @@ func (m *Manager) terraformApply(cmd *Command, paths *Paths) error {
 		)
 	} else {
 		// Interactive mode - use special interactive runner
-		result = framework.RunCmdInteractive(
+		result = m.runInteractive(
 			terraformCmd,
 			"Applying terraform changes",
 			"Terraform apply failed",
 		)
 	}
 
+	if wantOutputs && result.Success {
+		if err := m.writeOutputsFile(m.resolveArtifactPath(cmd, outputsPath)); err != nil {
+			return err
+		}
+	}
+
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
 func (m *Manager) terraformApplyPlan(cmd *Command, paths *Paths) error {
+	outputsPath, wantOutputs := takeValueFlag(cmd, "outputs-file")
+
+	// Apply a plan restored from elsewhere (e.g. a CI artifact) instead of the conventional one
+	if planFile, ok := takeValueFlag(cmd, "plan-file"); ok {
+		planFile = m.resolveUserPath(planFile)
+		if info, err := os.Stat(planFile); err != nil || info.IsDir() {
+			framework.Error(fmt.Sprintf("Plan file %s does not exist", framework.AddEmphasisRed(planFile)))
+			return NewExitCodeError(fmt.Sprintf("plan file does not exist: %s", planFile), ExitValidationFailed)
+		}
+		paths.PlanFile = planFile
+	}
+
+	// Refuse plans made before the latest change to the module or its variables
+	if takeBoolFlag(cmd, "require-fresh-plan") || m.config.RequireFreshPlan {
+		if err := checkPlanFresh(paths); err != nil {
+			return err
+		}
+	}
+
+	parallelism, err := parallelismFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+
 	// Apply using the plan file
-	terraformCmd := fmt.Sprintf("terraform apply \"%s\"", paths.PlanFile)
+	terraformCmd := fmt.Sprintf("terraform apply%s%s \"%s\"", m.lockTimeoutFlag(), parallelism, paths.PlanFile)
 
 	// Add extra arguments in case we're running in "unattended" mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		terraformCmd += " -input=false"
 	}
 
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
 	flags := framework.DefaultCmdFlags()
 	flags.PrintMessage = false
@@ -542,41 +1211,59 @@ func (m *Manager) terraformApplyPlan(cmd *Command, paths *Paths) error {
 	framework.Info("Executing terraform apply")
 	framework.Info("This will affect infrastructure resources.")
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Applying terraform changes",
 		flags,
 		"Terraform apply failed",
 	)
 
+	if wantOutputs && result.Success {
+		if err := m.writeOutputsFile(m.resolveArtifactPath(cmd, outputsPath)); err != nil {
+			return err
+		}
+	}
+
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
-func (m *Manager) terraformDestroy(cmd *Command, paths *Paths) error {
-	extraVars := m.generateTfmExtraVars(cmd)
-	terraformCmd := fmt.Sprintf("terraform destroy -var-file=\"%s\" %s", paths.VarFile, extraVars)
+func (m *Manager) terraformDestroy(cmd *Command, paths *Paths, workspaceName string) error {
+	parallelism, err := parallelismFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
+	}
+
+	terraformCmd := fmt.Sprintf("terraform destroy %s%s%s", m.generateVarFlags(cmd, paths), m.lockTimeoutFlag(), parallelism)
 
 	// Add extra arguments in case we're running in "unattended" mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		terraformCmd += " -auto-approve"
 	}
 
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
 	// Notify user about the action
 	framework.Info("Executing terraform destroy")
 	framework.Info("This will DESTROY infrastructure resources.")
 
+	// Operators must confirm the exact target before terraform's own prompt
+	if !m.isUnattended() {
+		m.printTargetSummary(cmd, workspaceName)
+		if !m.confirmByTyping(cmd.ModuleInstance) {
+			framework.Error("Confirmation did not match, aborting destroy")
+			return fmt.Errorf("destroy aborted by operator")
+		}
+	}
+
 	var result *framework.CmdResult
 
 	// Use interactive runner for operator mode, regular runner for unattended mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		flags := framework.DefaultCmdFlags()
 		flags.PrintMessage = false
 
-		result = framework.RunCmd(
+		result = m.run(
 			terraformCmd,
 			"Destroying terraform resources",
 			flags,
@@ -584,7 +1271,7 @@ func (m *Manager) terraformDestroy(cmd *Command, paths *Paths) error {
 		)
 	} else {
 		// Interactive mode - use special interactive runner
-		result = framework.RunCmdInteractive(
+		result = m.runInteractive(
 			terraformCmd,
 			"Destroying terraform resources",
 			"Terraform destroy failed",
@@ -595,12 +1282,24 @@ func (m *Manager) terraformDestroy(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformOutput(cmd *Command, paths *Paths) error {
-	terraformCmd := "terraform output"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	// Print a flattened key=value list instead of terraform's own rendering
+	if takeBoolFlag(cmd, "json-values") {
+		showSensitive := takeBoolFlag(cmd, "show-sensitive")
+		outputs, err := m.readOutputs()
+		if err != nil {
+			framework.Error("Terraform output failed")
+			return err
+		}
+		for _, line := range flattenOutputs(outputs, showSensitive) {
+			fmt.Fprintln(framework.Stdout(), line)
+		}
+		return nil
 	}
 
-	result := framework.RunCmd(
+	terraformCmd := "terraform output"
+	terraformCmd += m.actionFlags(cmd)
+
+	result := m.run(
 		terraformCmd,
 		"Getting terraform outputs",
 		framework.DefaultCmdFlags(),
@@ -611,17 +1310,14 @@ func (m *Manager) terraformOutput(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformImport(cmd *Command, paths *Paths) error {
-	extraVars := m.generateTfmExtraVars(cmd)
-	terraformCmd := fmt.Sprintf("terraform import -var-file=\"%s\" %s", paths.VarFile, extraVars)
+	terraformCmd := fmt.Sprintf("terraform import %s%s", m.generateVarFlags(cmd, paths), m.lockTimeoutFlag())
 
-	// Add extra arguments in case we're running in "unattended" mode
-	if m.detectExecMode() == "unattended" {
-		terraformCmd += " -input=false -auto-approve"
+	// Add extra arguments in case we're running in "unattended" mode (import has no -auto-approve)
+	if m.isUnattended() {
+		terraformCmd += " -input=false"
 	}
 
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
 	// Notify user about the action
 	framework.Info("Executing terraform import")
@@ -630,11 +1326,11 @@ func (m *Manager) terraformImport(cmd *Command, paths *Paths) error {
 	var result *framework.CmdResult
 
 	// Use interactive runner for operator mode, regular runner for unattended mode
-	if m.detectExecMode() == "unattended" {
+	if m.isUnattended() {
 		flags := framework.DefaultCmdFlags()
 		flags.PrintMessage = false
 
-		result = framework.RunCmd(
+		result = m.run(
 			terraformCmd,
 			"Importing terraform resource",
 			flags,
@@ -642,7 +1338,7 @@ func (m *Manager) terraformImport(cmd *Command, paths *Paths) error {
 		)
 	} else {
 		// Interactive mode - use special interactive runner
-		result = framework.RunCmdInteractive(
+		result = m.runInteractive(
 			terraformCmd,
 			"Importing terraform resource",
 			"Terraform import failed",
@@ -653,12 +1349,12 @@ func (m *Manager) terraformImport(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformTaint(cmd *Command, paths *Paths) error {
+	framework.Info(fmt.Sprintf("terraform taint is deprecated; prefer %s", framework.AddEmphasisBlue("'plan --replace ADDRESS'")))
+
 	terraformCmd := "terraform taint"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Tainting terraform resource",
 		framework.DefaultCmdFlags(),
@@ -670,11 +1366,9 @@ func (m *Manager) terraformTaint(cmd *Command, paths *Paths) error {
 
 func (m *Manager) terraformUntaint(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform untaint"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Untainting terraform resource",
 		framework.DefaultCmdFlags(),
@@ -686,11 +1380,9 @@ func (m *Manager) terraformUntaint(cmd *Command, paths *Paths) error {
 
 func (m *Manager) terraformState(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform state"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Managing terraform state",
 		framework.DefaultCmdFlags(),
@@ -701,13 +1393,16 @@ func (m *Manager) terraformState(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformRefresh(cmd *Command, paths *Paths) error {
-	extraVars := m.generateTfmExtraVars(cmd)
-	terraformCmd := fmt.Sprintf("terraform refresh -var-file=\"%s\" %s", paths.VarFile, extraVars)
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	parallelism, err := parallelismFlag(cmd)
+	if err != nil {
+		framework.Error(err.Error())
+		return err
 	}
 
-	result := framework.RunCmd(
+	terraformCmd := fmt.Sprintf("terraform refresh %s%s%s", m.generateVarFlags(cmd, paths), m.lockTimeoutFlag(), parallelism)
+	terraformCmd += m.actionFlags(cmd)
+
+	result := m.run(
 		terraformCmd,
 		"Refreshing terraform state",
 		framework.DefaultCmdFlags(),
@@ -719,11 +1414,9 @@ func (m *Manager) terraformRefresh(cmd *Command, paths *Paths) error {
 
 func (m *Manager) terraformValidate(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform validate"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Validating terraform configuration",
 		framework.DefaultCmdFlags(),
@@ -735,11 +1428,9 @@ func (m *Manager) terraformValidate(cmd *Command, paths *Paths) error {
 
 func (m *Manager) terraformFormat(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform fmt"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Formatting terraform files",
 		framework.DefaultCmdFlags(),
@@ -750,38 +1441,89 @@ func (m *Manager) terraformFormat(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformShow(cmd *Command, paths *Paths) error {
-	var terraformCmd string
+	terraformCmd := showCommand(paths, takeBoolFlag(cmd, "json"))
+
+	terraformCmd += m.actionFlags(cmd)
+
+	result := m.run(
+		terraformCmd,
+		"Showing terraform state/plan",
+		framework.DefaultCmdFlags(),
+		"Terraform show failed",
+	)
+
+	return NewExitCodeError("command failed", result.ExitCode)
+}
+
+// showCommand builds the terraform show command, preferring the plan file over state
+func showCommand(paths *Paths, asJSON bool) string {
+	terraformCmd := "terraform show"
+	if asJSON {
+		terraformCmd += " -json"
+	}
 
 	// Check if plan file exists
 	if _, err := os.Stat(paths.PlanFile); err == nil {
 		// Show plan file
-		terraformCmd = fmt.Sprintf("terraform show \"%s\"", paths.PlanFile)
-	} else {
-		// Show current state
-		terraformCmd = "terraform show"
+		terraformCmd += fmt.Sprintf(" \"%s\"", paths.PlanFile)
 	}
 
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	// Otherwise show current state
+	return terraformCmd
+}
+
+// defaultMetadataQuery is run by the metadata action when no subcommand is given
+const defaultMetadataQuery = "functions -json"
+
+// terraformMetadata forwards to `terraform metadata <subcommand>` for editor integrations.
+// The action flags are the subcommand and its arguments, so configured flags are not added.
+func (m *Manager) terraformMetadata(cmd *Command) error {
+	query := cmd.ActionFlags
+	if query == "" {
+		query = defaultMetadataQuery
 	}
 
-	result := framework.RunCmd(
-		terraformCmd,
-		"Showing terraform state/plan",
-		framework.DefaultCmdFlags(),
-		"Terraform show failed",
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintStatus = false
+
+	result := m.run(
+		"terraform metadata "+query,
+		"Querying terraform metadata",
+		flags,
+		"Terraform metadata failed",
 	)
 
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
+// terraformRaw forwards the action flags verbatim as `terraform <flags>` for subcommands
+// tf-manage does not wrap. Nothing is validated or added beyond the selected workspace.
+func (m *Manager) terraformRaw(cmd *Command) error {
+	if strings.TrimSpace(cmd.ActionFlags) == "" {
+		framework.Error("raw needs the terraform subcommand to run, e.g. 'raw state pull'")
+		return NewExitCodeError("raw requires a terraform subcommand", ExitValidationFailed)
+	}
+
+	terraformCmd := "terraform " + cmd.ActionFlags
+	framework.Info(fmt.Sprintf("Running %s unvalidated; tf-manage does not check or adjust raw commands", framework.AddEmphasisRed(terraformCmd)))
+
+	var result *framework.CmdResult
+	if m.isUnattended() {
+		result = m.run(terraformCmd, "Running raw terraform command", framework.DefaultCmdFlags(), "Terraform command failed")
+	} else {
+		// Raw subcommands may prompt (e.g. console, state rm), so keep the operator's terminal
+		result = m.runInteractive(terraformCmd, "Running raw terraform command", "Terraform command failed")
+	}
+
+	return NewExitCodeError("command failed", result.ExitCode)
+}
+
 func (m *Manager) terraformGet(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform get"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Getting terraform modules",
 		framework.DefaultCmdFlags(),
@@ -793,11 +1535,9 @@ func (m *Manager) terraformGet(cmd *Command, paths *Paths) error {
 
 func (m *Manager) terraformWorkspace(cmd *Command, paths *Paths) error {
 	terraformCmd := "terraform workspace"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
-	}
+	terraformCmd += m.actionFlags(cmd)
 
-	result := framework.RunCmd(
+	result := m.run(
 		terraformCmd,
 		"Managing terraform workspace",
 		framework.DefaultCmdFlags(),
@@ -808,12 +1548,18 @@ func (m *Manager) terraformWorkspace(cmd *Command, paths *Paths) error {
 }
 
 func (m *Manager) terraformProviders(cmd *Command, paths *Paths) error {
-	terraformCmd := "terraform providers"
-	if cmd.ActionFlags != "" {
-		terraformCmd += " " + cmd.ActionFlags
+	// Record checksums for every platform the team runs on, not just this machine
+	if fields := strings.Fields(cmd.ActionFlags); len(fields) > 0 && fields[0] == "lock" &&
+		!hasActionFlag(cmd, "-platform") {
+		for _, platform := range m.config.LockPlatforms() {
+			cmd.ActionFlags += " -platform=" + platform
+		}
 	}
 
-	result := framework.RunCmd(
+	terraformCmd := "terraform providers"
+	terraformCmd += m.actionFlags(cmd)
+
+	result := m.run(
 		terraformCmd,
 		"Managing terraform providers",
 		framework.DefaultCmdFlags(),
@@ -823,14 +1569,98 @@ func (m *Manager) terraformProviders(cmd *Command, paths *Paths) error {
 	return NewExitCodeError("command failed", result.ExitCode)
 }
 
+// actionFlags returns the configured terraform flags for the action followed by the
+// operator's own action flags, so what is typed on the command line comes last
+func (m *Manager) actionFlags(cmd *Command) string {
+	flags := m.config.TerraformFlagsFor(cmd.Action)
+	if m.injectNoColor(cmd, flags) {
+		flags = append(flags, "-no-color")
+	}
+	if cmd.ActionFlags != "" {
+		flags = append(flags, cmd.ActionFlags)
+	}
+	if len(flags) == 0 {
+		return ""
+	}
+	return " " + strings.Join(flags, " ")
+}
+
+// noColorActions are the actions whose terraform command accepts -no-color
+var noColorActions = map[string]bool{
+	"init":       true,
+	"plan":       true,
+	"apply":      true,
+	"apply_plan": true,
+	"destroy":    true,
+	"output":     true,
+	"import":     true,
+	"taint":      true,
+	"untaint":    true,
+	"refresh":    true,
+	"validate":   true,
+	"show":       true,
+	"get":        true,
+}
+
+// injectNoColor reports whether -no-color should be added so terraform's ANSI codes stay out
+// of CI logs. It is skipped when terraform color was requested or -no-color is already set.
+func (m *Manager) injectNoColor(cmd *Command, configured []string) bool {
+	if m.options.TerraformColor || !m.isUnattended() || !noColorActions[cmd.Action] {
+		return false
+	}
+	for _, flag := range configured {
+		if flag == "-no-color" {
+			return false
+		}
+	}
+	return !hasActionFlag(cmd, "-no-color")
+}
+
+// lockTimeoutFlag returns the -lock-timeout argument for the configured lock_timeout, if any
+func (m *Manager) lockTimeoutFlag() string {
+	if m.config.LockTimeout == "" {
+		return ""
+	}
+	return " -lock-timeout=" + m.config.LockTimeout
+}
+
+// generateVarFlags creates the variable flags shared by all var-file aware actions.
+// User overrides come last so they take precedence over the instance tfvars file.
+func (m *Manager) generateVarFlags(cmd *Command, paths *Paths) string {
+	parts := []string{
+		fmt.Sprintf("-var-file=\"%s\"", paths.VarFile),
+	}
+	if extra := m.generateTfmExtraVars(cmd, paths); extra != "" {
+		parts = append(parts, extra)
+	}
+	for _, v := range cmd.Vars {
+		parts = append(parts, "-var "+quoteArg(v))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteArg wraps value in quotes the command parser understands, preferring single quotes
+func quoteArg(value string) string {
+	switch {
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'"
+	case !strings.Contains(value, `"`):
+		return `"` + value + `"`
+	}
+	// The parser has no escapes but joins adjacent quoted parts, so each ' closes the
+	// single quotes, is wrapped in double quotes, and reopens them
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
 // generateTfmExtraVars creates the terraform variable flags for tf-manage integration
-// This matches the bash version's _TFM_EXTRA_VARS functionality
-func (m *Manager) generateTfmExtraVars(cmd *Command) string {
-	return fmt.Sprintf("-var 'tfm_product=%s' -var 'tfm_repo=%s' -var 'tfm_module=%s' -var 'tfm_env=%s' -var 'tfm_module_instance=%s'",
-		cmd.Product,
-		m.config.RepoName,
-		cmd.Module,
-		cmd.Env,
-		cmd.ModuleInstance,
-	)
+// This matches the bash version's _TFM_EXTRA_VARS functionality. Which variables are
+// passed depends on inject_tfm_vars (see tfmVarNames).
+func (m *Manager) generateTfmExtraVars(cmd *Command, paths *Paths) string {
+	values := m.tfmVarValues(cmd)
+
+	var flags []string
+	for _, name := range m.tfmVarsToInject(paths.ModulePath) {
+		flags = append(flags, fmt.Sprintf("-var '%s=%s'", name, values[name]))
+	}
+	return strings.Join(flags, " ")
 }
@@ -1,8 +1,19 @@
 package terraform
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sorinlg/tf-manage2/internal/config"
 	"github.com/sorinlg/tf-manage2/internal/framework"
@@ -222,3 +233,3357 @@ func clearCIEnvVars() {
 		os.Unsetenv(envVar)
 	}
 }
+
+func TestConfirmByTyping(t *testing.T) {
+	cfg := &config.Config{
+		RepoName: "test-repo",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected bool
+	}{
+		{name: "Exact match", input: "instance_x\n", expected: true},
+		{name: "Surrounding whitespace", input: "  instance_x  \n", expected: true},
+		{name: "No trailing newline", input: "instance_x", expected: true},
+		{name: "Plain yes is rejected", input: "yes\n", expected: false},
+		{name: "Different instance", input: "instance_y\n", expected: false},
+		{name: "Empty input", input: "", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(cfg)
+			manager.stdin = strings.NewReader(tt.input)
+
+			if got := manager.confirmByTyping("instance_x"); got != tt.expected {
+				t.Errorf("confirmByTyping() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateVarFlags(t *testing.T) {
+	cfg := &config.Config{
+		RepoName: "test-repo",
+	}
+	manager := NewManager(cfg)
+
+	cmd := &Command{
+		Product:        "product1",
+		Module:         "sample_module",
+		Env:            "dev",
+		ModuleInstance: "instance_x",
+		Vars:           []string{"count=3", "name=first", "name=second", "msg=it's here", `note=it's "quoted"`},
+	}
+	paths := manager.computePaths(cmd)
+
+	flags := manager.generateVarFlags(cmd, paths)
+
+	// The var file must come before any override so the overrides win
+	order := []string{
+		"-var-file=\"" + paths.VarFile + "\"",
+		"-var 'tfm_module_instance=instance_x'",
+		"-var 'count=3'",
+		"-var 'name=first'",
+		"-var 'name=second'",
+		"-var \"msg=it's here\"",
+		`-var 'note=it'"'"'s "quoted"'`,
+	}
+	last := -1
+	for _, exp := range order {
+		idx := strings.Index(flags, exp)
+		if idx < 0 {
+			t.Fatalf("Expected %q in flags: %s", exp, flags)
+		}
+		if idx <= last {
+			t.Errorf("Expected %q to appear after previous flags: %s", exp, flags)
+		}
+		last = idx
+	}
+}
+
+func TestInjectTfmVars(t *testing.T) {
+	allDecls := `variable "tfm_product" {}
+variable "tfm_repo" {
+  type = string
+}
+variable "tfm_module" {}
+variable tfm_env {}
+  variable "tfm_module_instance" {}
+`
+	tests := []struct {
+		name    string
+		sources string
+		mode    string
+		want    []string
+		warn    bool
+	}{
+		{"All declared, always", allDecls, "", tfmVarNames, false},
+		{"All declared, auto", allDecls, config.InjectTfmVarsAuto, tfmVarNames, false},
+		{"Some declared, always", `variable "tfm_env" {}` + "\n" + `variable "tfm_product" {}`, config.InjectTfmVarsAlways, tfmVarNames, true},
+		{"Some declared, auto", `variable "tfm_env" {}` + "\n" + `variable "tfm_product" {}`, config.InjectTfmVarsAuto, []string{"tfm_product", "tfm_env"}, false},
+		{"None declared, auto", `# variable "tfm_env" {}` + "\n" + `resource "null_resource" "x" {}`, config.InjectTfmVarsAuto, nil, false},
+		{"None declared, always", `resource "null_resource" "x" {}`, config.InjectTfmVarsAlways, tfmVarNames, true},
+		{"All declared, never", allDecls, config.InjectTfmVarsNever, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			manager.config.InjectTfmVars = tt.mode
+			paths := manager.computePaths(cmd)
+			if err := os.WriteFile(filepath.Join(paths.ModulePath, "variables.tf"), []byte(tt.sources), 0644); err != nil {
+				t.Fatalf("Failed to write module sources: %v", err)
+			}
+
+			var flags string
+			output := captureStderr(t, func() { flags = manager.generateVarFlags(cmd, paths) })
+			for _, name := range tfmVarNames {
+				passed := strings.Contains(flags, "-var '"+name+"=")
+				if passed != slices.Contains(tt.want, name) {
+					t.Errorf("%s passed = %v in %q", name, passed, flags)
+				}
+			}
+			if warned := strings.Contains(output, "does not declare"); warned != tt.warn {
+				t.Errorf("Warning printed = %v, want %v:\n%s", warned, tt.warn, output)
+			}
+		})
+	}
+}
+
+func TestTfmVarEnvConflict(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		mode        string
+		errorOnWarn bool
+		warn        bool
+		wantCode    int
+	}{
+		{"No exported variables", nil, "", false, false, 0},
+		{"Conflicting value warns", map[string]string{"TF_VAR_tfm_product": "other"}, "", false, true, 0},
+		{"Matching value is fine", map[string]string{"TF_VAR_tfm_product": "product1"}, "", false, false, 0},
+		{"Conflict fails with ErrorOnWarn", map[string]string{"TF_VAR_tfm_env": "prod"}, "", true, true, ExitValidationFailed},
+		{"Variables not injected are ignored", map[string]string{"TF_VAR_tfm_product": "other"}, config.InjectTfmVarsNever, true, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			manager.config.InjectTfmVars = tt.mode
+			manager.SetOptions(Options{ErrorOnWarn: tt.errorOnWarn})
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var err error
+			output := captureStderr(t, func() { err = manager.checkTfmVarEnv(cmd, manager.computePaths(cmd)) })
+			if warned := strings.Contains(output, "take precedence"); warned != tt.warn {
+				t.Errorf("Warning printed = %v, want %v:\n%s", warned, tt.warn, output)
+			}
+			if tt.warn {
+				for key, value := range tt.env {
+					if !strings.Contains(output, key+"="+value) {
+						t.Errorf("Expected the warning to name %s=%s:\n%s", key, value, output)
+					}
+				}
+			}
+			if tt.wantCode != 0 {
+				if code := exitCodeOf(t, err); code != tt.wantCode {
+					t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Errorf("checkTfmVarEnv() error = %v", err)
+			}
+		})
+	}
+
+	t.Run("Plan warns before running terraform", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+		t.Setenv("TF_VAR_tfm_module_instance", "instance_y")
+		fakeTerraformInstalled(t)
+		manager, cmd := setupInstance(t)
+		fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = "plan"
+
+		output := captureStderr(t, func() { manager.Execute(cmd) })
+		if !strings.Contains(output, "TF_VAR_tfm_module_instance=instance_y (tf-manage passes instance_x)") {
+			t.Errorf("Expected a conflict warning, got:\n%s", output)
+		}
+	})
+}
+
+func TestDisableTfmVars(t *testing.T) {
+	for _, action := range []string{"plan", "apply"} {
+		t.Run(action, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+			manager.config.InjectTfmVars = "false"
+			cmd.Vars = []string{"tfm_env_label=dev"}
+			paths := manager.computePaths(cmd)
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+
+			cmd.Action = action
+			if action == "plan" {
+				manager.terraformPlan(cmd, paths)
+			} else {
+				manager.terraformApply(cmd, paths)
+			}
+			if len(withoutPlanSummary(*commands)) != 1 {
+				t.Fatalf("Expected one terraform command, got %v", *commands)
+			}
+			got := strings.ReplaceAll((*commands)[0], "-var 'tfm_env_label=dev'", "")
+			if strings.Contains(got, "tfm_") || !strings.Contains(got, "-var-file=") {
+				t.Errorf("Expected the var file and no tfm_* vars, got %q", (*commands)[0])
+			}
+		})
+	}
+}
+
+func TestMaskIdentifiers(t *testing.T) {
+	cfg := &config.Config{
+		RepoName:        "secret-repo",
+		MaskIdentifiers: true,
+	}
+	manager := NewManager(cfg)
+	defer framework.ClearMasks()
+
+	cmd := &Command{
+		Product:        "payments",
+		Module:         "ledger_db",
+		Env:            "prod",
+		ModuleInstance: "primary",
+	}
+	paths := manager.computePaths(cmd)
+	workspace := manager.generateWorkspace(cmd, paths)
+
+	manager.registerMasks(cmd)
+
+	// Terraform must still receive the real values
+	flags := manager.generateVarFlags(cmd, paths)
+	for _, real := range []string{"tfm_product=payments", "tfm_repo=secret-repo", "tfm_module=ledger_db", "tfm_env=prod", "tfm_module_instance=primary"} {
+		if !strings.Contains(flags, real) {
+			t.Errorf("Expected real value %q in terraform flags: %s", real, flags)
+		}
+	}
+
+	// Logs must only see the masked values
+	logged := captureStderr(t, func() {
+		framework.Info("Selecting workspace " + workspace)
+		framework.Info("Running from " + paths.ModulePath)
+	})
+	for _, real := range []string{"payments", "secret-repo", "ledger_db", "prod", "primary"} {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(real) + `\b`).MatchString(logged) {
+			t.Errorf("Real identifier %q leaked into logs: %s", real, logged)
+		}
+	}
+	if !strings.Contains(logged, maskIdentifier("product", "payments")) {
+		t.Errorf("Expected masked product in logs: %s", logged)
+	}
+
+	// Masks are stable across invocations
+	if maskIdentifier("env", "prod") != maskIdentifier("env", "prod") {
+		t.Error("Expected maskIdentifier to be stable")
+	}
+
+	t.Run("Nested env in workspace name", func(t *testing.T) {
+		framework.ClearMasks()
+		nested := *cmd
+		nested.Env = "prod/us-east-1"
+		workspace := manager.generateWorkspace(&nested, paths)
+		manager.registerMasks(&nested)
+
+		logged := captureStderr(t, func() { framework.Info("Selecting workspace " + workspace) })
+		if strings.Contains(logged, "prod__us-east-1") {
+			t.Errorf("Nested env leaked through the workspace name: %s", logged)
+		}
+	})
+
+	t.Run("Pinned workspace", func(t *testing.T) {
+		framework.ClearMasks()
+		manager.registerWorkspaceMask("legacy_payments_ws")
+
+		logged := captureStderr(t, func() { framework.Info("Selecting workspace legacy_payments_ws") })
+		if strings.Contains(logged, "legacy_payments_ws") || !strings.Contains(logged, maskIdentifier("workspace", "legacy_payments_ws")) {
+			t.Errorf("Expected the pinned workspace to be masked: %s", logged)
+		}
+	})
+}
+
+// captureStderr captures stderr during function execution
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+
+	originalStderr := os.Stderr
+	os.Stderr = w
+	defer func() {
+		os.Stderr = originalStderr
+	}()
+
+	fn()
+
+	w.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read captured stderr: %v", err)
+	}
+	return string(data)
+}
+
+func TestParseOutputs(t *testing.T) {
+	payload := `{
+  "bucket_name": {"sensitive": false, "type": "string", "value": "my-bucket"},
+  "instance_count": {"sensitive": false, "type": "number", "value": 3},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-a", "subnet-b"]}
+}`
+
+	values, err := parseOutputs([]byte(payload))
+	if err != nil {
+		t.Fatalf("parseOutputs failed: %v", err)
+	}
+
+	if values["bucket_name"] != "my-bucket" {
+		t.Errorf("bucket_name = %v, want my-bucket", values["bucket_name"])
+	}
+	if fmt.Sprint(values["instance_count"]) != "3" {
+		t.Errorf("instance_count = %v, want 3", values["instance_count"])
+	}
+	if subnets, ok := values["subnets"].([]any); !ok || len(subnets) != 2 {
+		t.Errorf("subnets = %v, want two-element list", values["subnets"])
+	}
+
+	expected := []string{
+		"bucket_name=my-bucket",
+		"instance_count=3",
+		"subnets.0=subnet-a",
+		"subnets.1=subnet-b",
+	}
+	outputs, err := decodeOutputs([]byte(payload))
+	if err != nil {
+		t.Fatalf("decodeOutputs failed: %v", err)
+	}
+	if got := flattenOutputs(outputs, false); !reflect.DeepEqual(got, expected) {
+		t.Errorf("flattenOutputs() = %v, want %v", got, expected)
+	}
+
+	if _, err := parseOutputs([]byte("not json")); err == nil {
+		t.Error("Expected error for invalid payload")
+	}
+}
+
+func TestFlattenOutputs(t *testing.T) {
+	payload := `{
+  "db_password": {"sensitive": true, "type": "string", "value": "hunter2"},
+  "list": {"sensitive": false, "type": ["list", "string"], "value": ["a","b","c","d","e","f","g","h","i","j","k"]},
+  "tags": {"sensitive": false, "type": ["map", "string"], "value": {"b": "2", "a": "1"}}
+}`
+	outputs, err := decodeOutputs([]byte(payload))
+	if err != nil {
+		t.Fatalf("decodeOutputs failed: %v", err)
+	}
+
+	t.Run("Sensitive values are hidden", func(t *testing.T) {
+		lines := flattenOutputs(outputs, false)
+		if lines[0] != "db_password=(sensitive)" {
+			t.Errorf("Expected the sensitive output to be hidden, got %q", lines[0])
+		}
+		if strings.Contains(strings.Join(lines, "\n"), "hunter2") {
+			t.Errorf("Sensitive value leaked: %v", lines)
+		}
+	})
+
+	t.Run("Sensitive values on request", func(t *testing.T) {
+		if lines := flattenOutputs(outputs, true); lines[0] != "db_password=hunter2" {
+			t.Errorf("Expected the sensitive value with showSensitive, got %q", lines[0])
+		}
+	})
+
+	t.Run("List indexes sort numerically", func(t *testing.T) {
+		lines := flattenOutputs(outputs, false)
+		want := []string{"list.0=a", "list.1=b", "list.2=c"}
+		if !reflect.DeepEqual(lines[1:4], want) {
+			t.Errorf("lines[1:4] = %v, want %v", lines[1:4], want)
+		}
+		if lines[10] != "list.9=j" || lines[11] != "list.10=k" {
+			t.Errorf("Expected list.9 before list.10, got %v", lines[10:12])
+		}
+		if lines[12] != "tags.a=1" || lines[13] != "tags.b=2" {
+			t.Errorf("Expected map keys in order, got %v", lines[12:])
+		}
+	})
+}
+
+// fakeRunCmd replaces runCmd for the duration of a test and records executed commands
+func fakeRunCmd(t *testing.T, result *framework.CmdResult) *[]string {
+	t.Helper()
+
+	var commands []string
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		commands = append(commands, command)
+		return result
+	}
+	t.Cleanup(func() {
+		runCmd = original
+	})
+	return &commands
+}
+
+// workspaceMissing is terraform's error for selecting a workspace that does not exist
+const workspaceMissing = "\nWorkspace \"ws\" doesn't exist.\n\nYou can create this workspace with the \"new\" subcommand.\n"
+
+// envValue returns the value env gives key; like exec, the last entry wins
+func envValue(env []string, key string) string {
+	value := ""
+	for _, entry := range env {
+		if v, ok := strings.CutPrefix(entry, key+"="); ok {
+			value = v
+		}
+	}
+	return value
+}
+
+// withoutPlanSummary drops the `terraform show -json` run that summarizes a successful plan
+func withoutPlanSummary(commands []string) []string {
+	return slices.DeleteFunc(slices.Clone(commands), func(command string) bool {
+		return strings.HasPrefix(command, "terraform show -json ")
+	})
+}
+
+func TestWritePlanText(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+	dir := t.TempDir()
+	outPath := filepath.Join(dir, "plan.txt")
+
+	t.Run("Successful show", func(t *testing.T) {
+		commands := fakeRunCmd(t, &framework.CmdResult{
+			Success: true,
+			Output:  "Terraform will perform the following actions:\nPlan: 1 to add, 0 to change, 0 to destroy.\n",
+		})
+
+		if err := manager.writePlanText("/tmp/instance_x.tfvars.tfplan", outPath); err != nil {
+			t.Fatalf("writePlanText failed: %v", err)
+		}
+
+		data, err := os.ReadFile(outPath)
+		if err != nil {
+			t.Fatalf("Expected plan text file: %v", err)
+		}
+		if !strings.Contains(string(data), "Plan: 1 to add") {
+			t.Errorf("Unexpected plan text: %s", data)
+		}
+		if len(*commands) != 1 || (*commands)[0] != `terraform show -no-color "/tmp/instance_x.tfvars.tfplan"` {
+			t.Errorf("Unexpected commands: %v", *commands)
+		}
+	})
+
+	t.Run("Failed show removes the file", func(t *testing.T) {
+		fakeRunCmd(t, &framework.CmdResult{Success: false, ExitCode: 1})
+
+		if err := manager.writePlanText("/tmp/instance_x.tfvars.tfplan", outPath); err == nil {
+			t.Fatal("Expected error when show fails")
+		}
+		if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+			t.Error("Expected plan text file to be removed after failure")
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("Expected no leftover files, found %d", len(entries))
+		}
+	})
+}
+
+func TestOutputsFile(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+	const outputs = `{"bucket_name": {"sensitive": false, "type": "string", "value": "my-bucket"}}`
+
+	// fakeApplyCmds reports applySuccess for terraform apply and returns outputs for terraform output -json
+	fakeApplyCmds := func(t *testing.T, applySuccess bool) *[]string {
+		t.Helper()
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			if command == "terraform output -json" {
+				return &framework.CmdResult{Success: true, Output: outputs}
+			}
+			if applySuccess {
+				return &framework.CmdResult{Success: true}
+			}
+			return &framework.CmdResult{Success: false, ExitCode: 1}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &commands
+	}
+
+	for _, action := range []string{"apply", "apply_plan"} {
+		apply := manager.terraformApply
+		if action == "apply_plan" {
+			apply = manager.terraformApplyPlan
+		}
+
+		t.Run(action+" failure leaves the file untouched", func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "outputs.json")
+			if err := os.WriteFile(outPath, []byte("previous"), 0644); err != nil {
+				t.Fatalf("Failed to seed outputs file: %v", err)
+			}
+			commands := fakeApplyCmds(t, false)
+			cmd.ActionFlags = "--outputs-file " + outPath
+
+			if code := exitCodeOf(t, apply(cmd, paths)); code != 1 {
+				t.Errorf("exit code = %d, want 1", code)
+			}
+			if len(*commands) != 1 || strings.Contains((*commands)[0], "--outputs-file") {
+				t.Errorf("Unexpected commands: %v", *commands)
+			}
+			if data, _ := os.ReadFile(outPath); string(data) != "previous" {
+				t.Errorf("Outputs file was overwritten after a failed apply: %s", data)
+			}
+		})
+
+		t.Run(action+" success writes outputs", func(t *testing.T) {
+			outPath := filepath.Join(t.TempDir(), "outputs.json")
+			commands := fakeApplyCmds(t, true)
+			cmd.ActionFlags = "--outputs-file=" + outPath
+
+			if code := exitCodeOf(t, apply(cmd, paths)); code != 0 {
+				t.Errorf("exit code = %d, want 0", code)
+			}
+			if len(*commands) != 2 || (*commands)[1] != "terraform output -json" {
+				t.Errorf("Unexpected commands: %v", *commands)
+			}
+			data, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatalf("Expected outputs file: %v", err)
+			}
+			values, err := parseOutputs(data)
+			if err != nil || values["bucket_name"] != "my-bucket" {
+				t.Errorf("Unexpected outputs file content %s: %v", data, err)
+			}
+		})
+	}
+}
+
+func TestRequireFreshPlan(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	manager, cmd := setupInstance(t)
+	cmd.Action = "apply_plan"
+	paths := manager.computePaths(cmd)
+
+	mainTF := filepath.Join(paths.ModulePath, "main.tf")
+	for _, path := range []string{mainTF, paths.PlanFile} {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	// touch sets a file's modification time relative to now
+	touch := func(path string, age time.Duration) {
+		t.Helper()
+		when := time.Now().Add(-age)
+		if err := os.Chtimes(path, when, when); err != nil {
+			t.Fatalf("Failed to touch %s: %v", path, err)
+		}
+	}
+
+	t.Run("Fresh plan is applied", func(t *testing.T) {
+		touch(mainTF, time.Hour)
+		touch(paths.VarFile, time.Hour)
+		touch(paths.PlanFile, time.Minute)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.ActionFlags = "--require-fresh-plan"
+
+		if code := exitCodeOf(t, manager.terraformApplyPlan(cmd, paths)); code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+		if len(*commands) != 1 || strings.Contains((*commands)[0], "--require-fresh-plan") {
+			t.Errorf("Unexpected commands: %v", *commands)
+		}
+	})
+
+	t.Run("Plan older than tfvars is refused", func(t *testing.T) {
+		touch(paths.PlanFile, time.Hour)
+		touch(paths.VarFile, time.Minute)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.ActionFlags = "--require-fresh-plan"
+
+		err := manager.terraformApplyPlan(cmd, paths)
+		if err == nil || !strings.Contains(err.Error(), "stale plan (1 newer files)") {
+			t.Errorf("Expected stale plan error, got %v", err)
+		}
+		if len(*commands) != 0 {
+			t.Errorf("Expected no terraform commands, got %v", *commands)
+		}
+
+		newer, _ := filesNewerThan(time.Now().Add(-30*time.Minute).UnixNano(), paths)
+		if len(newer) != 1 || newer[0] != paths.VarFile {
+			t.Errorf("filesNewerThan() = %v, want [%s]", newer, paths.VarFile)
+		}
+	})
+
+	t.Run("Config default without the flag", func(t *testing.T) {
+		manager.config.RequireFreshPlan = true
+		defer func() { manager.config.RequireFreshPlan = false }()
+		fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.ActionFlags = ""
+
+		if err := manager.terraformApplyPlan(cmd, paths); err == nil || !strings.Contains(err.Error(), "stale plan") {
+			t.Errorf("Expected stale plan error from config default, got %v", err)
+		}
+	})
+
+	t.Run("Missing plan is refused", func(t *testing.T) {
+		os.Remove(paths.PlanFile)
+		cmd.ActionFlags = "--require-fresh-plan"
+		if err := manager.terraformApplyPlan(cmd, paths); err == nil {
+			t.Error("Expected error without a plan file")
+		}
+	})
+}
+
+func TestResolveEnvWorkspace(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+	computed := "product1.test-repo.sample_module.dev.instance_x"
+
+	t.Run("Unset or matching is silent", func(t *testing.T) {
+		for _, preset := range []string{"", computed} {
+			t.Setenv("TF_WORKSPACE", preset)
+			var got string
+			logged := captureStderr(t, func() { got = manager.resolveEnvWorkspace(computed) })
+			if got != computed || logged != "" {
+				t.Errorf("resolveEnvWorkspace() = %q with output %q, want %q silently", got, logged, computed)
+			}
+		}
+	})
+
+	t.Run("Mismatch warns and overrides", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "other-tooling")
+		var got string
+		logged := captureStderr(t, func() { got = manager.resolveEnvWorkspace(computed) })
+		if got != computed {
+			t.Errorf("resolveEnvWorkspace() = %q, want %q", got, computed)
+		}
+		if !strings.Contains(logged, "other-tooling") || !strings.Contains(logged, computed) {
+			t.Errorf("Expected a warning naming both workspaces, got %q", logged)
+		}
+	})
+
+	t.Run("Respect flag keeps the exported workspace", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "other-tooling")
+		manager.SetOptions(Options{RespectEnvWorkspace: true})
+		defer manager.SetOptions(Options{})
+		if got := manager.resolveEnvWorkspace(computed); got != "other-tooling" {
+			t.Errorf("resolveEnvWorkspace() = %q, want other-tooling", got)
+		}
+	})
+}
+
+func TestMetadata(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+
+	for actionFlags, want := range map[string]string{
+		"":                "terraform metadata functions -json",
+		"functions -json": "terraform metadata functions -json",
+	} {
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		if code := exitCodeOf(t, manager.terraformMetadata(&Command{Action: "metadata", ActionFlags: actionFlags})); code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+		if len(*commands) != 1 || (*commands)[0] != want {
+			t.Errorf("metadata %q ran %v, want %q", actionFlags, *commands, want)
+		}
+	}
+
+	if needsWorkspace("metadata") {
+		t.Error("Expected metadata to skip workspace validation")
+	}
+	if !needsWorkspace("plan") {
+		t.Error("Expected plan to require the instance workspace")
+	}
+	if !IsValidAction("metadata") {
+		t.Error("Expected metadata to be a supported action")
+	}
+}
+
+func TestRaw(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo", GlobalTerraformFlags: []string{"-no-color"}})
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+
+	for actionFlags, want := range map[string]string{
+		"state pull":                      "terraform state pull",
+		"console -var-file=dev.tfvars":    "terraform console -var-file=dev.tfvars",
+		"test -filter=tests/a.tftest.hcl": "terraform test -filter=tests/a.tftest.hcl",
+	} {
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		var err error
+		output := captureStderr(t, func() { err = manager.terraformRaw(&Command{Action: "raw", ActionFlags: actionFlags}) })
+		if code := exitCodeOf(t, err); code != 0 {
+			t.Errorf("raw %q exit code = %d, want 0", actionFlags, code)
+		}
+		if len(*commands) != 1 || (*commands)[0] != want {
+			t.Errorf("raw %q ran %v, want %q", actionFlags, *commands, want)
+		}
+		if !strings.Contains(output, "unvalidated") {
+			t.Errorf("Expected raw %q to be flagged as unvalidated, got:\n%s", actionFlags, output)
+		}
+	}
+
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+	var err error
+	captureStderr(t, func() { err = manager.terraformRaw(&Command{Action: "raw"}) })
+	if code := exitCodeOf(t, err); code != ExitValidationFailed || len(*commands) != 0 {
+		t.Errorf("raw without a subcommand = exit %d running %v, want %d running nothing", code, *commands, ExitValidationFailed)
+	}
+
+	if !IsValidAction("raw") || !needsWorkspace("raw") {
+		t.Error("Expected raw to be a supported action that runs in the instance workspace")
+	}
+}
+
+func TestPlanDir(t *testing.T) {
+	cmd := &Command{Product: "product1", Module: "sample_module", Env: "team/dev", ModuleInstance: "instance_x"}
+
+	tests := []struct {
+		name    string
+		planDir string
+		want    string
+	}{
+		{"Default next to tfvars", "", "/repo/terraform/environments/product1/team/dev/sample_module/instance_x.tfvars.tfplan"},
+		{"Relative to project", ".plans", "/repo/.plans/product1/team/dev/sample_module/instance_x.tfplan"},
+		{"Absolute", "/var/plans", "/var/plans/product1/team/dev/sample_module/instance_x.tfplan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(&config.Config{
+				RepoName:      "test-repo",
+				EnvRelPath:    "terraform/environments",
+				ModuleRelPath: "terraform/modules",
+				ProjectDir:    "/repo",
+				PlanDir:       tt.planDir,
+			})
+			paths := manager.computePaths(cmd)
+			if paths.PlanFile != tt.want {
+				t.Errorf("PlanFile = %s, want %s", paths.PlanFile, tt.want)
+			}
+			if want := "/repo/terraform/environments/product1/team/dev/sample_module/instance_x.tfvars"; paths.VarFile != want {
+				t.Errorf("VarFile = %s, want %s", paths.VarFile, want)
+			}
+		})
+	}
+
+	t.Run("Plan creates the directory", func(t *testing.T) {
+		manager, cmd := setupInstance(t)
+		manager.config.PlanDir = ".plans"
+		paths := manager.computePaths(cmd)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = "plan"
+		manager.terraformPlan(cmd, paths)
+
+		if info, err := os.Stat(filepath.Dir(paths.PlanFile)); err != nil || !info.IsDir() {
+			t.Errorf("Expected plan directory %s to be created", filepath.Dir(paths.PlanFile))
+		}
+		if len(withoutPlanSummary(*commands)) != 1 || !strings.Contains((*commands)[0], "-out=\""+paths.PlanFile+"\"") {
+			t.Errorf("Expected plan to write %s, got %v", paths.PlanFile, *commands)
+		}
+	})
+}
+
+func TestArtifactDir(t *testing.T) {
+	cmd := &Command{Product: "product1", Module: "sample_module", Env: "team/dev", ModuleInstance: "instance_x"}
+
+	tests := []struct {
+		name        string
+		artifactDir string
+		outDir      string
+		want        string
+	}{
+		{"Relative to project", "artifacts", "", "/repo/artifacts/product1/team/dev/sample_module/instance_x/plan.tfplan"},
+		{"Absolute", "/var/artifacts", "", "/var/artifacts/product1/team/dev/sample_module/instance_x/plan.tfplan"},
+		{"Out dir overrides config", "artifacts", "/tmp/out", "/tmp/out/product1/team/dev/sample_module/instance_x/plan.tfplan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(&config.Config{
+				RepoName:      "test-repo",
+				EnvRelPath:    "terraform/environments",
+				ModuleRelPath: "terraform/modules",
+				ProjectDir:    "/repo",
+				PlanDir:       ".plans",
+				ArtifactDir:   tt.artifactDir,
+			})
+			manager.SetOptions(Options{OutDir: tt.outDir})
+			if paths := manager.computePaths(cmd); paths.PlanFile != tt.want {
+				t.Errorf("PlanFile = %s, want %s", paths.PlanFile, tt.want)
+			}
+		})
+	}
+
+	t.Run("Relative artifact flags land in the artifact dir", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		manager, cmd := setupInstance(t)
+		outDir := t.TempDir()
+		manager.options.OutDir = outDir
+		instanceDir := filepath.Join(outDir, cmd.Product, cmd.Env, cmd.Module, cmd.ModuleInstance)
+
+		if got := manager.resolveArtifactPath(cmd, "outputs.json"); got != filepath.Join(instanceDir, "outputs.json") {
+			t.Errorf("resolveArtifactPath = %s, want it under %s", got, instanceDir)
+		}
+		if got := manager.resolveArtifactPath(cmd, "/abs/outputs.json"); got != "/abs/outputs.json" {
+			t.Errorf("Absolute path was rewritten to %s", got)
+		}
+
+		fakeRunCmd(t, &framework.CmdResult{Success: true, Output: "plan text"})
+		cmd.Action = "plan"
+		cmd.ActionFlags = "--plan-out-text plan.txt"
+		paths := manager.computePaths(cmd)
+		if err := manager.terraformPlan(cmd, paths); exitCodeOf(t, err) != 0 {
+			t.Fatalf("terraformPlan failed: %v", err)
+		}
+		if filepath.Dir(paths.PlanFile) != instanceDir {
+			t.Errorf("PlanFile = %s, want it under %s", paths.PlanFile, instanceDir)
+		}
+		if data, err := os.ReadFile(filepath.Join(instanceDir, "plan.txt")); err != nil || string(data) != "plan text" {
+			t.Errorf("Expected plan text in the artifact dir: %q, %v", data, err)
+		}
+	})
+}
+
+func TestReviewPlans(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, *Command, *Paths, *[]string) {
+		t.Helper()
+		manager, cmd := setupInstance(t)
+		manager.config.ReviewPlans = true
+		cmd.Action = "plan"
+		paths := manager.computePaths(cmd)
+		if err := os.WriteFile(paths.PlanFile, []byte("previous"), 0644); err != nil {
+			t.Fatalf("Failed to seed plan: %v", err)
+		}
+		// terraform plan writes its -out file
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			if strings.HasPrefix(command, "terraform plan") {
+				os.WriteFile(pendingPlanFile(paths.PlanFile), []byte("reviewed"), 0644)
+			}
+			return &framework.CmdResult{Success: true}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return manager, cmd, paths, &commands
+	}
+
+	assertPlan := func(t *testing.T, paths *Paths, want string) {
+		t.Helper()
+		if data, _ := os.ReadFile(paths.PlanFile); string(data) != want {
+			t.Errorf("Canonical plan = %q, want %q", data, want)
+		}
+		if _, err := os.Stat(pendingPlanFile(paths.PlanFile)); !os.IsNotExist(err) {
+			t.Errorf("Expected the pending plan to be removed, got %v", err)
+		}
+	}
+
+	t.Run("Promote on confirm", func(t *testing.T) {
+		manager, cmd, paths, commands := setup(t)
+		manager.stdin = strings.NewReader("instance_x\n")
+
+		if code := exitCodeOf(t, manager.terraformPlan(cmd, paths)); code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+		if !strings.Contains((*commands)[0], "-out=\""+pendingPlanFile(paths.PlanFile)+"\"") {
+			t.Errorf("Expected plan to write the pending file, got %s", (*commands)[0])
+		}
+		assertPlan(t, paths, "reviewed")
+	})
+
+	t.Run("Promote on flag", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		manager, cmd, paths, commands := setup(t)
+		cmd.ActionFlags = "--approve-plan"
+
+		if code := exitCodeOf(t, manager.terraformPlan(cmd, paths)); code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+		if strings.Contains((*commands)[0], "--approve-plan") {
+			t.Errorf("--approve-plan was passed to terraform: %s", (*commands)[0])
+		}
+		assertPlan(t, paths, "reviewed")
+	})
+
+	t.Run("Rejected by operator", func(t *testing.T) {
+		manager, cmd, paths, _ := setup(t)
+		manager.stdin = strings.NewReader("nope\n")
+
+		if code := exitCodeOf(t, manager.terraformPlan(cmd, paths)); code != ExitApprovalDenied {
+			t.Errorf("exit code = %d, want %d", code, ExitApprovalDenied)
+		}
+		assertPlan(t, paths, "previous")
+	})
+
+	t.Run("Unattended without flag", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		manager, cmd, paths, _ := setup(t)
+
+		if code := exitCodeOf(t, manager.terraformPlan(cmd, paths)); code != ExitApprovalDenied {
+			t.Errorf("exit code = %d, want %d", code, ExitApprovalDenied)
+		}
+		assertPlan(t, paths, "previous")
+	})
+}
+
+func TestApplyPlanFileOverride(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+
+	artifact := filepath.Join(t.TempDir(), "restored.tfplan")
+	if err := os.WriteFile(artifact, []byte("plan"), 0644); err != nil {
+		t.Fatalf("Failed to write plan artifact: %v", err)
+	}
+
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+	cmd.Action = "apply_plan"
+	cmd.ActionFlags = "--plan-file " + artifact + " -compact-warnings"
+	if err := manager.terraformApplyPlan(cmd, paths); exitCodeOf(t, err) != 0 {
+		t.Fatalf("terraformApplyPlan failed: %v", err)
+	}
+	if len(*commands) != 1 {
+		t.Fatalf("Expected one command, got %v", *commands)
+	}
+	if want := "terraform apply \"" + artifact + "\" -input=false -no-color -compact-warnings"; (*commands)[0] != want {
+		t.Errorf("Command = %s, want %s", (*commands)[0], want)
+	}
+
+	// A missing override fails validation before terraform runs
+	*commands = nil
+	cmd.ActionFlags = "--plan-file " + filepath.Join(t.TempDir(), "missing.tfplan")
+	if code := exitCodeOf(t, manager.terraformApplyPlan(cmd, manager.computePaths(cmd))); code != ExitValidationFailed {
+		t.Errorf("Missing plan file exit code = %d, want %d", code, ExitValidationFailed)
+	}
+	if len(*commands) != 0 {
+		t.Errorf("Expected no terraform command for a missing plan file, got %v", *commands)
+	}
+}
+
+func TestWorkspacePrefix(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+
+	if got, want := manager.generateWorkspace(cmd, paths), "product1.test-repo.sample_module.dev.instance_x"; got != want {
+		t.Errorf("Unprefixed workspace = %s, want %s", got, want)
+	}
+
+	manager.config.WorkspacePrefix = "team-a"
+	workspace := manager.generateWorkspace(cmd, paths)
+	if want := "team-a.product1.test-repo.sample_module.dev.instance_x"; workspace != want {
+		t.Fatalf("Prefixed workspace = %s, want %s", workspace, want)
+	}
+
+	// The unprefixed workspace from another repository must not count as existing
+	manager.config.WorkspaceSelectEnv = true
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true, Output: "* default\n  product1.test-repo.sample_module.dev.instance_x\n"})
+	if err := manager.ensureWorkspace(workspace); err != nil {
+		t.Fatalf("ensureWorkspace failed: %v", err)
+	}
+	if want := []string{"terraform workspace list", "terraform workspace new " + workspace}; !reflect.DeepEqual(*commands, want) {
+		t.Errorf("Commands = %v, want %v", *commands, want)
+	}
+	if got := envValue(manager.env, "TF_WORKSPACE"); got != workspace {
+		t.Errorf("Selected workspace = %s, want %s", got, workspace)
+	}
+}
+
+func TestInstanceWorkspace(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+	computed := "product1.test-repo.sample_module.dev.instance_x"
+	sidecar := filepath.Join(paths.ModuleEnvPath, "instance_x.tfworkspace")
+
+	tests := []struct {
+		name     string
+		sidecar  string // Sidecar content; empty for no sidecar
+		override string
+		want     string
+		wantCode int
+	}{
+		{"Computed without sidecar", "", "", computed, 0},
+		{"Sidecar wins over computed name", "legacy-network-prod\n", "", "legacy-network-prod", 0},
+		{"CLI override wins over sidecar", "legacy-network-prod\n", "custom", "custom", 0},
+		{"Blank sidecar is rejected", " \n", "", "", ExitValidationFailed},
+		{"Several names are rejected", "one\ntwo\n", "", "", ExitValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(sidecar)
+			if tt.sidecar != "" {
+				if err := os.WriteFile(sidecar, []byte(tt.sidecar), 0644); err != nil {
+					t.Fatalf("Failed to write sidecar: %v", err)
+				}
+			}
+			withOverride := *cmd
+			withOverride.Workspace = tt.override
+
+			got, err := manager.instanceWorkspace(&withOverride, paths)
+			if tt.wantCode != 0 {
+				if code := exitCodeOf(t, err); code != tt.wantCode {
+					t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("instanceWorkspace() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("instanceWorkspace() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Execute selects the sidecar workspace", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+		fakeTerraformInstalled(t)
+		if err := os.WriteFile(sidecar, []byte("legacy-network-prod\n"), 0644); err != nil {
+			t.Fatalf("Failed to write sidecar: %v", err)
+		}
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = "validate"
+
+		manager.Execute(cmd)
+		if !slices.Contains(*commands, "terraform workspace select legacy-network-prod") {
+			t.Errorf("Expected the sidecar workspace to be selected, got %v", *commands)
+		}
+		if got := manager.RunInfo().Workspace; got != "legacy-network-prod" {
+			t.Errorf("RunInfo().Workspace = %s, want legacy-network-prod", got)
+		}
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	fakeTerraformInstalled(t)
+	manager := NewManager(config.DefaultConfig())
+
+	t.Run("Login and logout forward to terraform", func(t *testing.T) {
+		clearCIEnvVars()
+		tests := []struct {
+			cmd  Command
+			want string
+		}{
+			{Command{Action: "login"}, "terraform login"},
+			{Command{Action: "login", ActionFlags: "tfe.example.com:8443"}, "terraform login tfe.example.com:8443"},
+			{Command{Action: "logout", ActionFlags: "app.terraform.io"}, "terraform logout app.terraform.io"},
+		}
+		for _, tt := range tests {
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			if err := manager.Authenticate(&tt.cmd); exitCodeOf(t, err) != 0 {
+				t.Errorf("Authenticate(%s) failed: %v", tt.want, err)
+			}
+			if len(*commands) != 1 || (*commands)[0] != tt.want {
+				t.Errorf("Commands = %v, want [%s]", *commands, tt.want)
+			}
+		}
+	})
+
+	t.Run("Invalid hostname", func(t *testing.T) {
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		if code := exitCodeOf(t, manager.Authenticate(&Command{Action: "login", ActionFlags: "-help"})); code != ExitValidationFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitValidationFailed)
+		}
+		if len(*commands) != 0 {
+			t.Errorf("Expected no terraform command, got %v", *commands)
+		}
+	})
+
+	t.Run("Unattended login is refused", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		if code := exitCodeOf(t, manager.Authenticate(&Command{Action: "login"})); code != ExitValidationFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitValidationFailed)
+		}
+		if err := manager.Authenticate(&Command{Action: "logout"}); exitCodeOf(t, err) != 0 {
+			t.Errorf("Unattended logout failed: %v", err)
+		}
+		if want := []string{"terraform logout"}; !reflect.DeepEqual(*commands, want) {
+			t.Errorf("Commands = %v, want %v", *commands, want)
+		}
+	})
+}
+
+func TestProvidersLockPlatforms(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+	clearCIEnvVars()
+	cmd.Action = "providers"
+
+	tests := []struct {
+		name        string
+		configured  []string
+		actionFlags string
+		want        string
+	}{
+		{"Defaults injected", nil, "lock", "terraform providers lock -platform=linux_amd64 -platform=darwin_arm64 -platform=windows_amd64"},
+		{"Configured platforms", []string{"linux_arm64"}, "lock -fs-mirror=/mirror", "terraform providers lock -fs-mirror=/mirror -platform=linux_arm64"},
+		{"Explicit platform wins", nil, "lock -platform=linux_amd64", "terraform providers lock -platform=linux_amd64"},
+		{"Other subcommands untouched", nil, "schema -json", "terraform providers schema -json"},
+		{"Plain providers untouched", nil, "", "terraform providers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager.config.ProviderLockPlatforms = tt.configured
+			cmd.ActionFlags = tt.actionFlags
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			manager.terraformProviders(cmd, paths)
+			if len(*commands) != 1 || (*commands)[0] != tt.want {
+				t.Errorf("Commands = %v, want [%s]", *commands, tt.want)
+			}
+		})
+	}
+}
+
+func TestParallelismFlag(t *testing.T) {
+	tests := []struct {
+		actionFlags string
+		want        string
+		rest        string
+		wantErr     bool
+	}{
+		{"", "", "", false},
+		{"--parallelism 20", " -parallelism=20", "", false},
+		{"--parallelism=5 -refresh=false", " -parallelism=5", "-refresh=false", false},
+		{"--parallelism 0", "", "", true},
+		{"--parallelism=-3", "", "", true},
+		{"--parallelism ten", "", "", true},
+		{"--parallelism", "", "", true},
+	}
+
+	for _, tt := range tests {
+		cmd := &Command{ActionFlags: tt.actionFlags}
+		got, err := parallelismFlag(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parallelismFlag(%q) error = %v, wantErr %v", tt.actionFlags, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if code := exitCodeOf(t, err); code != ExitValidationFailed {
+				t.Errorf("parallelismFlag(%q) exit code = %d, want %d", tt.actionFlags, code, ExitValidationFailed)
+			}
+			continue
+		}
+		if got != tt.want || cmd.ActionFlags != tt.rest {
+			t.Errorf("parallelismFlag(%q) = %q leaving %q, want %q leaving %q", tt.actionFlags, got, cmd.ActionFlags, tt.want, tt.rest)
+		}
+	}
+
+	// The terraform flag sits with the managed flags, ahead of the plan file and the user's flags
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+	cmd.Action = "plan"
+	cmd.ActionFlags = "--parallelism 20 -refresh=false"
+	manager.terraformPlan(cmd, paths)
+	want := fmt.Sprintf(` -parallelism=20 -out="%s"`, paths.PlanFile)
+	if len(withoutPlanSummary(*commands)) != 1 || !strings.Contains((*commands)[0], want) || !strings.HasSuffix((*commands)[0], " -refresh=false") {
+		t.Errorf("Expected %q before the user flags, got %v", want, *commands)
+	}
+}
+
+func TestNoRefreshFlag(t *testing.T) {
+	tests := []struct {
+		actionFlags string
+		want        string
+		rest        string
+		wantErr     bool
+	}{
+		{"", "", "", false},
+		{"--no-refresh", " -refresh=false", "", false},
+		{"--no-refresh -target=aws_s3_bucket.logs", " -refresh=false", "-target=aws_s3_bucket.logs", false},
+		{"-refresh-only", "", "-refresh-only", false},
+		{"--no-refresh -refresh-only", "", "", true},
+		{"--refresh-only --no-refresh", "", "", true},
+	}
+
+	for _, tt := range tests {
+		cmd := &Command{ActionFlags: tt.actionFlags}
+		got, err := noRefreshFlag(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("noRefreshFlag(%q) error = %v, wantErr %v", tt.actionFlags, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if code := exitCodeOf(t, err); code != ExitValidationFailed {
+				t.Errorf("noRefreshFlag(%q) exit code = %d, want %d", tt.actionFlags, code, ExitValidationFailed)
+			}
+			continue
+		}
+		if got != tt.want || cmd.ActionFlags != tt.rest {
+			t.Errorf("noRefreshFlag(%q) = %q leaving %q, want %q leaving %q", tt.actionFlags, got, cmd.ActionFlags, tt.want, tt.rest)
+		}
+	}
+
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	for _, action := range []string{"plan", "apply"} {
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = action
+		cmd.ActionFlags = "--no-refresh"
+		if action == "plan" {
+			manager.terraformPlan(cmd, paths)
+		} else {
+			manager.terraformApply(cmd, paths)
+		}
+		if len(*commands) == 0 || !strings.Contains((*commands)[0], " -refresh=false") || strings.Contains((*commands)[0], "--no-refresh") {
+			t.Errorf("%s: expected -refresh=false in place of --no-refresh, got %v", action, *commands)
+		}
+	}
+}
+
+func TestReplaceFlags(t *testing.T) {
+	tests := []struct {
+		actionFlags string
+		want        string
+		rest        string
+		wantErr     bool
+	}{
+		{"", "", "", false},
+		{"--replace aws_instance.web", ` '-replace=aws_instance.web'`, "", false},
+		{"--replace=aws_instance.web[0] -refresh=false --replace module.db[\"eu\"].aws_db_instance.main",
+			` '-replace=aws_instance.web[0]' '-replace=module.db["eu"].aws_db_instance.main'`, "-refresh=false", false},
+		{"--replace data.aws_ami.ubuntu", ` '-replace=data.aws_ami.ubuntu'`, "", false},
+		{"--replace aws_instance", "", "", true},
+		{"--replace module.db", "", "", true},
+		{"--replace aws_instance.web[x]", "", "", true},
+		{"--replace", "", "", true},
+	}
+
+	for _, tt := range tests {
+		cmd := &Command{ActionFlags: tt.actionFlags}
+		got, err := replaceFlags(cmd)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("replaceFlags(%q) error = %v, wantErr %v", tt.actionFlags, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if code := exitCodeOf(t, err); code != ExitValidationFailed {
+				t.Errorf("replaceFlags(%q) exit code = %d, want %d", tt.actionFlags, code, ExitValidationFailed)
+			}
+			continue
+		}
+		if got != tt.want || cmd.ActionFlags != tt.rest {
+			t.Errorf("replaceFlags(%q) = %q leaving %q, want %q leaving %q", tt.actionFlags, got, cmd.ActionFlags, tt.want, tt.rest)
+		}
+	}
+
+	// Replace arguments follow the var file and precede the plan file and the user's flags
+	for _, action := range []string{"plan", "apply"} {
+		t.Run(action, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+			paths := manager.computePaths(cmd)
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			cmd.Action = action
+			cmd.ActionFlags = "--replace aws_instance.web --replace aws_instance.db -refresh=false"
+			if action == "plan" {
+				manager.terraformPlan(cmd, paths)
+			} else {
+				manager.terraformApply(cmd, paths)
+			}
+			if len(withoutPlanSummary(*commands)) != 1 {
+				t.Fatalf("Expected one terraform command, got %v", *commands)
+			}
+			got := (*commands)[0]
+			varFile := strings.Index(got, "-var-file=")
+			first := strings.Index(got, `'-replace=aws_instance.web' '-replace=aws_instance.db'`)
+			if varFile < 0 || first < varFile || !strings.HasSuffix(got, " -refresh=false") {
+				t.Errorf("Expected both -replace flags after the var file, got %q", got)
+			}
+			if action == "plan" && first > strings.Index(got, "-out=") {
+				t.Errorf("Expected -replace flags before -out, got %q", got)
+			}
+		})
+	}
+}
+
+func TestClean(t *testing.T) {
+	// setupModules creates two modules with provider caches and lock files
+	setupModules := func(t *testing.T) (*Manager, []string) {
+		t.Helper()
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		other := filepath.Join(manager.config.GetModulePath(), "other_module")
+
+		var created []string
+		for _, dir := range []string{paths.ModulePath, other} {
+			if err := os.MkdirAll(filepath.Join(dir, ".terraform", "providers"), 0755); err != nil {
+				t.Fatalf("Failed to create .terraform: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), nil, 0644); err != nil {
+				t.Fatalf("Failed to create lock file: %v", err)
+			}
+			created = append(created, filepath.Join(dir, ".terraform"), filepath.Join(dir, ".terraform.lock.hcl"))
+		}
+		return manager, created
+	}
+
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	t.Run("Dry run lists without removing", func(t *testing.T) {
+		manager, created := setupModules(t)
+		var out strings.Builder
+		if err := manager.Clean(&out, "product1", "sample_module", CleanOptions{LockFile: true, DryRun: true}); err != nil {
+			t.Fatalf("Clean failed: %v", err)
+		}
+		want := "would remove " + created[0] + "\nwould remove " + created[1] + "\n"
+		if out.String() != want {
+			t.Errorf("Dry run output = %q, want %q", out.String(), want)
+		}
+		for _, path := range created {
+			if !exists(path) {
+				t.Errorf("Dry run removed %s", path)
+			}
+		}
+	})
+
+	t.Run("Forced clean of one module keeps the lock file by default", func(t *testing.T) {
+		manager, created := setupModules(t)
+		var out strings.Builder
+		if err := manager.Clean(&out, "product1", "sample_module", CleanOptions{Force: true}); err != nil {
+			t.Fatalf("Clean failed: %v", err)
+		}
+		if exists(created[0]) || !exists(created[1]) || !exists(created[2]) {
+			t.Error("Expected only sample_module/.terraform to be removed")
+		}
+		if out.String() != "removed "+created[0]+"\n" {
+			t.Errorf("Unexpected output %q", out.String())
+		}
+	})
+
+	t.Run("All modules with lock files", func(t *testing.T) {
+		manager, created := setupModules(t)
+		if err := manager.Clean(io.Discard, "", "", CleanOptions{All: true, LockFile: true, Force: true}); err != nil {
+			t.Fatalf("Clean failed: %v", err)
+		}
+		for _, path := range created {
+			if exists(path) {
+				t.Errorf("Expected %s to be removed", path)
+			}
+		}
+	})
+
+	t.Run("Confirmation required", func(t *testing.T) {
+		manager, created := setupModules(t)
+		clearCIEnvVars()
+		manager.stdin = strings.NewReader("nope\n")
+		if err := manager.Clean(io.Discard, "product1", "sample_module", CleanOptions{}); err == nil {
+			t.Error("Expected clean to abort on a wrong confirmation")
+		}
+		if !exists(created[0]) {
+			t.Error("Expected nothing to be removed without confirmation")
+		}
+
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		if code := exitCodeOf(t, manager.Clean(io.Discard, "product1", "sample_module", CleanOptions{})); code != ExitValidationFailed {
+			t.Errorf("Unattended clean without --force exit code = %d, want %d", code, ExitValidationFailed)
+		}
+	})
+
+	t.Run("Unknown module", func(t *testing.T) {
+		manager, _ := setupModules(t)
+		if err := manager.Clean(io.Discard, "product1", "no_such_module", CleanOptions{Force: true}); err == nil {
+			t.Error("Expected error for an unknown module")
+		}
+	})
+}
+
+// exitCodeOf returns the exit code carried by err, failing the test if there is none
+func exitCodeOf(t *testing.T, err error) int {
+	t.Helper()
+	var exitErr *ExitCodeError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Expected *ExitCodeError, got %T: %v", err, err)
+	}
+	return exitErr.ExitCode
+}
+
+// fakeTerraformInstalled makes terraform appear to be on PATH for the duration of the test
+func fakeTerraformInstalled(t *testing.T) {
+	t.Helper()
+	original := lookPath
+	lookPath = func(file string) (string, error) {
+		return "/usr/local/bin/" + file, nil
+	}
+	t.Cleanup(func() { lookPath = original })
+}
+
+// setupInstance creates a minimal project layout for a single module instance
+func setupInstance(t *testing.T) (*Manager, *Command) {
+	t.Helper()
+
+	projectDir := t.TempDir()
+	cfg := &config.Config{
+		RepoName:      "test-repo",
+		EnvRelPath:    "terraform/environments",
+		ModuleRelPath: "terraform/modules",
+		ProjectDir:    projectDir,
+	}
+	cmd := &Command{
+		Product:        "product1",
+		Module:         "sample_module",
+		Env:            "dev",
+		ModuleInstance: "instance_x",
+	}
+
+	manager := NewManager(cfg)
+	paths := manager.computePaths(cmd)
+	for _, dir := range []string{paths.ModulePath, paths.ModuleEnvPath} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(paths.VarFile, []byte("instance_count = 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to create var file: %v", err)
+	}
+
+	return manager, cmd
+}
+
+func TestShowCommand(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+
+	// No plan yet: show state
+	if got := showCommand(paths, false); got != "terraform show" {
+		t.Errorf("showCommand() = %s, want terraform show", got)
+	}
+	if got := showCommand(paths, true); got != "terraform show -json" {
+		t.Errorf("showCommand(json) = %s, want terraform show -json", got)
+	}
+
+	// Plan exists: prefer it over state
+	if err := os.WriteFile(paths.PlanFile, []byte("plan"), 0644); err != nil {
+		t.Fatalf("Failed to create plan file: %v", err)
+	}
+	want := `terraform show -json "` + paths.PlanFile + `"`
+	if got := showCommand(paths, true); got != want {
+		t.Errorf("showCommand(json) = %s, want %s", got, want)
+	}
+
+	// ShowPlanJSON runs the JSON variant and returns its raw output
+	var commands []string
+	var workspace string
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		commands = append(commands, command)
+		workspace = envValue(flags.Env, "TF_WORKSPACE")
+		return &framework.CmdResult{Success: true, Output: `{"format_version":"1.2"}`}
+	}
+	t.Cleanup(func() { runCmd = original })
+	data, err := manager.ShowPlanJSON(cmd)
+	if err != nil {
+		t.Fatalf("ShowPlanJSON failed: %v", err)
+	}
+	if string(data) != `{"format_version":"1.2"}` {
+		t.Errorf("ShowPlanJSON() = %s", data)
+	}
+	if len(commands) != 1 || commands[0] != want {
+		t.Errorf("Unexpected commands: %v", commands)
+	}
+	if workspace != "product1.test-repo.sample_module.dev.instance_x" {
+		t.Errorf("Unexpected TF_WORKSPACE: %s", workspace)
+	}
+}
+
+func TestEnterInstance(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	base := manager.config
+	base.EnvOverrides = map[string]config.EnvOverride{
+		"dev": {ModuleRelPath: "terraform/modules-pinned"},
+	}
+
+	var dirs []string
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		dirs = append(dirs, flags.Dir)
+		return &framework.CmdResult{Success: true, Output: `{"bucket": {"sensitive": false, "value": "b"}}`}
+	}
+	t.Cleanup(func() { runCmd = original })
+
+	if _, err := manager.GetOutputs(cmd); err != nil {
+		t.Fatalf("GetOutputs failed: %v", err)
+	}
+	if want := filepath.Join(base.ProjectDir, "terraform/modules-pinned", "sample_module"); len(dirs) != 1 || dirs[0] != want {
+		t.Errorf("Expected the env_overrides module path %s, got %v", want, dirs)
+	}
+
+	// A later run on the same Manager must not inherit the instance's directory or workspace
+	if manager.config != base || manager.workDir != "" || len(manager.env) != 0 {
+		t.Errorf("Manager not restored: config changed %t, workDir %q, env %v", manager.config != base, manager.workDir, manager.env)
+	}
+}
+
+func TestInitCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		actionFlags string
+		want        string
+		wantErr     bool
+	}{
+		{"Plain init", "", "terraform init", false},
+		{"Migrate state", "--migrate-state", "terraform init -migrate-state", false},
+		{"Reconfigure", "--reconfigure", "terraform init -reconfigure", false},
+		{"Passthrough kept", "--migrate-state -force-copy -upgrade", "terraform init -migrate-state -force-copy -upgrade", false},
+		{"Terraform flags untouched", "-reconfigure -backend-config=prod.hcl", "terraform init -reconfigure -backend-config=prod.hcl", false},
+		{"Mutually exclusive", "--migrate-state --reconfigure", "", true},
+		{"Upgrade", "--upgrade", "terraform init -upgrade", false},
+		{"Backend disabled", "--backend=false", "terraform init -backend=false", false},
+		{"Backend value as next argument", "--backend false --upgrade", "terraform init -upgrade -backend=false", false},
+		{"Backend enabled adds nothing", "--backend=true", "terraform init", false},
+		{"Shorthands with action flags", "--reconfigure --upgrade -backend-config=prod.hcl", "terraform init -reconfigure -upgrade -backend-config=prod.hcl", false},
+		{"Invalid backend value", "--backend=off", "", true},
+		{"Missing backend value", "--backend", "", true},
+	}
+
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := manager.initCommand(&Command{Action: "init", ActionFlags: tt.actionFlags})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("initCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("initCommand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackendKeyTemplate(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	useWorkspaces := false
+	manager.config.UseWorkspaces = &useWorkspaces
+	manager.config.BackendKeyTemplate = "{{.Repo}}/{{.Product}}/{{.Env}}/{{.Module}}/{{.Instance}}.tfstate"
+	const keyFlag = "'-backend-config=key=test-repo/product1/dev/sample_module/instance_x.tfstate'"
+
+	t.Run("Init gets the rendered key", func(t *testing.T) {
+		tests := []struct {
+			actionFlags string
+			want        string
+		}{
+			{"", "terraform init -reconfigure " + keyFlag},
+			{"--upgrade", "terraform init -reconfigure " + keyFlag + " -upgrade"},
+			{"--migrate-state", "terraform init -migrate-state " + keyFlag},
+			{"--backend=false", "terraform init -backend=false"},
+		}
+		for _, tt := range tests {
+			initCmd := *cmd
+			initCmd.Action, initCmd.ActionFlags = "init", tt.actionFlags
+			got, err := manager.initCommand(&initCmd)
+			if err != nil || got != tt.want {
+				t.Errorf("initCommand(%q) = %q, %v; want %q", tt.actionFlags, got, err, tt.want)
+			}
+		}
+	})
+
+	t.Run("Initialized key must match the instance", func(t *testing.T) {
+		paths := manager.computePaths(cmd)
+		if err := manager.checkBackendKey(cmd, paths); err != nil {
+			t.Errorf("Expected an uninitialized module to be left to terraform, got %v", err)
+		}
+
+		dataDir := filepath.Join(paths.ModulePath, ".terraform")
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dataDir, err)
+		}
+		writeBackend := func(key string) {
+			state := fmt.Sprintf(`{"version":3,"backend":{"type":"s3","config":{"bucket":"state","key":%q}}}`, key)
+			if err := os.WriteFile(filepath.Join(dataDir, "terraform.tfstate"), []byte(state), 0644); err != nil {
+				t.Fatalf("Failed to write backend state: %v", err)
+			}
+		}
+
+		writeBackend("test-repo/product1/dev/sample_module/instance_x.tfstate")
+		if err := manager.checkBackendKey(cmd, paths); err != nil {
+			t.Errorf("Expected the matching key to pass, got %v", err)
+		}
+
+		writeBackend("test-repo/product1/dev/sample_module/instance_y.tfstate")
+		var err error
+		captureStderr(t, func() { err = manager.checkBackendKey(cmd, paths) })
+		if code := exitCodeOf(t, err); code != ExitValidationFailed {
+			t.Errorf("Expected exit code %d for another instance's key, got %v", ExitValidationFailed, err)
+		}
+	})
+
+	t.Run("Workspace actions are refused", func(t *testing.T) {
+		fakeTerraformInstalled(t)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		workspaceCmd := *cmd
+		workspaceCmd.Action = "delete-workspace"
+
+		var err error
+		captureStderr(t, func() { err = manager.Execute(&workspaceCmd) })
+		if code := exitCodeOf(t, err); code != ExitValidationFailed || len(*commands) != 0 {
+			t.Errorf("Expected delete-workspace to be refused without running terraform, got %v (commands %v)", err, *commands)
+		}
+	})
+}
+
+func TestActionTerraformFlagsPerAction(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	manager, cmd := setupInstance(t)
+	manager.config.ActionTerraformFlags = map[string][]string{
+		"plan":  {"-compact-warnings"},
+		"apply": {"-parallelism=20"},
+	}
+	manager.SetOptions(Options{TerraformColor: true}) // Keep -no-color out of the expected commands
+	paths := manager.computePaths(cmd)
+
+	tests := []struct {
+		action string
+		run    func(*Command, *Paths) error
+		want   string
+		absent []string
+	}{
+		{"plan", manager.terraformPlan, fmt.Sprintf(`-out="%s" -compact-warnings -lock=false`, paths.PlanFile), []string{"-parallelism=20"}},
+		{"apply", manager.terraformApply, "-auto-approve -parallelism=20 -lock=false", []string{"-compact-warnings"}},
+		{"apply_plan", manager.terraformApplyPlan, "-lock=false", []string{"-compact-warnings", "-parallelism=20"}},
+		{"refresh", manager.terraformRefresh, "-lock=false", []string{"-compact-warnings", "-parallelism=20"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			cmd.Action = tt.action
+			cmd.ActionFlags = "-lock=false"
+
+			tt.run(cmd, paths)
+			ran := withoutPlanSummary(*commands)
+			if len(ran) == 0 || !strings.HasSuffix(ran[0], tt.want) {
+				t.Fatalf("Expected command ending in %q, got %v", tt.want, *commands)
+			}
+			for _, flag := range tt.absent {
+				if strings.Contains(ran[0], flag) {
+					t.Errorf("%s got another action's flag %s: %s", tt.action, flag, ran[0])
+				}
+			}
+		})
+	}
+}
+
+func TestConfiguredTerraformFlags(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	manager, cmd := setupInstance(t)
+	manager.config.GlobalTerraformFlags = []string{"-no-color"}
+	manager.config.ActionTerraformFlags = map[string][]string{
+		"plan": {"-compact-warnings"},
+	}
+	paths := manager.computePaths(cmd)
+
+	tests := []struct {
+		action string
+		run    func(*Command, *Paths) error
+		want   string
+	}{
+		{"init", manager.terraformInit, "terraform init -no-color -upgrade"},
+		{"plan", manager.terraformPlan, fmt.Sprintf(`-out="%s" -no-color -compact-warnings -upgrade`, paths.PlanFile)},
+		{"apply", manager.terraformApply, "-input=false -auto-approve -no-color -upgrade"},
+		{"import", manager.terraformImport, " -input=false -no-color -upgrade"},
+		{"output", manager.terraformOutput, "terraform output -no-color -upgrade"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			cmd.Action = tt.action
+			cmd.ActionFlags = "-upgrade"
+
+			tt.run(cmd, paths)
+			if len(withoutPlanSummary(*commands)) != 1 || !strings.HasSuffix((*commands)[0], tt.want) {
+				t.Errorf("Expected command ending in %q, got %v", tt.want, *commands)
+			}
+		})
+	}
+}
+
+func TestUnattendedAutoApprove(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	manager, cmd := setupInstance(t)
+	manager.SetOptions(Options{TerraformColor: true})
+	paths := manager.computePaths(cmd)
+	workspace := manager.generateWorkspace(cmd, paths)
+
+	tests := []struct {
+		action string
+		run    func(*Command, *Paths) error
+		want   string
+	}{
+		{"apply", manager.terraformApply, " -input=false -auto-approve"},
+		{"destroy", func(cmd *Command, paths *Paths) error { return manager.terraformDestroy(cmd, paths, workspace) }, " -auto-approve"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			cmd.Action = tt.action
+
+			tt.run(cmd, paths)
+			ran := withoutPlanSummary(*commands)
+			if len(ran) != 1 || !strings.HasSuffix(ran[0], tt.want) {
+				t.Errorf("Expected unattended %s to end in %q, got %v", tt.action, tt.want, ran)
+			}
+		})
+	}
+}
+
+func TestNoColorInjection(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+
+	tests := []struct {
+		name           string
+		unattended     bool
+		terraformColor bool
+		action         string
+		actionFlags    string
+		want           string
+	}{
+		{"Plan in CI", true, false, "plan", "", " -no-color"},
+		{"Apply in CI keeps action flags last", true, false, "apply", "-parallelism=5", " -no-color -parallelism=5"},
+		{"Console in CI is left alone", true, false, "console", "", ""},
+		{"Workspace in CI is left alone", true, false, "workspace", "list", " list"},
+		{"Not duplicated", true, false, "plan", "-no-color", " -no-color"},
+		{"Operator mode", false, false, "plan", "", ""},
+		{"Opted out", true, true, "plan", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unattended {
+				t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+			} else {
+				clearCIEnvVars()
+			}
+			manager.SetOptions(Options{TerraformColor: tt.terraformColor})
+
+			got := manager.actionFlags(&Command{Action: tt.action, ActionFlags: tt.actionFlags})
+			if got != tt.want {
+				t.Errorf("actionFlags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	for _, action := range []string{"plan", "apply_plan", "fmt", "format", "delete-workspace"} {
+		if err := ValidateAction(action); err != nil {
+			t.Errorf("ValidateAction(%q) unexpected error: %v", action, err)
+		}
+	}
+
+	tests := []struct {
+		action     string
+		suggestion string
+	}{
+		{"plna", "plan"},
+		{"aply", "apply"},
+		{"apply-plan", "apply_plan"},
+		{"destory", "destroy"},
+		{"frobnicate", ""},
+	}
+	for _, tt := range tests {
+		if got := SuggestAction(tt.action); got != tt.suggestion {
+			t.Errorf("SuggestAction(%q) = %q, want %q", tt.action, got, tt.suggestion)
+		}
+	}
+
+	err := ValidateAction("plna")
+	if code := exitCodeOf(t, err); code != ExitValidationFailed {
+		t.Errorf("exit code = %d, want %d", code, ExitValidationFailed)
+	}
+	if want := "unknown action 'plna'; did you mean 'plan'?"; err.Error() != want {
+		t.Errorf("ValidateAction() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	manager, _ := setupInstance(t)
+	fakeTerraformInstalled(t)
+	for _, module := range []string{"network", "broken", "storage"} {
+		if err := os.MkdirAll(filepath.Join(manager.config.GetModulePath(), module), 0755); err != nil {
+			t.Fatalf("Failed to create module %s: %v", module, err)
+		}
+	}
+
+	// Fail validation only when running inside the "broken" module
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		if filepath.Base(flags.Dir) == "broken" && strings.HasPrefix(command, "terraform validate") {
+			return &framework.CmdResult{ExitCode: 1, Error: "Error: Unsupported argument\n"}
+		}
+		return &framework.CmdResult{Success: true}
+	}
+	t.Cleanup(func() {
+		runCmd = original
+	})
+
+	var buf strings.Builder
+	err := manager.ValidateAll(&buf, "json")
+
+	exitErr, ok := err.(*ExitCodeError)
+	if !ok || exitErr.ExitCode == 0 {
+		t.Fatalf("Expected non-zero exit code error, got %v", err)
+	}
+
+	var results []ModuleValidation
+	if err := json.Unmarshal([]byte(buf.String()), &results); err != nil {
+		t.Fatalf("Invalid JSON summary: %v\n%s", err, buf.String())
+	}
+
+	expected := []ModuleValidation{
+		{Module: "broken", Valid: false, Stage: "validate", Error: "Error: Unsupported argument"},
+		{Module: "network", Valid: true},
+		{Module: "sample_module", Valid: true},
+		{Module: "storage", Valid: true},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("results = %+v, want %+v", results, expected)
+	}
+
+	// All valid modules result in success
+	if countInvalid(expected[1:]) != 0 {
+		t.Error("Expected no invalid modules")
+	}
+}
+
+func TestVerboseRun(t *testing.T) {
+	manager := NewManager(&config.Config{RepoName: "test-repo"})
+
+	var seen *framework.CmdFlags
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		seen = flags
+		// Run a harmless command but echo it as the real one would be
+		if flags.DisplayCommand == "" {
+			flags.DisplayCommand = command
+		}
+		return framework.RunCmd("true", message, flags, failMessage...)
+	}
+	t.Cleanup(func() {
+		runCmd = original
+	})
+
+	command := `terraform plan -var-file="dev.tfvars" -var 'db_password=hunter2' -var "api_key=abc123" -var 'note=it'"'"'s "sekrit"' -var=token=xyz`
+	quietFlags := func() *framework.CmdFlags {
+		flags := framework.DefaultCmdFlags()
+		flags.PrintMessage = false
+		flags.PrintStatus = false
+		return flags
+	}
+
+	// Not verbose: nothing printed
+	logged := captureStderr(t, func() {
+		manager.run(command, "Planning", quietFlags())
+	})
+	if seen.PrintCmd || strings.Contains(logged, "terraform plan") {
+		t.Errorf("Expected command not to be printed without verbose, got %q", logged)
+	}
+
+	// Verbose: exact command printed
+	manager.SetOptions(Options{Verbose: true})
+	logged = captureStderr(t, func() {
+		manager.run(command, "Planning", quietFlags())
+	})
+	if !seen.PrintCmd || !strings.Contains(logged, command) {
+		t.Errorf("Expected command to be printed when verbose, got %q", logged)
+	}
+
+	// Verbose with redaction: values hidden, names kept
+	manager.SetOptions(Options{Verbose: true, RedactVars: true})
+	logged = captureStderr(t, func() {
+		manager.run(command, "Planning", quietFlags())
+	})
+	for _, secret := range []string{"hunter2", "abc123", "sekrit", "xyz"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("Expected %q to be redacted, got %q", secret, logged)
+		}
+	}
+	for _, kept := range []string{`-var-file="dev.tfvars"`, "-var 'db_password=***'", `-var "api_key=***"`, "-var 'note=***'", "-var=token=***"} {
+		if !strings.Contains(logged, kept) {
+			t.Errorf("Expected %q in redacted command, got %q", kept, logged)
+		}
+	}
+}
+
+func TestDeleteWorkspace(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	cmd.Action = "delete-workspace"
+	workspaceName := "product1.test-repo.sample_module.dev.instance_x"
+
+	// fakeWorkspaceCmds records commands and reports activeWorkspace from workspace show
+	fakeWorkspaceCmds := func(t *testing.T, activeWorkspace string) *[]string {
+		t.Helper()
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			if command == "terraform workspace show" {
+				return &framework.CmdResult{Success: true, Output: activeWorkspace + "\n"}
+			}
+			return &framework.CmdResult{Success: true}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &commands
+	}
+
+	t.Run("Unattended without -force is refused", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		commands := fakeWorkspaceCmds(t, "default")
+		cmd.ActionFlags = ""
+
+		if err := manager.terraformDeleteWorkspace(cmd, workspaceName); err == nil {
+			t.Fatal("Expected error without -force in unattended mode")
+		}
+		if len(*commands) != 0 {
+			t.Errorf("Expected no terraform commands, got %v", *commands)
+		}
+	})
+
+	t.Run("Unattended with -force switches to default first", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		t.Setenv("TF_WORKSPACE", workspaceName)
+		commands := fakeWorkspaceCmds(t, "default")
+		cmd.ActionFlags = "-force"
+
+		err := manager.terraformDeleteWorkspace(cmd, workspaceName)
+		var exitErr *ExitCodeError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 0 {
+			t.Fatalf("Expected success exit code, got %v", err)
+		}
+		want := []string{
+			"terraform workspace select default",
+			"terraform workspace show",
+			"terraform workspace delete -force " + workspaceName,
+		}
+		if strings.Join(*commands, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Unexpected commands:\n%v\nwant:\n%v", *commands, want)
+		}
+		if got := envValue(manager.env, "TF_WORKSPACE"); got != "" {
+			t.Errorf("Expected TF_WORKSPACE to be cleared before selecting default, got %s", got)
+		}
+	})
+
+	t.Run("Still active workspace is refused", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		commands := fakeWorkspaceCmds(t, workspaceName)
+		cmd.ActionFlags = "-force"
+
+		if err := manager.terraformDeleteWorkspace(cmd, workspaceName); err == nil {
+			t.Fatal("Expected error when the workspace is still active")
+		}
+		for _, command := range *commands {
+			if strings.HasPrefix(command, "terraform workspace delete") {
+				t.Errorf("Unexpected delete command: %s", command)
+			}
+		}
+	})
+
+	t.Run("Default workspace is refused", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		commands := fakeWorkspaceCmds(t, "default")
+		cmd.ActionFlags = "-force"
+
+		if err := manager.terraformDeleteWorkspace(cmd, "default"); err == nil {
+			t.Fatal("Expected error when deleting the default workspace")
+		}
+		if len(*commands) != 0 {
+			t.Errorf("Expected no terraform commands, got %v", *commands)
+		}
+	})
+
+	t.Run("Operator must type the workspace name", func(t *testing.T) {
+		clearCIEnvVars()
+		os.Unsetenv("TF_EXEC_MODE_OVERRIDE")
+		cmd.ActionFlags = ""
+
+		commands := fakeWorkspaceCmds(t, "default")
+		manager.stdin = strings.NewReader("instance_x\n")
+		if err := manager.terraformDeleteWorkspace(cmd, workspaceName); err == nil {
+			t.Fatal("Expected error when confirmation does not match")
+		}
+		if len(*commands) != 0 {
+			t.Errorf("Expected no terraform commands, got %v", *commands)
+		}
+
+		manager.stdin = strings.NewReader(workspaceName + "\n")
+		if err := manager.terraformDeleteWorkspace(cmd, workspaceName); err != nil {
+			var exitErr *ExitCodeError
+			if !errors.As(err, &exitErr) || exitErr.ExitCode != 0 {
+				t.Fatalf("Expected success after confirmation, got %v", err)
+			}
+		}
+		if len(*commands) != 3 || (*commands)[2] != "terraform workspace delete "+workspaceName {
+			t.Errorf("Unexpected commands: %v", *commands)
+		}
+	})
+}
+
+func TestGuardProtectedEnv(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	manager.config.ProtectedEnvs = []string{"prod*"}
+
+	tests := []struct {
+		name       string
+		env        string
+		action     string
+		unattended bool
+		allow      string
+		input      string
+		wantErr    bool
+	}{
+		{name: "Unprotected env", env: "dev", action: "apply"},
+		{name: "Read-only action", env: "prod", action: "plan"},
+		{name: "Operator types env name", env: "prod-eu", action: "apply", input: "prod-eu\n"},
+		{name: "Operator types wrong name", env: "prod-eu", action: "destroy", input: "prod\n", wantErr: true},
+		{name: "Operator gives no answer", env: "prod", action: "import", wantErr: true},
+		{name: "Unattended without allow", env: "prod", action: "apply", unattended: true, wantErr: true},
+		{name: "Unattended with allow", env: "prod", action: "apply", unattended: true, allow: "1"},
+		{name: "Unattended raw without allow", env: "prod", action: "raw", unattended: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCIEnvVars()
+			os.Unsetenv("TF_EXEC_MODE_OVERRIDE")
+			if tt.unattended {
+				t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+			}
+			t.Setenv("TFM_ALLOW_PROTECTED", tt.allow)
+
+			cmd.Env = tt.env
+			cmd.Action = tt.action
+			manager.stdin = strings.NewReader(tt.input)
+
+			err := manager.guardProtectedEnv(cmd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("guardProtectedEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEnvOverrideResolution(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	base := manager.config
+	base.EnvOverrides = map[string]config.EnvOverride{
+		"prod": {ModuleRelPath: "terraform/modules-pinned", LockTimeout: "5m"},
+	}
+
+	manager.config = base.ForEnv("prod")
+	paths := manager.computePaths(cmd)
+	if want := filepath.Join(base.ProjectDir, "terraform/modules-pinned", "sample_module"); paths.ModulePath != want {
+		t.Errorf("prod ModulePath = %s, want %s", paths.ModulePath, want)
+	}
+
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+	if err := manager.terraformPlan(cmd, paths); err != nil {
+		var exitErr *ExitCodeError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode != 0 {
+			t.Fatalf("terraformPlan failed: %v", err)
+		}
+	}
+	if len(withoutPlanSummary(*commands)) != 1 || !strings.Contains((*commands)[0], " -lock-timeout=5m ") {
+		t.Errorf("Expected -lock-timeout in plan command: %v", *commands)
+	}
+
+	manager.config = base.ForEnv("dev")
+	if want := filepath.Join(base.ProjectDir, "terraform/modules", "sample_module"); manager.computePaths(cmd).ModulePath != want {
+		t.Errorf("dev ModulePath = %s, want %s", manager.computePaths(cmd).ModulePath, want)
+	}
+	if manager.lockTimeoutFlag() != "" {
+		t.Errorf("Expected no lock timeout for dev, got %q", manager.lockTimeoutFlag())
+	}
+}
+
+func TestDetectDrift(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	fakeTerraformInstalled(t)
+	moduleEnvPath := manager.computePaths(cmd).ModuleEnvPath
+	for _, name := range []string{"instance_y.tfvars", "instance_z.tfvars", "instance_x.tfvars.tfplan"} {
+		if err := os.WriteFile(filepath.Join(moduleEnvPath, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	// fakePlanExitCodes answers each instance's refresh-only plan with the given exit code
+	fakePlanExitCodes := func(t *testing.T, exitCodes map[string]int) *[]string {
+		t.Helper()
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			instance := strings.TrimPrefix(filepath.Ext(envValue(flags.Env, "TF_WORKSPACE")), ".")
+			exitCode := exitCodes[instance]
+			result := &framework.CmdResult{ExitCode: exitCode}
+			for _, valid := range flags.ValidExitCodes {
+				result.Success = result.Success || valid == exitCode
+			}
+			if !result.Success {
+				result.Error = "Error: state lock\n"
+			}
+			return result
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &commands
+	}
+
+	t.Run("Drift is reported with exit code 2", func(t *testing.T) {
+		commands := fakePlanExitCodes(t, map[string]int{"instance_x": 0, "instance_y": 2, "instance_z": 0})
+
+		var buf strings.Builder
+		err := manager.DetectDrift(&buf, "json", "product1", "sample_module", "dev", DriftOptions{})
+		exitErr, ok := err.(*ExitCodeError)
+		if !ok || exitErr.ExitCode != 2 {
+			t.Fatalf("Expected exit code 2, got %v", err)
+		}
+
+		var results []InstanceDrift
+		if err := json.Unmarshal([]byte(buf.String()), &results); err != nil {
+			t.Fatalf("Invalid JSON summary: %v\n%s", err, buf.String())
+		}
+		statuses := map[string]string{}
+		for _, result := range results {
+			statuses[result.Instance] = result.Status
+		}
+		expected := map[string]string{"instance_x": DriftInSync, "instance_y": DriftDrifted, "instance_z": DriftInSync}
+		if !reflect.DeepEqual(statuses, expected) {
+			t.Errorf("statuses = %v, want %v", statuses, expected)
+		}
+
+		if len(*commands) != 3 || !strings.Contains((*commands)[0], "-detailed-exitcode -refresh-only") {
+			t.Errorf("Unexpected commands: %v", *commands)
+		}
+	})
+
+	t.Run("All in sync succeeds", func(t *testing.T) {
+		fakePlanExitCodes(t, map[string]int{})
+
+		var buf strings.Builder
+		if err := manager.DetectDrift(&buf, "text", "product1", "sample_module", "dev", DriftOptions{}); err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+	})
+
+	t.Run("Failed check takes precedence over drift", func(t *testing.T) {
+		fakePlanExitCodes(t, map[string]int{"instance_y": 2, "instance_z": 1})
+
+		var buf strings.Builder
+		err := manager.DetectDrift(&buf, "text", "product1", "sample_module", "dev", DriftOptions{})
+		exitErr, ok := err.(*ExitCodeError)
+		if !ok || exitErr.ExitCode != 1 {
+			t.Fatalf("Expected exit code 1, got %v", err)
+		}
+		if !strings.Contains(buf.String(), "error") {
+			t.Errorf("Expected error status in summary: %s", buf.String())
+		}
+	})
+
+	// fakeChangedFiles answers git diff with files and records the instances that were planned
+	fakeChangedFiles := func(t *testing.T, files string, gitOK bool) *[]string {
+		t.Helper()
+		var planned []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			if strings.HasPrefix(command, "git ") {
+				if !strings.Contains(command, "'main...HEAD'") {
+					t.Errorf("Unexpected git command: %s", command)
+				}
+				return &framework.CmdResult{Success: gitOK, Output: files, Error: "fatal: bad revision\n"}
+			}
+			planned = append(planned, strings.TrimPrefix(filepath.Ext(envValue(flags.Env, "TF_WORKSPACE")), "."))
+			return &framework.CmdResult{Success: true}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &planned
+	}
+	changedOnly := DriftOptions{ChangedSince: "main"}
+
+	t.Run("Changed tfvars limits the instances checked", func(t *testing.T) {
+		planned := fakeChangedFiles(t, "terraform/environments/product1/dev/sample_module/instance_y.tfvars\nREADME.md\n", true)
+
+		var buf strings.Builder
+		if err := manager.DetectDrift(&buf, "json", "product1", "sample_module", "dev", changedOnly); err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		if !reflect.DeepEqual(*planned, []string{"instance_y"}) {
+			t.Errorf("planned = %v, want [instance_y]", *planned)
+		}
+	})
+
+	t.Run("Changed module checks every instance", func(t *testing.T) {
+		planned := fakeChangedFiles(t, "terraform/modules/sample_module/main.tf\n", true)
+
+		var buf strings.Builder
+		if err := manager.DetectDrift(&buf, "json", "product1", "sample_module", "dev", changedOnly); err != nil {
+			t.Fatalf("Expected success, got %v", err)
+		}
+		if len(*planned) != 3 {
+			t.Errorf("planned = %v, want every instance", *planned)
+		}
+	})
+
+	t.Run("Failed git diff is a validation error", func(t *testing.T) {
+		planned := fakeChangedFiles(t, "", false)
+
+		var buf strings.Builder
+		err := manager.DetectDrift(&buf, "json", "product1", "sample_module", "dev", changedOnly)
+		if code := exitCodeOf(t, err); code != ExitValidationFailed {
+			t.Errorf("Expected exit code %d, got %v", ExitValidationFailed, err)
+		}
+		if len(*planned) != 0 {
+			t.Errorf("Expected no plans, got %v", *planned)
+		}
+	})
+}
+
+func TestParseEnvFile(t *testing.T) {
+	input := `# deployment secrets
+
+export AWS_REGION=eu-west-1
+PLAIN = value with spaces   # trailing comment
+HASH=abc#def
+SINGLE='literal $HOME \n # kept'
+DOUBLE="line1\nline2 \"quoted\"" # comment
+EMPTY=
+EMPTY_QUOTED=""
+`
+	vars, err := parseEnvFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseEnvFile() error: %v", err)
+	}
+	want := []envVar{
+		{"AWS_REGION", "eu-west-1"},
+		{"PLAIN", "value with spaces"},
+		{"HASH", "abc#def"},
+		{"SINGLE", `literal $HOME \n # kept`},
+		{"DOUBLE", "line1\nline2 \"quoted\""},
+		{"EMPTY", ""},
+		{"EMPTY_QUOTED", ""},
+	}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("parseEnvFile() =\n%q\nwant\n%q", vars, want)
+	}
+
+	for _, bad := range []string{"NO_EQUALS", "1BAD=x", "OPEN=\"unterminated", "OPEN='unterminated", "TRAIL=\"x\" y"} {
+		if _, err := parseEnvFile(strings.NewReader(bad + "\n")); err == nil {
+			t.Errorf("parseEnvFile(%q) expected an error", bad)
+		} else if strings.Contains(err.Error(), "unterminated\"") {
+			t.Errorf("parseEnvFile(%q) error leaks the value: %v", bad, err)
+		}
+	}
+}
+
+func TestEnvFile(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	fakeTerraformInstalled(t)
+	t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	t.Setenv("TFM_TEST_SECRET", "")
+	cmd.Action = "plan"
+
+	envFile := filepath.Join(t.TempDir(), "dev.env")
+	if err := os.WriteFile(envFile, []byte("# dev secrets\nTFM_TEST_SECRET=\"s3cr3t value\"\n"), 0600); err != nil {
+		t.Fatalf("Failed to write env file: %v", err)
+	}
+	manager.SetOptions(Options{EnvFile: envFile})
+
+	// Record what terraform plan would see in its environment
+	var seen string
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		if strings.HasPrefix(command, "terraform plan") {
+			seen = envValue(flags.Env, "TFM_TEST_SECRET")
+		}
+		return &framework.CmdResult{Success: true}
+	}
+	t.Cleanup(func() { runCmd = original })
+
+	var err error
+	output := captureStderr(t, func() { err = manager.Execute(cmd) })
+	if exitCodeOf(t, err) != 0 {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if seen != "s3cr3t value" {
+		t.Errorf("terraform plan saw TFM_TEST_SECRET=%q, want the env file value", seen)
+	}
+	if got := os.Getenv("TFM_TEST_SECRET"); got != "" {
+		t.Errorf("Expected tf-manage's own environment to be left alone, got TFM_TEST_SECRET=%q", got)
+	}
+	if !strings.Contains(output, "TFM_TEST_SECRET") || strings.Contains(output, "s3cr3t") {
+		t.Errorf("Expected the variable name but not its value in the output:\n%s", output)
+	}
+
+	manager.SetOptions(Options{EnvFile: filepath.Join(t.TempDir(), "missing.env")})
+	if code := exitCodeOf(t, manager.Execute(cmd)); code != ExitValidationFailed {
+		t.Errorf("Missing env file exit code = %d, want %d", code, ExitValidationFailed)
+	}
+}
+
+func TestJSONVarFile(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	moduleEnvPath := manager.computePaths(cmd).ModuleEnvPath
+	hclFile := filepath.Join(moduleEnvPath, "instance_x.tfvars")
+	jsonFile := filepath.Join(moduleEnvPath, "instance_x.tfvars.json")
+
+	tests := []struct {
+		name    string
+		files   []string
+		varFile string
+	}{
+		{"HCL only", []string{hclFile}, hclFile},
+		{"JSON only", []string{jsonFile}, jsonFile},
+		{"JSON preferred over HCL", []string{hclFile, jsonFile}, jsonFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(hclFile)
+			os.Remove(jsonFile)
+			for _, file := range tt.files {
+				content := "instance_count = 1\n"
+				if strings.HasSuffix(file, ".json") {
+					content = `{"instance_count": 1}`
+				}
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", file, err)
+				}
+			}
+
+			if varFile := manager.computePaths(cmd).VarFile; varFile != tt.varFile {
+				t.Errorf("VarFile = %s, want %s", varFile, tt.varFile)
+			}
+			if err := manager.validateCommand(cmd); err != nil {
+				t.Errorf("validateCommand() error: %v", err)
+			}
+			instances, err := manager.listInstances(cmd.Product, cmd.Module, cmd.Env)
+			if err != nil || !reflect.DeepEqual(instances, []string{"instance_x"}) {
+				t.Errorf("listInstances() = %v (err %v), want [instance_x]", instances, err)
+			}
+
+			commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+			if code := exitCodeOf(t, manager.terraformPlan(cmd, manager.computePaths(cmd))); code != 0 {
+				t.Fatalf("plan exit code = %d", code)
+			}
+			if !strings.Contains((*commands)[0], fmt.Sprintf("-var-file=\"%s\"", tt.varFile)) {
+				t.Errorf("plan command %q does not use %s", (*commands)[0], tt.varFile)
+			}
+		})
+	}
+}
+
+func TestCheckVarFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		syntax  bool
+		wantErr string
+	}{
+		{"empty", "a.tfvars", "", false, "is empty"},
+		{"whitespace only", "a.tfvars", "  \n\t\n", false, "is empty"},
+		{"comments skipped without syntax check", "a.tfvars", "# nothing\n", false, ""},
+		{"invalid accepted without syntax check", "a.tfvars", "name = {\n", false, ""},
+		{"simple values", "a.tfvars", "name = \"web\"\ncount = 2\nenabled = true\n", true, ""},
+		{"list and map", "a.tfvars", "zones = [\"a\", \"b\"]\ntags = {\n  team = \"ops\" # owner\n  \"cost-center\" = 42\n}\n", true, ""},
+		{"comments", "a.tfvars", "// header\n/* block\n comment */\nname = \"x\" # trailing\n", true, ""},
+		{"heredoc", "a.tfvars", "policy = <<-EOF\n  { \"unbalanced\": [\n  EOF\nname = \"x\"\n", true, ""},
+		{"escaped quote", "a.tfvars", "name = \"say \\\"hi\\\"\"\n", true, ""},
+		{"unclosed brace", "a.tfvars", "tags = {\n  team = \"ops\"\n", true, "not valid HCL"},
+		{"missing equals", "a.tfvars", "name \"web\"\n", true, "not valid HCL"},
+		{"missing value", "a.tfvars", "name =\n", true, "not valid HCL"},
+		{"unterminated string", "a.tfvars", "name = \"web\n", true, "not valid HCL"},
+		{"unterminated heredoc", "a.tfvars", "policy = <<EOF\nbody\n", true, "not valid HCL"},
+		{"valid json", "a.tfvars.json", "{\"name\": \"web\"}\n", true, ""},
+		{"invalid json", "a.tfvars.json", "{\"name\": }\n", true, "not valid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write var file: %v", err)
+			}
+			err := checkVarFile(path, tt.syntax)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkVarFile() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkVarFile() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmptyVarFileFailsValidation(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	fakeRunCmd(t, &framework.CmdResult{Success: true})
+	cmd.Action = "plan"
+
+	paths := manager.computePaths(cmd)
+	if err := os.WriteFile(paths.VarFile, []byte("\n"), 0644); err != nil {
+		t.Fatalf("Failed to empty var file: %v", err)
+	}
+
+	var err error
+	output := captureStderr(t, func() { err = manager.validateCommand(cmd) })
+	if code := exitCodeOf(t, err); code != ExitValidationFailed {
+		t.Errorf("validateCommand() exit code = %d, want %d", code, ExitValidationFailed)
+	}
+	if !strings.Contains(output, "is empty") {
+		t.Errorf("Expected an empty tfvars error, got:\n%s", output)
+	}
+}
+
+func TestApprovalCommand(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	// The stub approves only when it sees the expected target in its environment
+	dir := t.TempDir()
+	approve := filepath.Join(dir, "approve.sh")
+	if err := os.WriteFile(approve, []byte(`[ "$TFM_ACTION" = apply ] && [ "$TFM_ENV" = dev ] && [ -n "$TFM_WORKSPACE" ] && exit 0
+exit 1
+`), 0755); err != nil {
+		t.Fatalf("Failed to write approval stub: %v", err)
+	}
+	deny := filepath.Join(dir, "deny.sh")
+	if err := os.WriteFile(deny, []byte("echo 'CHG0001 rejected'\nexit 3\n"), 0755); err != nil {
+		t.Fatalf("Failed to write approval stub: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		command     string
+		action      string
+		unattended  bool
+		wantCode    int
+		wantApplied bool
+	}{
+		{"Approved apply", "sh " + approve, "apply", true, 0, true},
+		{"Denied apply", "sh " + deny, "apply", true, ExitApprovalDenied, false},
+		{"Denied destroy", "sh " + deny, "destroy", true, ExitApprovalDenied, false},
+		{"Denied raw", "sh " + deny, "raw", true, ExitApprovalDenied, false},
+		{"Context mismatch denies", "sh " + approve, "destroy", true, ExitApprovalDenied, false},
+		{"Plan skips approval", "sh " + deny, "plan", true, 0, true},
+		{"Operator mode skips approval", "sh " + deny, "apply", false, 0, true},
+		{"No approval command", "", "apply", true, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			fakeTerraformInstalled(t)
+			clearCIEnvVars()
+			t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+			t.Setenv("TFM_ACTION", "")
+			if tt.unattended {
+				t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+			}
+			manager.config.ApprovalCommand = tt.command
+			cmd.Action = tt.action
+
+			// Run the approval stub for real and fake terraform
+			applied := false
+			original := runCmd
+			runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+				if strings.HasPrefix(command, "sh ") {
+					// The target reaches the approval command only through its own environment
+					if os.Getenv("TFM_ACTION") != "" {
+						t.Errorf("TFM_ACTION exported to the tf process: %q", os.Getenv("TFM_ACTION"))
+					}
+					flags.PrintOutput = false
+					return framework.RunCmd(command, message, flags, failMessage...)
+				}
+				if strings.HasPrefix(command, "terraform "+tt.action) {
+					applied = true
+				}
+				return &framework.CmdResult{Success: true}
+			}
+			t.Cleanup(func() { runCmd = original })
+
+			var err error
+			captureStderr(t, func() { err = manager.Execute(cmd) })
+			if code := exitCodeOf(t, err); code != tt.wantCode {
+				t.Errorf("Execute() exit code = %d, want %d (%v)", code, tt.wantCode, err)
+			}
+			if applied != tt.wantApplied {
+				t.Errorf("terraform %s ran = %v, want %v", tt.action, applied, tt.wantApplied)
+			}
+			if os.Getenv("TFM_ACTION") != "" {
+				t.Errorf("TFM_ACTION leaked after the approval command: %q", os.Getenv("TFM_ACTION"))
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	manager.config.WorkspacePrefix = "team"
+	paths := manager.computePaths(cmd)
+	workspace := manager.generateWorkspace(cmd, paths)
+
+	var buf strings.Builder
+	if err := manager.Inspect(&buf, cmd, true); err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	var got Inspection
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("Inspect() printed invalid JSON: %v\n%s", err, buf.String())
+	}
+	want := Inspection{
+		Product:       cmd.Product,
+		Module:        cmd.Module,
+		Env:           cmd.Env,
+		Instance:      cmd.ModuleInstance,
+		ModulePath:    paths.ModulePath,
+		EnvPath:       paths.EnvPath,
+		ModuleEnvPath: paths.ModuleEnvPath,
+		VarFile:       paths.VarFile,
+		PlanFile:      paths.PlanFile,
+		Workspace:     workspace,
+	}
+	if got != want || !strings.HasPrefix(got.Workspace, "team.") {
+		t.Errorf("Inspect() JSON = %+v, want %+v", got, want)
+	}
+
+	buf.Reset()
+	if err := manager.Inspect(&buf, cmd, false); err != nil {
+		t.Fatalf("Inspect() error = %v", err)
+	}
+	output := buf.String()
+	for _, line := range []string{
+		"Var file:         " + paths.VarFile + "\n",
+		"Plan file:        " + paths.PlanFile + " (missing)\n",
+		"Workspace:        " + workspace + "\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected %q in text output:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, paths.VarFile+" (missing)") {
+		t.Errorf("Existing var file marked missing:\n%s", output)
+	}
+}
+
+func TestParseWorkspace(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		workspace string
+		want      Command
+		wantOK    bool
+	}{
+		{"Plain", "", "product1.test-repo.sample_module.dev.instance_x",
+			Command{Product: "product1", Module: "sample_module", Env: "dev", ModuleInstance: "instance_x"}, true},
+		{"Nested env", "", "product1.test-repo.sample_module.eu__prod.instance_x",
+			Command{Product: "product1", Module: "sample_module", Env: "eu/prod", ModuleInstance: "instance_x"}, true},
+		{"Prefixed", "team", "team.product1.test-repo.sample_module.dev.instance_x",
+			Command{Product: "product1", Module: "sample_module", Env: "dev", ModuleInstance: "instance_x"}, true},
+		{"Missing prefix", "team", "product1.test-repo.sample_module.dev.instance_x", Command{}, false},
+		{"Default workspace", "", "default", Command{}, false},
+		{"Too few segments", "", "product1.test-repo.sample_module.dev", Command{}, false},
+		{"Too many segments", "", "product1.test-repo.sample_module.dev.instance_x.old", Command{}, false},
+		{"Empty segment", "", "product1.test-repo..dev.instance_x", Command{}, false},
+		{"Other repository", "", "product1.other-repo.sample_module.dev.instance_x", Command{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(&config.Config{RepoName: "test-repo", WorkspacePrefix: tt.prefix})
+			got, ok := manager.parseWorkspace(tt.workspace)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWorkspace(%q) = %+v, %v; want %+v, %v", tt.workspace, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	// Parsing undoes generateWorkspace
+	manager := NewManager(&config.Config{RepoName: "test-repo", WorkspacePrefix: "team"})
+	cmd := &Command{Product: "product1", Module: "sample_module", Env: "eu/prod", ModuleInstance: "instance_x"}
+	got, ok := manager.parseWorkspace(manager.generateWorkspace(cmd, manager.computePaths(cmd)))
+	if !ok || !reflect.DeepEqual(got, *cmd) {
+		t.Errorf("Round trip = %+v, %v; want %+v", got, ok, *cmd)
+	}
+}
+
+func TestWorkspaceEnvSeparator(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		env       string
+		workspace string
+	}{
+		{"Default separator", "", "eu/prod", "product1.test-repo.sample_module.eu__prod.instance_x"},
+		{"Deeply nested env", "", "eu/west/prod", "product1.test-repo.sample_module.eu__west__prod.instance_x"},
+		{"Custom separator", "--", "eu/prod", "product1.test-repo.sample_module.eu--prod.instance_x"},
+		{"Custom separator keeps double underscores", "--", "eu/prod__blue", "product1.test-repo.sample_module.eu--prod__blue.instance_x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(&config.Config{RepoName: "test-repo", WorkspaceEnvSeparator: tt.separator})
+			cmd := &Command{Product: "product1", Module: "sample_module", Env: tt.env, ModuleInstance: "instance_x"}
+			workspace := manager.generateWorkspace(cmd, manager.computePaths(cmd))
+			if workspace != tt.workspace {
+				t.Errorf("generateWorkspace() = %q, want %q", workspace, tt.workspace)
+			}
+			got, ok := manager.parseWorkspace(workspace)
+			if !ok || !reflect.DeepEqual(got, *cmd) {
+				t.Errorf("parseWorkspace(%q) = %+v, %v; want %+v", workspace, got, ok, *cmd)
+			}
+		})
+	}
+
+	// An env containing the separator could not be told apart from a nested env
+	for _, tt := range []struct{ separator, env string }{{"", "blue__green"}, {"--", "blue--green"}} {
+		manager, cmd := setupInstance(t)
+		manager.config.WorkspaceEnvSeparator = tt.separator
+		cmd.Env = tt.env
+		paths := manager.computePaths(cmd)
+		if err := os.MkdirAll(paths.ModuleEnvPath, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", paths.ModuleEnvPath, err)
+		}
+
+		var err error
+		output := captureStderr(t, func() { err = manager.validateCommand(cmd) })
+		if code := exitCodeOf(t, err); code != ExitValidationFailed {
+			t.Errorf("validateCommand(env %q) exit code = %d, want %d", tt.env, code, ExitValidationFailed)
+		}
+		if !strings.Contains(output, "workspace_env_separator") {
+			t.Errorf("Expected guidance about workspace_env_separator, got:\n%s", output)
+		}
+	}
+}
+
+func TestListWorkspaces(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	fakeTerraformInstalled(t)
+	t.Setenv("TF_WORKSPACE", "product1.test-repo.sample_module.dev.instance_x")
+	var commands []string
+	var listEnv []string
+	original := runCmd
+	runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		commands = append(commands, command)
+		listEnv = flags.Env
+		return &framework.CmdResult{
+			Success: true,
+			Output:  "* default\n  product1.test-repo.sample_module.dev.instance_x\n  product1.test-repo.sample_module.eu__prod.instance_y\n  scratch\n",
+		}
+	}
+	t.Cleanup(func() { runCmd = original })
+
+	var buf strings.Builder
+	var err error
+	output := captureStderr(t, func() { err = manager.ListWorkspaces(&buf, "text", cmd.Module) })
+	if err != nil {
+		t.Fatalf("ListWorkspaces() error = %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "terraform workspace list" {
+		t.Errorf("Expected a single terraform workspace list, got %v", commands)
+	}
+	if got := envValue(listEnv, "TF_WORKSPACE"); got != "" {
+		t.Errorf("Expected TF_WORKSPACE to be cleared before listing, got %s", got)
+	}
+
+	want := `Workspaces
+product   module         env      instance    workspace
+-         -              -        -           default (not tf-manage)
+product1  sample_module  dev      instance_x  product1.test-repo.sample_module.dev.instance_x
+product1  sample_module  eu/prod  instance_y  product1.test-repo.sample_module.eu__prod.instance_y
+-         -              -        -           scratch (not tf-manage)
+`
+	if buf.String() != want {
+		t.Errorf("ListWorkspaces() table:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if !strings.Contains(output, "1 workspace(s) do not follow") {
+		t.Errorf("Expected the unmanaged workspace to be reported, got:\n%s", output)
+	}
+
+	if code := exitCodeOf(t, manager.ListWorkspaces(&buf, "text", "no_such_module")); code != ExitValidationFailed {
+		t.Errorf("Unknown module exit code = %d, want %d", code, ExitValidationFailed)
+	}
+}
+
+func TestExecuteWithoutTerraform(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	cmd.Action = "plan"
+
+	original := lookPath
+	lookPath = func(file string) (string, error) {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	t.Cleanup(func() { lookPath = original })
+
+	commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+
+	var err error
+	stderr := captureStderr(t, func() {
+		err = manager.Execute(cmd)
+	})
+	if err == nil || !strings.Contains(err.Error(), "install terraform") {
+		t.Fatalf("Expected actionable install error, got %v", err)
+	}
+	if !strings.Contains(stderr, "terraform was not found on PATH") {
+		t.Errorf("Expected a single clear error line, got: %s", stderr)
+	}
+	if len(*commands) != 0 {
+		t.Errorf("Expected no terraform commands, got %v", *commands)
+	}
+}
+
+func TestExitCodeCategories(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	paths := manager.computePaths(cmd)
+
+	t.Run("Missing product is a validation failure", func(t *testing.T) {
+		missing := *cmd
+		missing.Product = "no_such_product"
+		if code := exitCodeOf(t, manager.validateCommand(&missing)); code != ExitValidationFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitValidationFailed)
+		}
+	})
+
+	t.Run("Missing config is a validation failure", func(t *testing.T) {
+		missing := *cmd
+		missing.ModuleInstance = "no_such_instance"
+		if code := exitCodeOf(t, manager.validateCommand(&missing)); code != ExitValidationFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitValidationFailed)
+		}
+	})
+
+	t.Run("Valid command passes validation", func(t *testing.T) {
+		if err := manager.validateCommand(cmd); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Workspace select failure", func(t *testing.T) {
+		fakeRunCmd(t, &framework.CmdResult{Success: false, ExitCode: 1})
+		if code := exitCodeOf(t, manager.ensureWorkspace("ws")); code != ExitWorkspaceFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitWorkspaceFailed)
+		}
+	})
+
+	t.Run("Workspace creation failure", func(t *testing.T) {
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			if command == "terraform workspace select ws" {
+				return &framework.CmdResult{Success: false, ExitCode: 1, Error: workspaceMissing}
+			}
+			return &framework.CmdResult{Success: false, ExitCode: 1}
+		}
+		t.Cleanup(func() { runCmd = original })
+
+		if code := exitCodeOf(t, manager.ensureWorkspace("ws")); code != ExitWorkspaceFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitWorkspaceFailed)
+		}
+	})
+
+	t.Run("Workspace created concurrently", func(t *testing.T) {
+		// Another job creates the workspace between our select and our workspace new
+		selects, lists := 0, 0
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			switch command {
+			case "terraform workspace select ws":
+				selects++
+				if selects == 1 {
+					return &framework.CmdResult{Success: false, ExitCode: 1, Error: workspaceMissing}
+				}
+				return &framework.CmdResult{Success: true}
+			case "terraform workspace list":
+				lists++
+				return &framework.CmdResult{Success: true, Output: "* default\n  ws\n"}
+			}
+			return &framework.CmdResult{Success: false, ExitCode: 1, Error: "Workspace \"ws\" already exists\n"}
+		}
+		t.Cleanup(func() { runCmd = original })
+
+		if err := manager.ensureWorkspace("ws"); err != nil {
+			t.Errorf("Expected a concurrently created workspace to be used, got %v", err)
+		}
+		if lists != 1 {
+			t.Errorf("Expected the workspace list to be re-checked, listed %d times", lists)
+		}
+		if selects != 2 {
+			t.Errorf("Expected the workspace to be selected after creation, selected %d times", selects)
+		}
+	})
+
+	t.Run("Already exists but not listed", func(t *testing.T) {
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			switch command {
+			case "terraform workspace select ws":
+				return &framework.CmdResult{Success: false, ExitCode: 1, Error: workspaceMissing}
+			case "terraform workspace list":
+				return &framework.CmdResult{Success: true, Output: "* default\n"}
+			}
+			return &framework.CmdResult{Success: false, ExitCode: 1, Error: "Workspace \"ws\" already exists\n"}
+		}
+		t.Cleanup(func() { runCmd = original })
+
+		if code := exitCodeOf(t, manager.ensureWorkspace("ws")); code != ExitWorkspaceFailed {
+			t.Errorf("exit code = %d, want %d", code, ExitWorkspaceFailed)
+		}
+	})
+
+	t.Run("Terraform failures keep terraform's exit code", func(t *testing.T) {
+		fakeRunCmd(t, &framework.CmdResult{Success: false, ExitCode: 3})
+		if code := exitCodeOf(t, manager.terraformPlan(cmd, paths)); code != 3 {
+			t.Errorf("exit code = %d, want 3", code)
+		}
+	})
+}
+
+func TestPlanSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		planJSON string
+		want     PlanSummary
+	}{
+		{"No changes", `{"format_version":"1.2","resource_changes":[{"address":"aws_s3_bucket.b","change":{"actions":["no-op"]}}]}`, PlanSummary{}},
+		{"No resources", `{"format_version":"1.2"}`, PlanSummary{}},
+		{"Mixed changes", `{"resource_changes":[
+			{"address":"aws_instance.a","change":{"actions":["create"]}},
+			{"address":"aws_instance.b","change":{"actions":["create"]}},
+			{"address":"aws_instance.c","change":{"actions":["update"]}},
+			{"address":"aws_instance.d","change":{"actions":["delete"]}},
+			{"address":"data.aws_ami.e","change":{"actions":["read"]}}]}`, PlanSummary{Add: 2, Change: 1, Destroy: 1}},
+		{"Replacement counts as add and destroy", `{"resource_changes":[
+			{"address":"aws_instance.a","change":{"actions":["delete","create"]}},
+			{"address":"aws_instance.b","change":{"actions":["create","delete"]}}]}`, PlanSummary{Add: 2, Destroy: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlanSummary([]byte(tt.planJSON))
+			if err != nil {
+				t.Fatalf("parsePlanSummary() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parsePlanSummary() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parsePlanSummary([]byte("Error: no plan")); err == nil {
+		t.Error("Expected an error for output that is not JSON")
+	}
+
+	t.Run("Printed after a successful plan", func(t *testing.T) {
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		fakeRunCmd(t, &framework.CmdResult{Success: true, Output: `{"resource_changes":[{"change":{"actions":["create"]}}]}`})
+
+		stderr := captureStderr(t, func() {
+			if err := manager.terraformPlan(cmd, paths); exitCodeOf(t, err) != 0 {
+				t.Errorf("terraformPlan() error: %v", err)
+			}
+		})
+		if got := regexp.MustCompile(`\x1b\[[0-9;]*m`).ReplaceAllString(stderr, ""); !strings.Contains(got, "Plan: 1 to add, 0 to change, 0 to destroy.") {
+			t.Errorf("Expected the plan summary, got %q", got)
+		}
+	})
+}
+
+func TestSkipValidation(t *testing.T) {
+	checks := 0
+	original := runNative
+	runNative = func(nativeFunc framework.NativeFunc, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+		checks++
+		return original(nativeFunc, message, flags, failMessage...)
+	}
+	t.Cleanup(func() { runNative = original })
+
+	manager, cmd := setupInstance(t)
+	cmd.Product = "missing_product"
+
+	if code := exitCodeOf(t, manager.validateCommand(cmd)); code != ExitValidationFailed || checks != 1 {
+		t.Errorf("Without the option: exit code = %d after %d checks, want %d after 1", code, checks, ExitValidationFailed)
+	}
+
+	checks = 0
+	manager.SetOptions(Options{SkipValidation: true})
+	if err := manager.validateCommand(cmd); err != nil || checks != 0 {
+		t.Errorf("With SkipValidation: err = %v after %d checks, want nil after none", err, checks)
+	}
+}
+
+func TestTimings(t *testing.T) {
+	manager, cmd := setupInstance(t)
+	fakeTerraformInstalled(t)
+	fakeRunCmd(t, &framework.CmdResult{Success: true, Output: "* default\n"})
+	cmd.Action = "plan"
+
+	t.Run("Off by default", func(t *testing.T) {
+		planCmd := *cmd
+		captureStderr(t, func() {
+			if err := manager.Execute(&planCmd); exitCodeOf(t, err) != 0 {
+				t.Errorf("Execute() error: %v", err)
+			}
+		})
+		if timings := manager.RunInfo().Timings; len(timings) != 0 {
+			t.Errorf("Expected no timings, got %v", timings)
+		}
+	})
+
+	t.Run("Each phase is recorded", func(t *testing.T) {
+		manager.SetOptions(Options{Timings: true})
+		planCmd := *cmd
+		stderr := captureStderr(t, func() {
+			if err := manager.Execute(&planCmd); exitCodeOf(t, err) != 0 {
+				t.Errorf("Execute() error: %v", err)
+			}
+		})
+
+		var phases []string
+		for _, timing := range manager.RunInfo().Timings {
+			phases = append(phases, timing.Phase)
+			if timing.Duration < 0 {
+				t.Errorf("Phase %s has a negative duration %s", timing.Phase, timing.Duration)
+			}
+		}
+		if want := []string{"validate", "workspace", "terraform plan"}; !reflect.DeepEqual(phases, want) {
+			t.Errorf("phases = %v, want %v", phases, want)
+		}
+		if !strings.Contains(stderr, "Timings:") || !strings.Contains(stderr, "total") {
+			t.Errorf("Expected a timing table, got %q", stderr)
+		}
+	})
+}
+
+func TestExpandEnvPattern(t *testing.T) {
+	manager, _ := setupInstance(t)
+	productPath := filepath.Join(manager.config.GetEnvPath(), "product1")
+	for _, dir := range []string{"prod/us-east-1/sample_module", "prod/eu-west-1/sample_module", "prod/ap-south-1/other_module", "staging/sample_module"} {
+		if err := os.MkdirAll(filepath.Join(productPath, dir), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"prod/*", []string{"prod/eu-west-1", "prod/us-east-1"}},
+		{"prod/us-*", []string{"prod/us-east-1"}},
+		{"*", []string{"dev", "staging"}},
+	}
+	for _, tt := range tests {
+		envs, err := manager.ExpandEnvPattern("product1", "sample_module", tt.pattern)
+		if err != nil || !reflect.DeepEqual(envs, tt.want) {
+			t.Errorf("ExpandEnvPattern(%q) = %v, %v; want %v", tt.pattern, envs, err, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"qa/*", "prod/["} {
+		if _, err := manager.ExpandEnvPattern("product1", "sample_module", pattern); exitCodeOf(t, err) != ExitValidationFailed {
+			t.Errorf("ExpandEnvPattern(%q) error = %v, want exit code %d", pattern, err, ExitValidationFailed)
+		}
+	}
+
+	if !IsEnvPattern("prod/*") || IsEnvPattern("prod/us-east-1") {
+		t.Error("IsEnvPattern should only match globs")
+	}
+}
+
+func TestDetectCI(t *testing.T) {
+	// Clear the markers of the CI system running these tests
+	for _, key := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "BITBUCKET_BUILD_NUMBER", "CIRCLECI", "BUILDKITE", "TF_BUILD", "JENKINS_URL"} {
+		t.Setenv(key, "")
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *CIInfo
+	}{
+		{"Not in CI", nil, nil},
+		{
+			name: "GitHub Actions",
+			env: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_REPOSITORY": "acme/infra", "GITHUB_SHA": "abc123", "GITHUB_RUN_ID": "42",
+				"GITHUB_SERVER_URL": "https://github.com", "GITHUB_JOB": "deploy"},
+			want: &CIInfo{Provider: "github", Repository: "acme/infra", Commit: "abc123", RunID: "42", RunURL: "https://github.com/acme/infra/actions/runs/42", Job: "deploy"},
+		},
+		{
+			name: "GitLab CI",
+			env: map[string]string{"GITLAB_CI": "true", "CI_PROJECT_PATH": "acme/infra", "CI_COMMIT_SHA": "abc123", "CI_PIPELINE_ID": "7",
+				"CI_JOB_URL": "https://gitlab.com/acme/infra/-/jobs/99", "CI_JOB_NAME": "apply"},
+			want: &CIInfo{Provider: "gitlab", Repository: "acme/infra", Commit: "abc123", RunID: "7", RunURL: "https://gitlab.com/acme/infra/-/jobs/99", Job: "apply"},
+		},
+		{
+			name: "Bitbucket Pipelines",
+			env:  map[string]string{"BITBUCKET_BUILD_NUMBER": "15", "BITBUCKET_REPO_FULL_NAME": "acme/infra", "BITBUCKET_COMMIT": "abc123", "BITBUCKET_STEP_UUID": "{step}"},
+			want: &CIInfo{Provider: "bitbucket", Repository: "acme/infra", Commit: "abc123", RunID: "15", RunURL: "https://bitbucket.org/acme/infra/pipelines/results/15", Job: "{step}"},
+		},
+		{
+			name: "CircleCI",
+			env: map[string]string{"CIRCLECI": "true", "CIRCLE_PROJECT_USERNAME": "acme", "CIRCLE_PROJECT_REPONAME": "infra", "CIRCLE_SHA1": "abc123",
+				"CIRCLE_WORKFLOW_ID": "wf", "CIRCLE_BUILD_URL": "https://circleci.com/gh/acme/infra/3", "CIRCLE_JOB": "apply"},
+			want: &CIInfo{Provider: "circleci", Repository: "acme/infra", Commit: "abc123", RunID: "wf", RunURL: "https://circleci.com/gh/acme/infra/3", Job: "apply"},
+		},
+		{
+			name: "Azure Pipelines",
+			env: map[string]string{"TF_BUILD": "True", "BUILD_REPOSITORY_NAME": "infra", "BUILD_SOURCEVERSION": "abc123", "BUILD_BUILDID": "8",
+				"SYSTEM_COLLECTIONURI": "https://dev.azure.com/acme/", "SYSTEM_TEAMPROJECT": "ops"},
+			want: &CIInfo{Provider: "azure", Repository: "infra", Commit: "abc123", RunID: "8", RunURL: "https://dev.azure.com/acme/ops/_build/results?buildId=8"},
+		},
+		{
+			name: "Jenkins",
+			env:  map[string]string{"JENKINS_URL": "https://ci.acme.dev/", "GIT_COMMIT": "abc123", "BUILD_NUMBER": "5", "BUILD_URL": "https://ci.acme.dev/job/infra/5/", "JOB_NAME": "infra"},
+			want: &CIInfo{Provider: "jenkins", Commit: "abc123", RunID: "5", RunURL: "https://ci.acme.dev/job/infra/5/", Job: "infra"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if got := detectCI(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("detectCI() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Recorded in run info", func(t *testing.T) {
+		t.Setenv("GITHUB_ACTIONS", "true")
+		t.Setenv("GITHUB_SHA", "abc123")
+		manager, cmd := setupInstance(t)
+		fakeRunCmd(t, &framework.CmdResult{Success: true})
+		fakeTerraformInstalled(t)
+		cmd.Action = "plan"
+		manager.Execute(cmd)
+
+		if ci := manager.RunInfo().CI; ci == nil || ci.Provider != "github" || ci.Commit != "abc123" {
+			t.Errorf("RunInfo().CI = %+v, want the GitHub run", ci)
+		}
+	})
+}
+
+func TestDeploy(t *testing.T) {
+	// fakeDeployCmds records commands and fails terraform plan with planExit
+	fakeDeployCmds := func(t *testing.T, planExit int) *[]string {
+		t.Helper()
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			if strings.HasPrefix(command, "terraform plan") && planExit != 0 {
+				return &framework.CmdResult{Success: false, ExitCode: planExit}
+			}
+			return &framework.CmdResult{Success: true}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &commands
+	}
+
+	t.Run("Plan then apply the plan file", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		commands := fakeDeployCmds(t, 0)
+		cmd.Action = "deploy"
+		cmd.ActionFlags = "-target=aws_s3_bucket.logs"
+
+		if code := exitCodeOf(t, manager.terraformDeploy(cmd, paths, "ws")); code != 0 {
+			t.Fatalf("exit code = %d, want 0", code)
+		}
+		ran := withoutPlanSummary(*commands)
+		if len(ran) != 2 || !strings.HasPrefix(ran[0], "terraform plan") || !strings.HasPrefix(ran[1], "terraform apply") {
+			t.Fatalf("Expected plan then apply, got %v", ran)
+		}
+		if !strings.Contains(ran[0], `-out="`+paths.PlanFile+`"`) || !strings.Contains(ran[1], `"`+paths.PlanFile+`"`) {
+			t.Errorf("Expected apply to use the plan written by plan, got %v", ran)
+		}
+		if !strings.Contains(ran[0], "-target=aws_s3_bucket.logs") || strings.Contains(ran[1], "-target") {
+			t.Errorf("Expected the action flags on the plan only, got %v", ran)
+		}
+	})
+
+	t.Run("Failed plan skips apply", func(t *testing.T) {
+		t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		commands := fakeDeployCmds(t, 1)
+		cmd.Action = "deploy"
+
+		if code := exitCodeOf(t, manager.terraformDeploy(cmd, paths, "ws")); code != 1 {
+			t.Errorf("exit code = %d, want 1", code)
+		}
+		if len(*commands) != 1 || !strings.HasPrefix((*commands)[0], "terraform plan") {
+			t.Errorf("Expected only the plan to run, got %v", *commands)
+		}
+	})
+
+	t.Run("Operator rejects the plan", func(t *testing.T) {
+		manager, cmd := setupInstance(t)
+		paths := manager.computePaths(cmd)
+		commands := fakeDeployCmds(t, 0)
+		manager.stdin = strings.NewReader("nope\n")
+		cmd.Action = "deploy"
+
+		if err := manager.terraformDeploy(cmd, paths, "ws"); err == nil {
+			t.Error("Expected deploy to be aborted")
+		}
+		if ran := withoutPlanSummary(*commands); len(ran) != 1 {
+			t.Errorf("Expected only the plan to run, got %v", ran)
+		}
+	})
+}
+
+func TestPluginCache(t *testing.T) {
+	t.Run("Exported from config and created", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+		manager, cmd := setupInstance(t)
+		manager.config.PluginCacheDir = ".plugin-cache"
+		paths := manager.computePaths(cmd)
+		commands := fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = "init"
+
+		manager.terraformInit(cmd, paths)
+		want := filepath.Join(manager.config.ProjectDir, ".plugin-cache")
+		if got := envValue(manager.env, "TF_PLUGIN_CACHE_DIR"); got != want {
+			t.Errorf("TF_PLUGIN_CACHE_DIR = %q, want %q", got, want)
+		}
+		if got := os.Getenv("TF_PLUGIN_CACHE_DIR"); got != "" {
+			t.Errorf("Expected tf-manage's own environment to be left alone, got %q", got)
+		}
+		if info, err := os.Stat(want); err != nil || !info.IsDir() {
+			t.Errorf("Expected plugin cache directory %s to be created", want)
+		}
+		if len(*commands) != 1 {
+			t.Errorf("Expected init to run, got %v", *commands)
+		}
+	})
+
+	t.Run("Exported variable wins", func(t *testing.T) {
+		preset := filepath.Join(t.TempDir(), "cache")
+		t.Setenv("TF_PLUGIN_CACHE_DIR", preset)
+		manager, cmd := setupInstance(t)
+		manager.config.PluginCacheDir = ".plugin-cache"
+		paths := manager.computePaths(cmd)
+		fakeRunCmd(t, &framework.CmdResult{Success: true})
+		cmd.Action = "init"
+
+		manager.terraformInit(cmd, paths)
+		if got := envValue(manager.env, "TF_PLUGIN_CACHE_DIR"); got != "" {
+			t.Errorf("Expected the exported TF_PLUGIN_CACHE_DIR to be inherited, got override %q", got)
+		}
+		if _, err := os.Stat(preset); err != nil {
+			t.Errorf("Expected %s to be created: %v", preset, err)
+		}
+		if _, err := os.Stat(filepath.Join(manager.config.ProjectDir, ".plugin-cache")); !os.IsNotExist(err) {
+			t.Error("Configured plugin_cache_dir should not be created when TF_PLUGIN_CACHE_DIR is set")
+		}
+	})
+
+	t.Run("Unset leaves the environment alone", func(t *testing.T) {
+		t.Setenv("TF_PLUGIN_CACHE_DIR", "")
+		manager, _ := setupInstance(t)
+		manager.preparePluginCache()
+		if len(manager.env) != 0 {
+			t.Errorf("Expected no TF_PLUGIN_CACHE_DIR override, got %v", manager.env)
+		}
+	})
+}
+
+func TestSupportedActionsHaveHandlers(t *testing.T) {
+	listed := map[string]bool{}
+	for _, action := range SupportedActions() {
+		if listed[action.Name] {
+			t.Errorf("Action %s is listed twice", action.Name)
+		}
+		listed[action.Name] = true
+		if action.Description == "" {
+			t.Errorf("Action %s has no description", action.Name)
+		}
+		if actionHandlers[action.Name] == nil {
+			t.Errorf("Action %s has no handler", action.Name)
+		}
+	}
+	for name := range actionHandlers {
+		if !listed[name] {
+			t.Errorf("Handler %s is not in SupportedActions", name)
+		}
+	}
+}
+
+func TestExecuteRunsInModuleDirectory(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+	fakeTerraformInstalled(t)
+
+	for _, tt := range []struct {
+		name   string
+		result *framework.CmdResult
+	}{
+		{"Success", &framework.CmdResult{Success: true, Output: "* product1.test-repo.sample_module.dev.instance_x"}},
+		{"Failure", &framework.CmdResult{Success: false, ExitCode: 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, cmd := setupInstance(t)
+			start := t.TempDir()
+			t.Chdir(start)
+			cmd.Action = "validate"
+			modulePath := manager.computePaths(cmd).ModulePath
+
+			var dirs []string
+			original := runCmd
+			runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+				if strings.HasPrefix(command, "terraform ") {
+					dirs = append(dirs, flags.Dir)
+				}
+				return tt.result
+			}
+			t.Cleanup(func() { runCmd = original })
+
+			manager.Execute(cmd)
+			if wd, _ := os.Getwd(); wd != start {
+				t.Errorf("Working directory = %s after Execute, want %s", wd, start)
+			}
+			if len(dirs) == 0 {
+				t.Fatal("Expected terraform commands to run")
+			}
+			for _, dir := range dirs {
+				if dir != modulePath {
+					t.Errorf("terraform ran in %q, want %q", dir, modulePath)
+				}
+			}
+		})
+	}
+}
+
+func TestExecuteSelectsWorkspace(t *testing.T) {
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+	fakeTerraformInstalled(t)
+	workspace := "product1.test-repo.sample_module.dev.instance_x"
+
+	tests := []struct {
+		name       string
+		selectEnv  bool
+		wantSelect bool
+		wantEnv    string
+	}{
+		{"workspace select by default", false, true, ""},
+		{"TF_WORKSPACE with workspace_select_env", true, false, workspace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Unsetenv("TF_WORKSPACE")
+			manager, cmd := setupInstance(t)
+			manager.config.WorkspaceSelectEnv = tt.selectEnv
+			cmd.Action = "validate"
+
+			var commands, validateEnv []string
+			original := runCmd
+			runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+				commands = append(commands, command)
+				if strings.HasPrefix(command, "terraform validate") {
+					validateEnv = flags.Env
+				}
+				return &framework.CmdResult{Success: true, Output: "* " + workspace}
+			}
+			t.Cleanup(func() { runCmd = original })
+
+			manager.Execute(cmd)
+			if got := slices.Contains(commands, "terraform workspace select "+workspace); got != tt.wantSelect {
+				t.Errorf("Ran terraform workspace select = %v, want %v (commands %v)", got, tt.wantSelect, commands)
+			}
+			if got := envValue(validateEnv, "TF_WORKSPACE"); got != tt.wantEnv {
+				t.Errorf("terraform validate got TF_WORKSPACE=%q, want %q", got, tt.wantEnv)
+			}
+			if value, set := os.LookupEnv("TF_WORKSPACE"); set {
+				t.Errorf("Expected the process environment to be unchanged, TF_WORKSPACE=%q", value)
+			}
+			if len(manager.env) != 0 {
+				t.Errorf("Expected the command environment to be reset after Execute, got %v", manager.env)
+			}
+		})
+	}
+}
+
+func TestEnsureWorkspaceSelect(t *testing.T) {
+	manager, _ := setupInstance(t)
+
+	// fakeWorkspaces answers workspace select with missing until the workspace is created
+	fakeWorkspaces := func(t *testing.T, exists bool) *[]string {
+		t.Helper()
+		var commands []string
+		original := runCmd
+		runCmd = func(command, message string, flags *framework.CmdFlags, failMessage ...string) *framework.CmdResult {
+			commands = append(commands, command)
+			switch {
+			case command == "terraform workspace new ws":
+				exists = true
+			case command == "terraform workspace select ws" && !exists:
+				return &framework.CmdResult{Success: false, ExitCode: 1, Error: workspaceMissing}
+			}
+			return &framework.CmdResult{Success: true}
+		}
+		t.Cleanup(func() { runCmd = original })
+		return &commands
+	}
+
+	t.Run("Existing workspace is selected", func(t *testing.T) {
+		commands := fakeWorkspaces(t, true)
+		if err := manager.ensureWorkspace("ws"); err != nil {
+			t.Fatalf("ensureWorkspace failed: %v", err)
+		}
+		if want := []string{"terraform workspace select ws"}; !reflect.DeepEqual(*commands, want) {
+			t.Errorf("Commands = %v, want %v", *commands, want)
+		}
+	})
+
+	t.Run("Missing workspace is created then selected", func(t *testing.T) {
+		commands := fakeWorkspaces(t, false)
+		if err := manager.ensureWorkspace("ws"); err != nil {
+			t.Fatalf("ensureWorkspace failed: %v", err)
+		}
+		want := []string{"terraform workspace select ws", "terraform workspace new ws", "terraform workspace select ws"}
+		if !reflect.DeepEqual(*commands, want) {
+			t.Errorf("Commands = %v, want %v", *commands, want)
+		}
+	})
+
+	t.Run("Exported TF_WORKSPACE is blanked for terraform only", func(t *testing.T) {
+		t.Setenv("TF_WORKSPACE", "other")
+		manager.env = nil
+		fakeWorkspaces(t, true)
+		if err := manager.ensureWorkspace("ws"); err != nil {
+			t.Fatalf("ensureWorkspace failed: %v", err)
+		}
+		if got := envValue(manager.env, "TF_WORKSPACE"); got != "" {
+			t.Errorf("terraform gets TF_WORKSPACE=%q, want it blanked", got)
+		}
+		if got := os.Getenv("TF_WORKSPACE"); got != "other" {
+			t.Errorf("Process TF_WORKSPACE = %q, want it unchanged", got)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package terraform
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// GetOutputs returns the value of every terraform output for the command's instance.
+// It resolves the module directory and workspace itself, so it can be used without Execute.
+func (m *Manager) GetOutputs(cmd *Command) (map[string]any, error) {
+	_, leave, err := m.enterInstance(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	outputs, err := m.readOutputs()
+	if err != nil {
+		return nil, err
+	}
+	return outputValues(outputs), nil
+}
+
+// ShowPlanJSON returns the raw `terraform show -json` document for the command's instance.
+// The saved plan file is used when present, otherwise the current state is shown.
+func (m *Manager) ShowPlanJSON(cmd *Command) ([]byte, error) {
+	paths, leave, err := m.enterInstance(cmd)
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run(showCommand(paths, true), "Reading terraform plan as JSON", flags)
+	if !result.Success {
+		return nil, fmt.Errorf("terraform show failed: %s", strings.TrimSpace(result.Error))
+	}
+
+	return []byte(result.Output), nil
+}
+
+// enterInstance runs the following terraform commands in the module directory with the
+// instance workspace selected and the env's config overrides applied, mirroring what
+// Execute does before running an action. The returned function restores the Manager.
+func (m *Manager) enterInstance(cmd *Command) (*Paths, func(), error) {
+	base := m.config
+	m.config = base.ForEnv(cmd.Env)
+	leave := func() {
+		m.config = base
+		m.workDir = ""
+		m.env = nil
+	}
+
+	if !m.config.WorkspacesEnabled() {
+		leave()
+		return nil, nil, fmt.Errorf("instance %s cannot be selected through TF_WORKSPACE with use_workspaces: false", cmd.ModuleInstance)
+	}
+	paths := m.computePaths(cmd)
+	workspaceName, err := m.instanceWorkspace(cmd, paths)
+	if err != nil {
+		leave()
+		return nil, nil, err
+	}
+
+	m.workDir = paths.ModulePath
+	m.setEnv("TF_WORKSPACE", workspaceName)
+
+	return paths, leave, nil
+}
+
+// readOutputs runs `terraform output -json` quietly in the current directory
+func (m *Manager) readOutputs() (map[string]outputValue, error) {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run("terraform output -json", "Reading terraform outputs", flags)
+	if !result.Success {
+		return nil, fmt.Errorf("terraform output failed: %s", strings.TrimSpace(result.Error))
+	}
+
+	return decodeOutputs([]byte(result.Output))
+}
+
+// writeOutputsFile saves the raw `terraform output -json` document to outPath for downstream jobs.
+// An existing file is only replaced once valid JSON has been read.
+func (m *Manager) writeOutputsFile(outPath string) error {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run("terraform output -json", "Reading terraform outputs", flags)
+	if !result.Success {
+		framework.Error("Could not read terraform outputs")
+		return fmt.Errorf("terraform output failed: %s", strings.TrimSpace(result.Error))
+	}
+	if !json.Valid([]byte(result.Output)) {
+		framework.Error("Could not read terraform outputs")
+		return fmt.Errorf("invalid terraform output JSON")
+	}
+
+	if err := writeFileAtomic(outPath, []byte(result.Output)); err != nil {
+		return fmt.Errorf("failed to write outputs file %s: %w", outPath, err)
+	}
+	framework.Info(fmt.Sprintf("Wrote terraform outputs to %s", framework.AddEmphasisBlue(outPath)))
+	return nil
+}
+
+// outputValue is a single output from a `terraform output -json` payload
+type outputValue struct {
+	Value     any  `json:"value"`
+	Sensitive bool `json:"sensitive"`
+}
+
+// sensitiveOutput replaces sensitive values in flattened outputs, as terraform output does
+const sensitiveOutput = "(sensitive)"
+
+// decodeOutputs decodes a `terraform output -json` payload
+func decodeOutputs(data []byte) (map[string]outputValue, error) {
+	var payload map[string]outputValue
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep numbers exactly as terraform printed them
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("invalid terraform output JSON: %w", err)
+	}
+	return payload, nil
+}
+
+// parseOutputs extracts the value of each output from a `terraform output -json` payload
+func parseOutputs(data []byte) (map[string]any, error) {
+	outputs, err := decodeOutputs(data)
+	if err != nil {
+		return nil, err
+	}
+	return outputValues(outputs), nil
+}
+
+// outputValues drops the output metadata, keeping only the values
+func outputValues(outputs map[string]outputValue) map[string]any {
+	values := make(map[string]any, len(outputs))
+	for name, output := range outputs {
+		values[name] = output.Value
+	}
+	return values
+}
+
+// flattenOutputs renders outputs as key=value lines sorted by key, with list indexes in
+// numeric order. Nested lists and maps are expanded with dotted keys (e.g. subnets.0=...).
+// Sensitive outputs are shown as (sensitive) unless showSensitive is set.
+func flattenOutputs(outputs map[string]outputValue, showSensitive bool) []string {
+	var entries []outputEntry
+	for name, output := range outputs {
+		if output.Sensitive && !showSensitive {
+			entries = append(entries, outputEntry{path: []string{name}, value: sensitiveOutput})
+			continue
+		}
+		entries = flattenValue(entries, []string{name}, output.Value)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return compareOutputPaths(entries[i].path, entries[j].path) < 0
+	})
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = strings.Join(entry.path, ".") + "=" + entry.value
+	}
+	return lines
+}
+
+// outputEntry is a single flattened output value and the key segments leading to it
+type outputEntry struct {
+	path  []string
+	value string
+}
+
+func flattenValue(entries []outputEntry, path []string, value any) []outputEntry {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			entries = flattenValue(entries, append(slices.Clone(path), k), item)
+		}
+	case []any:
+		for i, item := range v {
+			entries = flattenValue(entries, append(slices.Clone(path), strconv.Itoa(i)), item)
+		}
+	case nil:
+		entries = append(entries, outputEntry{path: path})
+	default:
+		entries = append(entries, outputEntry{path: path, value: fmt.Sprint(v)})
+	}
+	return entries
+}
+
+// compareOutputPaths orders flattened keys segment by segment, comparing numeric
+// segments (list indexes) as numbers so list.2 sorts before list.10
+func compareOutputPaths(a, b []string) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] == b[i] {
+			continue
+		}
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		if errX == nil && errY == nil {
+			return cmp.Compare(x, y)
+		}
+		return strings.Compare(a[i], b[i])
+	}
+	return cmp.Compare(len(a), len(b))
+}
@@ -0,0 +1,40 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// pendingPlanFile is where plan writes a plan awaiting review when review_plans is enabled
+func pendingPlanFile(planFile string) string {
+	return planFile + ".pending"
+}
+
+// promotePlan saves a reviewed plan as the canonical plan used by apply_plan. The plan is
+// approved by --approve-plan or, in operator mode, by typing the instance name; otherwise
+// the pending plan is discarded and the previous canonical plan is left as it was.
+func (m *Manager) promotePlan(cmd *Command, pendingFile, planFile string, approved bool) error {
+	if !approved && m.isUnattended() {
+		os.Remove(pendingFile)
+		framework.Error(fmt.Sprintf("Plan was not saved; pass %s to save it in unattended mode", framework.AddEmphasisBlue("--approve-plan")))
+		return NewExitCodeError("plan not approved", ExitApprovalDenied)
+	}
+
+	if !approved {
+		framework.Info("Review the plan above before it is saved for apply_plan.")
+		if !m.confirmByTyping(cmd.ModuleInstance) {
+			os.Remove(pendingFile)
+			framework.Error("Confirmation did not match, discarding the plan")
+			return NewExitCodeError("plan not approved", ExitApprovalDenied)
+		}
+	}
+
+	if err := os.Rename(pendingFile, planFile); err != nil {
+		os.Remove(pendingFile)
+		return fmt.Errorf("failed to save plan %s: %w", planFile, err)
+	}
+	framework.Info(fmt.Sprintf("Plan saved to %s", framework.AddEmphasisBlue(planFile)))
+	return nil
+}
@@ -0,0 +1,88 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// PlanSummary holds the resource counts of a plan, as in terraform's "Plan: X to add, ..." line
+type PlanSummary struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// HasChanges reports whether the plan changes any resource
+func (s PlanSummary) HasChanges() bool {
+	return s.Add+s.Change+s.Destroy > 0
+}
+
+// String renders the summary as a colorized one-liner
+func (s PlanSummary) String() string {
+	if !s.HasChanges() {
+		return framework.AddEmphasisGreen("No changes.") + " Infrastructure matches the configuration."
+	}
+	return fmt.Sprintf("Plan: %s to add, %s to change, %s to destroy.",
+		framework.AddEmphasisGreen(fmt.Sprint(s.Add)),
+		framework.AddEmphasisBlue(fmt.Sprint(s.Change)),
+		framework.AddEmphasisRed(fmt.Sprint(s.Destroy)))
+}
+
+// parsePlanSummary counts the resource changes in `terraform show -json` output of a plan.
+// A replacement counts as one add and one destroy, like terraform's own summary.
+func parsePlanSummary(planJSON []byte) (PlanSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(planJSON, &plan); err != nil {
+		return PlanSummary{}, fmt.Errorf("invalid plan JSON: %w", err)
+	}
+
+	var summary PlanSummary
+	for _, rc := range plan.ResourceChanges {
+		actions := rc.Change.Actions
+		if slices.Contains(actions, "create") {
+			summary.Add++
+		}
+		if slices.Contains(actions, "update") {
+			summary.Change++
+		}
+		if slices.Contains(actions, "delete") {
+			summary.Destroy++
+		}
+	}
+	return summary, nil
+}
+
+// printPlanSummary renders planFile with `terraform show -json` and prints its add/change/destroy
+// counts. The summary is informational, so failing to produce it never fails the plan.
+func (m *Manager) printPlanSummary(planFile string) {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintMessage = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	result := m.run(
+		fmt.Sprintf("terraform show -json \"%s\"", planFile),
+		"Summarizing plan",
+		flags,
+	)
+	if !result.Success {
+		framework.Debug("Could not render the plan as JSON: " + result.Error)
+		return
+	}
+
+	summary, err := parsePlanSummary([]byte(result.Output))
+	if err != nil {
+		framework.Debug(err.Error())
+		return
+	}
+	framework.Info(summary.String())
+}
@@ -0,0 +1,36 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// preparePluginCache passes plugin_cache_dir to terraform as TF_PLUGIN_CACHE_DIR so init reuses
+// providers across modules, and creates the directory, which terraform does not do itself. A
+// TF_PLUGIN_CACHE_DIR exported before tf-manage ran takes precedence over the config.
+func (m *Manager) preparePluginCache() {
+	dir, _ := m.lookupEnv("TF_PLUGIN_CACHE_DIR")
+	if dir == "" {
+		if dir = m.config.GetPluginCacheDir(); dir == "" {
+			return
+		}
+		m.setEnv("TF_PLUGIN_CACHE_DIR", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil || !isWritableDir(dir) {
+		framework.Info(fmt.Sprintf("Plugin cache directory %s is not writable; providers will not be cached", framework.AddEmphasisRed(dir)))
+	}
+}
+
+// isWritableDir reports whether a file can be created in dir
+func isWritableDir(dir string) bool {
+	file, err := os.CreateTemp(dir, ".tfm-write-check-*")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return true
+}
@@ -0,0 +1,130 @@
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// tfmVarNames are the convention variables tf-manage passes to every var-file aware action
+var tfmVarNames = []string{"tfm_product", "tfm_repo", "tfm_module", "tfm_env", "tfm_module_instance"}
+
+// tfmVarActions are the actions whose terraform commands carry the tfm_* -var flags
+var tfmVarActions = map[string]bool{
+	"plan": true, "apply": true, "destroy": true, "import": true, "refresh": true, "deploy": true,
+}
+
+// tfmVarDeclPattern matches a `variable "tfm_..." {` block header in a .tf file
+var tfmVarDeclPattern = regexp.MustCompile(`(?m)^\s*variable\s+"?(tfm_[A-Za-z0-9_]+)"?\s*\{`)
+
+// tfmVarsToInject returns the tfm_* variables to pass for the module at modulePath.
+// In always mode undeclared variables are still passed, with a warning; auto drops them.
+func (m *Manager) tfmVarsToInject(modulePath string) []string {
+	inject, missing := m.selectTfmVars(modulePath)
+	if len(missing) == 0 {
+		return inject
+	}
+
+	if m.config.TfmVarsMode() == config.InjectTfmVarsAuto {
+		framework.Debug(fmt.Sprintf("Not passing undeclared %s", strings.Join(missing, ", ")))
+		return inject
+	}
+	framework.Info(fmt.Sprintf("Module %s does not declare %s; terraform may reject them (set inject_tfm_vars: auto to omit them)",
+		framework.AddEmphasisBlue(filepath.Base(modulePath)), strings.Join(missing, ", ")))
+	return tfmVarNames
+}
+
+// selectTfmVars splits the tfm_* variables into those the module at modulePath declares and
+// those it does not. Every variable counts as declared when the module cannot be scanned.
+func (m *Manager) selectTfmVars(modulePath string) (declaredVars, missing []string) {
+	if m.config.TfmVarsMode() == config.InjectTfmVarsNever {
+		return nil, nil
+	}
+
+	declared, err := declaredTfmVars(modulePath)
+	if err != nil {
+		// Without the module's sources terraform will report the problem itself
+		framework.Debug(fmt.Sprintf("Could not scan %s for tfm_* variables: %v", modulePath, err))
+		return tfmVarNames, nil
+	}
+
+	for _, name := range tfmVarNames {
+		if declared[name] {
+			declaredVars = append(declaredVars, name)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	return declaredVars, missing
+}
+
+// tfmVarValues returns the value tf-manage passes for each tfm_* variable
+func (m *Manager) tfmVarValues(cmd *Command) map[string]string {
+	return map[string]string{
+		"tfm_product":         cmd.Product,
+		"tfm_repo":            m.config.RepoName,
+		"tfm_module":          cmd.Module,
+		"tfm_env":             cmd.Env,
+		"tfm_module_instance": cmd.ModuleInstance,
+	}
+}
+
+// checkTfmVarEnv reports TF_VAR_tfm_* variables exported with values that differ from the
+// -var values tf-manage passes. Terraform silently prefers -var, so the exported value is
+// ignored; with ErrorOnWarn the conflict fails validation instead.
+func (m *Manager) checkTfmVarEnv(cmd *Command, paths *Paths) error {
+	// Same selection as tfmVarsToInject, without repeating its notices
+	injected, missing := m.selectTfmVars(paths.ModulePath)
+	if len(missing) > 0 && m.config.TfmVarsMode() != config.InjectTfmVarsAuto {
+		injected = tfmVarNames
+	}
+	values := m.tfmVarValues(cmd)
+
+	var conflicts []string
+	for _, name := range injected {
+		exported, set := m.lookupEnv("TF_VAR_" + name)
+		if set && exported != values[name] {
+			conflicts = append(conflicts, fmt.Sprintf("TF_VAR_%s=%s (tf-manage passes %s)", name, exported, values[name]))
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("Exported %s conflict with the values tf-manage passes with -var, which take precedence",
+		strings.Join(conflicts, ", "))
+	if m.options.ErrorOnWarn {
+		framework.Error(message)
+		return NewExitCodeError("conflicting TF_VAR_tfm_* environment variables", ExitValidationFailed)
+	}
+	framework.Error(message + "; unset them to silence this warning")
+	return nil
+}
+
+// declaredTfmVars scans the module's top level .tf files for tfm_* variable declarations
+func declaredTfmVars(modulePath string) (map[string]bool, error) {
+	files, err := filepath.Glob(filepath.Join(modulePath, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .tf files")
+	}
+
+	declared := map[string]bool{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range tfmVarDeclPattern.FindAllStringSubmatch(string(data), -1) {
+			declared[match[1]] = true
+		}
+	}
+	return declared, nil
+}
@@ -0,0 +1,191 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// varFileExtensions are the instance config file extensions, in order of preference
+var varFileExtensions = []string{".tfvars.json", ".tfvars"}
+
+// instanceVarFile returns the instance's config file in moduleEnvPath, preferring
+// <instance>.tfvars.json over <instance>.tfvars. The .tfvars path is returned when neither exists.
+func instanceVarFile(moduleEnvPath, instance string) string {
+	for _, ext := range varFileExtensions {
+		path := filepath.Join(moduleEnvPath, instance+ext)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return filepath.Join(moduleEnvPath, instance+".tfvars")
+}
+
+// instanceFromVarFile returns the instance name of a config file name, if it is one
+func instanceFromVarFile(name string) (string, bool) {
+	for _, ext := range varFileExtensions {
+		if instance, ok := strings.CutSuffix(name, ext); ok && instance != "" {
+			return instance, true
+		}
+	}
+	return "", false
+}
+
+// checkVarFile rejects an empty or whitespace-only tfvars file, which would otherwise make
+// terraform prompt for every variable. With syntax set it also checks the file's structure.
+func checkVarFile(path string, syntax bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return fmt.Errorf("is empty; set the module's variables in it")
+	}
+	if !syntax {
+		return nil
+	}
+
+	if strings.HasSuffix(path, ".json") {
+		if !json.Valid(data) {
+			return fmt.Errorf("is not valid JSON")
+		}
+		return nil
+	}
+	if err := checkTfvarsSyntax(string(data)); err != nil {
+		return fmt.Errorf("is not valid HCL: %w", err)
+	}
+	return nil
+}
+
+// checkTfvarsSyntax is a lightweight structural check of HCL tfvars: every top level
+// statement must be "name = value", and strings, heredocs, comments and brackets must be
+// terminated and balanced. It is not a full HCL parser.
+func checkTfvarsSyntax(src string) error {
+	var stack []byte
+	line := 1
+	atStatement := true // At depth 0, waiting for the next "name ="
+	valueSeen := true   // At depth 0, the current value has started
+
+	closers := map[byte]byte{'}': '{', ']': '[', ')': '('}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			if len(stack) == 0 && !valueSeen {
+				return fmt.Errorf("line %d: missing value", line)
+			}
+			if len(stack) == 0 {
+				atStatement = true
+			}
+			line++
+
+		case c == ' ' || c == '\t' || c == '\r' || c == ',' && len(stack) > 0:
+
+		case c == '#' || c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return fmt.Errorf("line %d: unterminated comment", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 3
+
+		case atStatement && len(stack) == 0:
+			start := i
+			for i < len(src) && (isIdentByte(src[i]) || i > start && (src[i] == '-' || src[i] >= '0' && src[i] <= '9')) {
+				i++
+			}
+			if i == start {
+				return fmt.Errorf("line %d: expected a variable name", line)
+			}
+			for i < len(src) && (src[i] == ' ' || src[i] == '\t') {
+				i++
+			}
+			if i >= len(src) || src[i] != '=' {
+				return fmt.Errorf("line %d: expected %s = value", line, src[start:i])
+			}
+			atStatement, valueSeen = false, false
+
+		case c == '"':
+			valueSeen = true
+			for i++; ; i++ {
+				if i >= len(src) || src[i] == '\n' {
+					return fmt.Errorf("line %d: unterminated string", line)
+				}
+				if src[i] == '\\' {
+					i++
+				} else if src[i] == '"' {
+					break
+				}
+			}
+
+		case c == '<' && strings.HasPrefix(src[i:], "<<"):
+			valueSeen = true
+			eol := strings.IndexByte(src[i:], '\n')
+			if eol < 0 {
+				return fmt.Errorf("line %d: unterminated heredoc", line)
+			}
+			marker := strings.TrimSpace(strings.TrimPrefix(src[i+2:i+eol], "-"))
+			if marker == "" {
+				return fmt.Errorf("line %d: heredoc without a marker", line)
+			}
+			opened := line
+
+			// Skip body lines up to the marker, leaving the newline after it to the main loop
+			i += eol
+			for {
+				line++
+				next := strings.IndexByte(src[i+1:], '\n')
+				bodyLine := src[i+1:]
+				if next >= 0 {
+					bodyLine = src[i+1 : i+1+next]
+				}
+				if strings.TrimSpace(bodyLine) == marker {
+					if next < 0 {
+						i = len(src) - 1
+					} else {
+						i += next
+					}
+					break
+				}
+				if next < 0 {
+					return fmt.Errorf("line %d: unterminated heredoc", opened)
+				}
+				i += 1 + next
+			}
+
+		case c == '{' || c == '[' || c == '(':
+			valueSeen = true
+			stack = append(stack, c)
+
+		case closers[c] != 0:
+			if len(stack) == 0 || stack[len(stack)-1] != closers[c] {
+				return fmt.Errorf("line %d: unexpected %c", line, c)
+			}
+			stack = stack[:len(stack)-1]
+
+		default:
+			valueSeen = true
+		}
+	}
+
+	if len(stack) > 0 {
+		return fmt.Errorf("unclosed %c at end of file", stack[len(stack)-1])
+	}
+	if !valueSeen {
+		return fmt.Errorf("line %d: missing value", line)
+	}
+	return nil
+}
+
+// isIdentByte reports whether c can start an HCL identifier
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
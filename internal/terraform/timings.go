@@ -0,0 +1,46 @@
+package terraform
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// PhaseTiming is how long one phase of Execute took (recorded with Options.Timings)
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// timePhase runs fn, recording how long it took when timings are enabled
+func (m *Manager) timePhase(phase string, fn func() error) error {
+	if !m.options.Timings {
+		return fn()
+	}
+
+	start := time.Now()
+	err := fn()
+	m.info.Timings = append(m.info.Timings, PhaseTiming{Phase: phase, Duration: time.Since(start)})
+	return err
+}
+
+// printTimings prints the recorded phases and their total as a small table
+func (m *Manager) printTimings() {
+	if !m.options.Timings || len(m.info.Timings) == 0 {
+		return
+	}
+
+	width := len("total")
+	for _, timing := range m.info.Timings {
+		width = max(width, len(timing.Phase))
+	}
+
+	var total time.Duration
+	framework.Info("Timings:")
+	for _, timing := range m.info.Timings {
+		total += timing.Duration
+		framework.Info(fmt.Sprintf("  %-*s %10s", width, timing.Phase, timing.Duration.Round(time.Millisecond)))
+	}
+	framework.Info(fmt.Sprintf("  %-*s %10s", width, "total", total.Round(time.Millisecond)))
+}
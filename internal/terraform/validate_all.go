@@ -0,0 +1,134 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/format"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// ModuleValidation is the outcome of validating a single module
+type ModuleValidation struct {
+	Module string `json:"module"`
+	Valid  bool   `json:"valid"`
+	Stage  string `json:"stage,omitempty"` // Step that failed: "init" or "validate"
+	Error  string `json:"error,omitempty"`
+}
+
+// ValidateAll runs `terraform init -backend=false` and `terraform validate` in every module
+// and writes a summary in the requested format. It fails if any module is invalid.
+func (m *Manager) ValidateAll(w io.Writer, formatName string) error {
+	if err := checkTerraformInstalled(); err != nil {
+		return err
+	}
+
+	modules, err := m.listModules()
+	if err != nil {
+		return err
+	}
+	if len(modules) == 0 {
+		return fmt.Errorf("no modules found in %s", m.config.GetModulePath())
+	}
+
+	m.preparePluginCache()
+	defer func() { m.env = nil }()
+	results := m.validateModules(modules)
+
+	if err := format.Write(w, formatName, validationSummary(results)); err != nil {
+		return err
+	}
+
+	if failed := countInvalid(results); failed > 0 {
+		framework.Error(fmt.Sprintf("%d of %d modules failed validation", failed, len(results)))
+		return NewExitCodeError("validation failed", 1)
+	}
+
+	framework.Info(fmt.Sprintf("All %d modules are valid", len(results)))
+	return nil
+}
+
+// listModules returns the module directory names under module_rel_path
+func (m *Manager) listModules() ([]string, error) {
+	modulePath := m.config.GetModulePath()
+	entries, err := os.ReadDir(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("module path does not exist: %s", modulePath)
+	}
+
+	var modules []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			modules = append(modules, entry.Name())
+		}
+	}
+	return modules, nil
+}
+
+// validateModules validates each module from within its own directory
+func (m *Manager) validateModules(modules []string) []ModuleValidation {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintMessage = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+
+	results := make([]ModuleValidation, 0, len(modules))
+	for _, module := range modules {
+		result := ModuleValidation{Module: module, Valid: true}
+		flags.Dir = filepath.Join(m.config.GetModulePath(), module)
+
+		steps := []struct {
+			stage   string
+			command string
+		}{
+			{"init", "terraform init -backend=false -input=false"},
+			{"validate", "terraform validate -no-color"},
+		}
+		for _, step := range steps {
+			res := m.run(step.command,
+				fmt.Sprintf("Running %s for module %s", step.stage, framework.AddEmphasisBlue(module)),
+				flags,
+			)
+			if !res.Success {
+				result.Valid = false
+				result.Stage = step.stage
+				result.Error = strings.TrimSpace(res.Error)
+				break
+			}
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+// validationSummary converts validation results into a formatter result
+func validationSummary(results []ModuleValidation) *format.Result {
+	summary := &format.Result{
+		Title:   "Module validation summary",
+		Columns: []string{"module", "status", "failed_stage"},
+		Data:    results,
+	}
+	for _, result := range results {
+		status := "valid"
+		if !result.Valid {
+			status = "invalid"
+		}
+		summary.Rows = append(summary.Rows, []string{result.Module, status, result.Stage})
+	}
+	return summary
+}
+
+// countInvalid returns how many modules failed validation
+func countInvalid(results []ModuleValidation) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Valid {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,256 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// versionCacheEntry is the on-disk record of the last detected terraform version
+type versionCacheEntry struct {
+	BinaryPath string `json:"binary_path"`
+	ModTime    int64  `json:"mod_time"`
+	Size       int64  `json:"size"`
+	Version    string `json:"version"`
+}
+
+var (
+	versionOnce sync.Once
+	version     string
+
+	// versionLookup performs the actual detection; replaced in tests
+	versionLookup = lookupTerraformVersion
+
+	// lookPath locates binaries on PATH; replaced in tests
+	lookPath = exec.LookPath
+)
+
+// terraformBinary is the executable every terraform command runs
+const terraformBinary = "terraform"
+
+// checkTerraformInstalled fails with an actionable message when terraform is not on PATH
+func checkTerraformInstalled() error {
+	if _, err := lookPath(terraformBinary); err != nil {
+		framework.Error(fmt.Sprintf("%s was not found on PATH", terraformBinary))
+		return fmt.Errorf("%s not found on PATH: install terraform (https://developer.hashicorp.com/terraform/install) or add its directory to PATH", terraformBinary)
+	}
+	return nil
+}
+
+// getTerraformVersion returns the Terraform CLI version, detecting it at most once per process.
+// Setting TFM_SKIP_VERSION_CHECK skips detection entirely and reports "unknown".
+func getTerraformVersion() string {
+	if os.Getenv("TFM_SKIP_VERSION_CHECK") != "" {
+		return "unknown"
+	}
+
+	versionOnce.Do(func() {
+		version = versionLookup()
+	})
+	return version
+}
+
+// lookupTerraformVersion returns the Terraform CLI version, reusing the version cached by a
+// previous invocation as long as the terraform binary on PATH has not changed
+func lookupTerraformVersion() string {
+	binaryPath, err := lookPath(terraformBinary)
+	if err != nil {
+		return detectTerraformVersion()
+	}
+
+	cachePath, err := versionCachePath()
+	if err != nil {
+		framework.Debug(fmt.Sprintf("Terraform version cache disabled: %v", err))
+		return detectTerraformVersion()
+	}
+
+	return cachedVersion(cachePath, binaryPath, detectTerraformVersion)
+}
+
+// versionCachePath returns the file used to cache the terraform version.
+// TFM_CACHE_DIR overrides the default user cache directory.
+func versionCachePath() (string, error) {
+	dir := os.Getenv("TFM_CACHE_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(cacheDir, "tf-manage2")
+	}
+	return filepath.Join(dir, "terraform-version.json"), nil
+}
+
+// cachedVersion returns the cached version for binaryPath, calling detect and refreshing
+// the cache when the binary's resolved path, size or modification time changed
+func cachedVersion(cachePath, binaryPath string, detect func() string) string {
+	if resolved, err := filepath.EvalSymlinks(binaryPath); err == nil {
+		binaryPath = resolved
+	}
+
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return detect()
+	}
+
+	current := versionCacheEntry{
+		BinaryPath: binaryPath,
+		ModTime:    info.ModTime().UnixNano(),
+		Size:       info.Size(),
+	}
+
+	var cached versionCacheEntry
+	if data, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(data, &cached) == nil {
+		if cached.BinaryPath == current.BinaryPath && cached.ModTime == current.ModTime &&
+			cached.Size == current.Size && cached.Version != "" {
+			framework.Debug(fmt.Sprintf("Using cached terraform version %s", cached.Version))
+			return cached.Version
+		}
+	}
+
+	current.Version = detect()
+	if current.Version == "unknown" {
+		return current.Version
+	}
+
+	if err := writeVersionCache(cachePath, &current); err != nil {
+		framework.Debug(fmt.Sprintf("Failed to write terraform version cache: %v", err))
+	}
+	return current.Version
+}
+
+// writeVersionCache stores entry at cachePath, creating the cache directory if needed
+func writeVersionCache(cachePath string, entry *versionCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(cachePath, data, 0644)
+}
+
+// mutatingActions are the actions that can write terraform state
+var mutatingActions = map[string]bool{
+	"apply":            true,
+	"apply_plan":       true,
+	"deploy":           true,
+	"destroy":          true,
+	"import":           true,
+	"taint":            true,
+	"untaint":          true,
+	"state":            true,
+	"refresh":          true,
+	"workspace":        true,
+	"delete-workspace": true,
+	"raw":              true, // Raw commands are not rewritten, but still gated
+}
+
+// checkMinTerraformVersion enforces min_terraform_version for state-mutating actions,
+// which are also refused when the version cannot be detected. Read-only actions only get a warning.
+func (m *Manager) checkMinTerraformVersion(action, detected string) error {
+	minVersion := m.config.MinTerraformVersion
+	if minVersion == "" {
+		return nil
+	}
+
+	if detected == "unknown" {
+		message := fmt.Sprintf("Could not detect terraform version to check against minimum %s", minVersion)
+		if mutatingActions[action] {
+			framework.Error(message + " (is TFM_SKIP_VERSION_CHECK set?)")
+			return fmt.Errorf("terraform version unknown, cannot enforce min_terraform_version %s for %s", minVersion, action)
+		}
+		framework.Error(message + ", continuing with read-only action")
+		return nil
+	}
+
+	cmp, err := CompareVersions(detected, minVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min_terraform_version: %w", err)
+	}
+	if cmp >= 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("Terraform %s is older than the required minimum %s", detected, minVersion)
+	if mutatingActions[action] {
+		framework.Error(message)
+		return fmt.Errorf("terraform %s is below min_terraform_version %s; upgrade terraform before running %s", detected, minVersion, action)
+	}
+
+	framework.Error(message + ", continuing with read-only action")
+	return nil
+}
+
+// CompareVersions compares two semantic versions (with optional "v" prefix), returning -1, 0 or 1.
+// A pre-release sorts before the corresponding release.
+func CompareVersions(a, b string) (int, error) {
+	va, preA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, preB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0, nil
+	case preA == "":
+		return 1, nil
+	case preB == "":
+		return -1, nil
+	case preA < preB:
+		return -1, nil
+	default:
+		return 1, nil
+	}
+}
+
+// parseVersion splits "v1.9.5-beta1" into [1 9 5] and "beta1"; missing parts default to 0
+func parseVersion(version string) ([3]int, string, error) {
+	var parsed [3]int
+	core := strings.TrimPrefix(strings.TrimSpace(version), "v")
+
+	pre := ""
+	if idx := strings.IndexAny(core, "-+"); idx >= 0 {
+		if core[idx] == '-' {
+			pre = core[idx+1:]
+			if plus := strings.IndexByte(pre, '+'); plus >= 0 {
+				pre = pre[:plus]
+			}
+		}
+		core = core[:idx]
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 || parts[0] == "" {
+		return parsed, "", fmt.Errorf("invalid version: %s", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, "", fmt.Errorf("invalid version: %s", version)
+		}
+		parsed[i] = n
+	}
+	return parsed, pre, nil
+}
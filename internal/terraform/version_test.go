@@ -0,0 +1,149 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+)
+
+func TestCachedVersion(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cache", "terraform-version.json")
+	binaryPath := filepath.Join(dir, "terraform")
+	if err := os.WriteFile(binaryPath, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatalf("Failed to create fake binary: %v", err)
+	}
+
+	calls := 0
+	detected := "1.9.5"
+	detect := func() string {
+		calls++
+		return detected
+	}
+
+	// Miss: nothing cached yet
+	if got := cachedVersion(cachePath, binaryPath, detect); got != "1.9.5" || calls != 1 {
+		t.Fatalf("first call = %s (%d detections), want 1.9.5 (1 detection)", got, calls)
+	}
+
+	// Hit: the binary is unchanged
+	if got := cachedVersion(cachePath, binaryPath, detect); got != "1.9.5" || calls != 1 {
+		t.Errorf("cached call = %s (%d detections), want 1.9.5 (1 detection)", got, calls)
+	}
+
+	// Invalidation: the binary was upgraded in place
+	detected = "1.10.0"
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(binaryPath, later, later); err != nil {
+		t.Fatalf("Failed to touch fake binary: %v", err)
+	}
+	if got := cachedVersion(cachePath, binaryPath, detect); got != "1.10.0" || calls != 2 {
+		t.Errorf("after upgrade = %s (%d detections), want 1.10.0 (2 detections)", got, calls)
+	}
+
+	// Unknown versions are never cached
+	detected = "unknown"
+	otherCache := filepath.Join(dir, "other.json")
+	cachedVersion(otherCache, binaryPath, detect)
+	if _, err := os.Stat(otherCache); !os.IsNotExist(err) {
+		t.Error("Expected unknown version not to be cached")
+	}
+}
+
+func TestGetTerraformVersionOncePerProcess(t *testing.T) {
+	calls := 0
+	originalLookup := versionLookup
+	versionLookup = func() string {
+		calls++
+		return "1.9.5"
+	}
+	versionOnce = sync.Once{}
+	t.Cleanup(func() {
+		versionLookup = originalLookup
+		versionOnce = sync.Once{}
+	})
+
+	for i := 0; i < 3; i++ {
+		if got := getTerraformVersion(); got != "1.9.5" {
+			t.Errorf("getTerraformVersion() = %s, want 1.9.5", got)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected detection to run once, ran %d times", calls)
+	}
+
+	t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+	if got := getTerraformVersion(); got != "unknown" {
+		t.Errorf("getTerraformVersion() with TFM_SKIP_VERSION_CHECK = %s, want unknown", got)
+	}
+}
+
+func TestCheckMinTerraformVersion(t *testing.T) {
+	manager := NewManager(&config.Config{
+		RepoName:            "test-repo",
+		MinTerraformVersion: "1.5.0",
+	})
+
+	tests := []struct {
+		detected string
+		action   string
+		wantErr  bool
+	}{
+		{detected: "v1.9.5", action: "apply"},
+		{detected: "1.5.0", action: "apply"},
+		{detected: "v1.10.0", action: "destroy"},
+		{detected: "v1.4.7", action: "apply", wantErr: true},
+		{detected: "v1.4.7", action: "state", wantErr: true},
+		{detected: "v1.4.7", action: "raw", wantErr: true},
+		{detected: "v1.5.0-rc1", action: "apply", wantErr: true},
+		{detected: "v0.15.5", action: "plan"},
+		{detected: "v1.4.7", action: "show"},
+		{detected: "unknown", action: "apply", wantErr: true},
+		{detected: "unknown", action: "raw", wantErr: true},
+		{detected: "unknown", action: "plan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.detected+" "+tt.action, func(t *testing.T) {
+			err := manager.checkMinTerraformVersion(tt.action, tt.detected)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkMinTerraformVersion(%s, %s) error = %v, wantErr %v", tt.action, tt.detected, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.9.5", "1.9.5", 0},
+		{"v1.9.5", "1.9.5", 0},
+		{"1.10.0", "1.9.5", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.5.0-beta1", "1.5.0", -1},
+		{"1.5.0-beta2", "1.5.0-beta1", 1},
+		{"1.5.0+build", "1.5.0", 0},
+		{"0.15.5", "1.0.0", -1},
+	}
+
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("CompareVersions(%s, %s) unexpected error: %v", tt.a, tt.b, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if _, err := CompareVersions("one.two", "1.0.0"); err == nil {
+		t.Error("Expected error for invalid version")
+	}
+}
@@ -0,0 +1,101 @@
+package terraform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// defaultWorkspace is the workspace terraform always provides and never deletes
+const defaultWorkspace = "default"
+
+// terraformDeleteWorkspace removes the instance's workspace after switching to default
+func (m *Manager) terraformDeleteWorkspace(cmd *Command, workspaceName string) error {
+	if workspaceName == defaultWorkspace {
+		framework.Error("Refusing to delete the default workspace")
+		return NewExitCodeError(fmt.Sprintf("cannot delete the %s workspace", defaultWorkspace), ExitWorkspaceFailed)
+	}
+
+	force := hasActionFlag(cmd, "-force")
+
+	framework.Info(fmt.Sprintf("This will DELETE workspace %s.", framework.AddEmphasisRed(workspaceName)))
+
+	// Unattended runs have nobody to confirm, so they must opt in explicitly
+	if m.isUnattended() {
+		if !force {
+			framework.Error("Deleting a workspace in unattended mode requires -force")
+			return fmt.Errorf("delete-workspace requires -force in unattended mode")
+		}
+	} else {
+		m.printTargetSummary(cmd, workspaceName)
+		if !m.confirmByTyping(workspaceName) {
+			framework.Error("Confirmation did not match, aborting workspace deletion")
+			return fmt.Errorf("delete-workspace aborted by operator")
+		}
+	}
+
+	if err := m.selectDefaultWorkspace(); err != nil {
+		return err
+	}
+
+	// Terraform cannot delete the active workspace; make sure the switch took effect
+	current, err := m.currentWorkspace()
+	if err != nil {
+		return err
+	}
+	if current == workspaceName {
+		framework.Error(fmt.Sprintf("Workspace %s is still active", framework.AddEmphasisRed(workspaceName)))
+		return NewExitCodeError(fmt.Sprintf("refusing to delete active workspace %s", workspaceName), ExitWorkspaceFailed)
+	}
+
+	terraformCmd := fmt.Sprintf("terraform workspace delete%s %s", m.actionFlags(cmd), workspaceName)
+
+	result := m.run(
+		terraformCmd,
+		fmt.Sprintf("Deleting workspace %s", framework.AddEmphasisRed(workspaceName)),
+		framework.DefaultCmdFlags(),
+		"Terraform workspace delete failed",
+	)
+
+	return NewExitCodeError("command failed", result.ExitCode)
+}
+
+// selectDefaultWorkspace switches away from the instance workspace
+func (m *Manager) selectDefaultWorkspace() error {
+	// TF_WORKSPACE would override the selection, so blank it first (terraform ignores it when empty)
+	m.setEnv("TF_WORKSPACE", "")
+
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintOutcome = false
+	flags.DecorateOutput = true
+
+	result := m.run(
+		fmt.Sprintf("terraform workspace select %s", defaultWorkspace),
+		fmt.Sprintf("Selecting workspace %s", framework.AddEmphasisBlue(defaultWorkspace)),
+		flags,
+		"Could not select the default workspace!",
+	)
+	if !result.Success {
+		return NewExitCodeError(fmt.Sprintf("failed to select workspace %s", defaultWorkspace), ExitWorkspaceFailed)
+	}
+
+	return nil
+}
+
+// currentWorkspace returns the name of the workspace terraform considers active
+func (m *Manager) currentWorkspace() (string, error) {
+	flags := framework.DefaultCmdFlags()
+	flags.PrintMessage = false
+	flags.PrintOutput = false
+	flags.PrintStatus = false
+	flags.DecorateOutput = true
+
+	result := m.run("terraform workspace show", "Reading active workspace", flags)
+	if !result.Success {
+		return "", NewExitCodeError("failed to read the active workspace", ExitWorkspaceFailed)
+	}
+
+	return strings.TrimSpace(result.Output), nil
+}
@@ -0,0 +1,120 @@
+package terraform
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sorinlg/tf-manage2/internal/format"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+)
+
+// WorkspaceEntry is one workspace of a module mapped back to tf-manage coordinates
+type WorkspaceEntry struct {
+	Workspace string `json:"workspace"`
+	Product   string `json:"product,omitempty"`
+	Module    string `json:"module,omitempty"`
+	Env       string `json:"env,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Managed   bool   `json:"managed"` // The name follows tf-manage's naming scheme for this repo
+}
+
+// parseWorkspace is the inverse of generateWorkspace: it splits a workspace name into the
+// product, module, env and instance it was generated from. It reports false for names that
+// do not follow the scheme, including workspaces of other repositories or prefixes.
+func (m *Manager) parseWorkspace(name string) (Command, bool) {
+	if prefix := m.config.WorkspacePrefix; prefix != "" {
+		rest, ok := strings.CutPrefix(name, prefix+".")
+		if !ok {
+			return Command{}, false
+		}
+		name = rest
+	}
+
+	// {product}.{repo}.{module}.{env}.{module_instance}
+	parts := strings.Split(name, ".")
+	if len(parts) != 5 || parts[1] != m.config.RepoName {
+		return Command{}, false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return Command{}, false
+		}
+	}
+
+	return Command{
+		Product:        parts[0],
+		Module:         parts[2],
+		Env:            strings.ReplaceAll(parts[3], m.config.EnvSeparator(), "/"),
+		ModuleInstance: parts[4],
+	}, true
+}
+
+// ListWorkspaces writes every workspace of module's backend as a table of tf-manage
+// coordinates. Workspaces that do not follow the naming scheme are flagged, not hidden.
+func (m *Manager) ListWorkspaces(w io.Writer, formatName, module string) error {
+	if err := checkTerraformInstalled(); err != nil {
+		return err
+	}
+
+	modulePath := filepath.Join(m.config.GetModulePath(), module)
+	if info, err := os.Stat(modulePath); err != nil || !info.IsDir() {
+		framework.Error(fmt.Sprintf("Module path %s does not exist", framework.AddEmphasisRed(modulePath)))
+		return NewExitCodeError(fmt.Sprintf("module path does not exist: %s", modulePath), ExitValidationFailed)
+	}
+	flags := framework.DefaultCmdFlags()
+	flags.PrintOutput = false
+	flags.PrintMessage = false
+	flags.DecorateOutput = true // Force non-interactive mode to capture output
+	flags.Spinner = true        // Listing workspaces on a remote backend can take a while
+	flags.Dir = modulePath
+	// TF_WORKSPACE would make terraform report a single selected workspace; it is ignored when empty
+	flags.Env = []string{"TF_WORKSPACE="}
+
+	result := m.run("terraform workspace list", fmt.Sprintf("Listing workspaces of %s", framework.AddEmphasisBlue(module)), flags)
+	if !result.Success {
+		framework.Error(strings.TrimSpace(result.Error))
+		return NewExitCodeError("failed to list workspaces", ExitWorkspaceFailed)
+	}
+
+	var entries []WorkspaceEntry
+	unmanaged := 0
+	for _, name := range parseWorkspaceList(result.Output) {
+		entry := WorkspaceEntry{Workspace: name}
+		if cmd, ok := m.parseWorkspace(name); ok {
+			entry.Product, entry.Module, entry.Env, entry.Instance = cmd.Product, cmd.Module, cmd.Env, cmd.ModuleInstance
+			entry.Managed = true
+		} else if name != defaultWorkspace {
+			unmanaged++
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := format.Write(w, formatName, workspaceSummary(entries)); err != nil {
+		return err
+	}
+	if unmanaged > 0 {
+		framework.Info(fmt.Sprintf("%d workspace(s) do not follow the %s naming scheme",
+			unmanaged, framework.AddEmphasisBlue("product.repo.module.env.instance")))
+	}
+	return nil
+}
+
+// workspaceSummary converts workspace entries into a formatter result
+func workspaceSummary(entries []WorkspaceEntry) *format.Result {
+	summary := &format.Result{
+		Title:   "Workspaces",
+		Columns: []string{"product", "module", "env", "instance", "workspace"},
+		Data:    entries,
+	}
+	for _, entry := range entries {
+		row := []string{entry.Product, entry.Module, entry.Env, entry.Instance, entry.Workspace}
+		if !entry.Managed {
+			row = []string{"-", "-", "-", "-", entry.Workspace + " (not tf-manage)"}
+		}
+		summary.Rows = append(summary.Rows, row)
+	}
+	return summary
+}
@@ -0,0 +1,45 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Workspace is the terraform workspace of the instance created by FakeProject
+const Workspace = "product1.test-repo.sample_module.dev.instance_x"
+
+// FakeProject creates a project with one module instance and a fake terraform on PATH that
+// lists the instance workspace and exits with exitCode for every other command. It also
+// forces unattended mode and skips the terraform version check.
+func FakeProject(t *testing.T, exitCode int) string {
+	t.Helper()
+
+	projectDir := t.TempDir()
+	files := map[string]string{
+		".tfm.yaml": "repo_name: test-repo\nenv_rel_path: terraform/environments\nmodule_rel_path: terraform/modules\n",
+		"terraform/modules/sample_module/main.tf":                             "",
+		"terraform/environments/product1/dev/sample_module/instance_x.tfvars": "instance_count = 1\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(projectDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	binDir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nif [ \"$1 $2\" = \"workspace list\" ]; then printf '* default\n  %s\n'; fi\nif [ \"$1\" = workspace ]; then exit 0; fi\necho \"fake terraform $1\"\nexit %d\n", Workspace, exitCode)
+	if err := os.WriteFile(filepath.Join(binDir, "terraform"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake terraform: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("TF_EXEC_MODE_OVERRIDE", "1")
+	t.Setenv("TFM_SKIP_VERSION_CHECK", "1")
+	return projectDir
+}
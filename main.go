@@ -19,8 +19,9 @@ func main() {
 	// Set version info for CLI
 	cli.SetVersionInfo(version, commit, date, builtBy)
 
-	if err := cli.Execute(); err != nil {
+	code, err := cli.Run(os.Args[1:])
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
 	}
+	os.Exit(code)
 }
@@ -0,0 +1,107 @@
+// Package tfmanage runs tf-manage actions from Go programs without shelling out to the tf binary.
+//
+// Run captures output through process-wide state, so it must not be called concurrently. It
+// records that output in its own log file and refuses to start while another tf-manage log
+// file is open, rather than replacing it. Set TF_EXEC_MODE_OVERRIDE=1 to run unattended, as
+// operator mode prompts on stdin.
+package tfmanage
+
+import (
+	"errors"
+	"os"
+
+	"github.com/sorinlg/tf-manage2/internal/config"
+	"github.com/sorinlg/tf-manage2/internal/framework"
+	"github.com/sorinlg/tf-manage2/internal/terraform"
+)
+
+// Config is a loaded tf-manage project configuration
+type Config struct {
+	cfg *config.Config
+}
+
+// ProjectDir returns the project root the configuration was loaded from
+func (c *Config) ProjectDir() string {
+	return c.cfg.ProjectDir
+}
+
+// Command identifies the instance to run an action against, as on the tf command line
+type Command struct {
+	Product     string
+	Module      string
+	Env         string
+	Instance    string
+	Action      string
+	ActionFlags string   // Extra flags for the action, e.g. "--parallelism 20 -target=aws_s3_bucket.logs"
+	Vars        []string // key=value variable overrides, applied in order like --set
+}
+
+// Result describes a finished run
+type Result struct {
+	ExitCode  int    // Exit code the tf binary would have exited with
+	Output    string // tf-manage and terraform output, without colors
+	Workspace string // Terraform workspace the action ran in, empty when the run stopped before it was known
+}
+
+// Load reads the .tfm.yaml or .tfm.conf configuration of the project rooted at projectDir
+func Load(projectDir string) (*Config, error) {
+	cfg, err := config.LoadConfigFrom(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{cfg: cfg}, nil
+}
+
+// Run executes cmd and never exits the process. The error is nil when the action succeeded;
+// otherwise Result.ExitCode carries the code the tf binary would have used.
+func Run(cfg *Config, cmd Command) (Result, error) {
+	previous := framework.SetStrictExit(false)
+	defer framework.SetStrictExit(previous)
+	if err := framework.SetRedactPatterns(cfg.cfg.RedactPatterns); err != nil {
+		return Result{ExitCode: terraform.ExitValidationFailed}, terraform.NewExitCodeError(err.Error(), terraform.ExitValidationFailed)
+	}
+
+	if framework.LogFileOpen() {
+		return Result{ExitCode: 1}, errors.New("a tf-manage log file is already open; close it before calling Run")
+	}
+	output, err := os.CreateTemp("", "tfmanage-*.log")
+	if err != nil {
+		return Result{ExitCode: 1}, err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+	if err := framework.OpenLogFile(output.Name()); err != nil {
+		return Result{ExitCode: 1}, err
+	}
+
+	tfm := terraform.NewManager(cfg.cfg)
+	runErr := tfm.Execute(&terraform.Command{
+		Product:        cmd.Product,
+		Module:         cmd.Module,
+		Env:            cmd.Env,
+		ModuleInstance: cmd.Instance,
+		Action:         cmd.Action,
+		ActionFlags:    cmd.ActionFlags,
+		Vars:           cmd.Vars,
+	})
+
+	framework.CloseLogFile()
+	data, _ := os.ReadFile(output.Name())
+	result := Result{ExitCode: exitCode(runErr), Output: string(data), Workspace: tfm.RunInfo().Workspace}
+	if result.ExitCode == 0 {
+		return result, nil
+	}
+	return result, runErr
+}
+
+// exitCode maps an Execute error to the tf binary's exit code
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitCodeErr *terraform.ExitCodeError
+	if errors.As(err, &exitCodeErr) {
+		return exitCodeErr.ExitCode
+	}
+	return 1
+}
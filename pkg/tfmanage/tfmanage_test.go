@@ -0,0 +1,117 @@
+package tfmanage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sorinlg/tf-manage2/internal/framework"
+	"github.com/sorinlg/tf-manage2/internal/testutil"
+)
+
+func TestRun(t *testing.T) {
+	instance := Command{Product: "product1", Module: "sample_module", Env: "dev", Instance: "instance_x"}
+
+	tests := []struct {
+		name     string
+		action   string
+		env      string
+		tfExit   int
+		wantCode int
+		wantErr  bool
+	}{
+		{"Success", "validate", "dev", 0, 0, false},
+		{"Terraform failure", "validate", "dev", 3, 3, true},
+		{"Validation failure", "validate", "staging", 0, 64, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projectDir := testutil.FakeProject(t, tt.tfExit)
+			wd, _ := os.Getwd()
+
+			cfg, err := Load(projectDir)
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if cfg.ProjectDir() != projectDir {
+				t.Errorf("ProjectDir() = %s, want %s", cfg.ProjectDir(), projectDir)
+			}
+
+			cmd := instance
+			cmd.Action, cmd.Env = tt.action, tt.env
+			result, err := Run(cfg, cmd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.ExitCode != tt.wantCode {
+				t.Errorf("ExitCode = %d, want %d", result.ExitCode, tt.wantCode)
+			}
+			if got, _ := os.Getwd(); got != wd {
+				t.Errorf("Working directory changed to %s", got)
+			}
+			if tt.wantCode == 64 {
+				return
+			}
+			if result.Workspace != testutil.Workspace {
+				t.Errorf("Workspace = %q", result.Workspace)
+			}
+			if !strings.Contains(result.Output, "fake terraform validate") {
+				t.Errorf("Output does not contain terraform's output:\n%s", result.Output)
+			}
+		})
+	}
+}
+
+func TestRunRedactsOutput(t *testing.T) {
+	projectDir := testutil.FakeProject(t, 0)
+	configPath := filepath.Join(projectDir, ".tfm.yaml")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	data = append(data, "approval_command: echo token=hunter2\nredact_patterns:\n  - 'token=(\\S+)'\n"...)
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Cleanup(func() { framework.SetRedactPatterns(nil) })
+
+	cfg, err := Load(projectDir)
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	result, err := Run(cfg, Command{Product: "product1", Module: "sample_module", Env: "dev", Instance: "instance_x", Action: "apply"})
+	if err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if !strings.Contains(result.Output, "token=***") || strings.Contains(result.Output, "hunter2") {
+		t.Errorf("Output does not redact the configured pattern:\n%s", result.Output)
+	}
+}
+
+func TestRunRefusesOpenLogFile(t *testing.T) {
+	cfg, err := Load(testutil.FakeProject(t, 0))
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	logPath := filepath.Join(t.TempDir(), "caller.log")
+	if err := framework.OpenLogFile(logPath); err != nil {
+		t.Fatalf("OpenLogFile() error: %v", err)
+	}
+	defer framework.CloseLogFile()
+
+	result, err := Run(cfg, Command{Product: "product1", Module: "sample_module", Env: "dev", Instance: "instance_x", Action: "validate"})
+	if err == nil || result.ExitCode != 1 {
+		t.Errorf("Run() = %d, %v; want exit code 1 and an error", result.ExitCode, err)
+	}
+	if !framework.LogFileOpen() {
+		t.Error("Run() closed the caller's log file")
+	}
+}
+
+func TestLoadMissingProject(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load() expected an error for a missing project directory")
+	}
+}